@@ -0,0 +1,93 @@
+package cache
+
+import "time"
+
+// WithAdaptiveCleanup makes the janitor choose the time until its next run
+// after every run, between min and max, instead of running at a fixed
+// interval. It waits until the nearest upcoming expiration, so that expired
+// items don't pile up between runs and no runs are wasted when nothing is
+// about to expire. When a run deletes items, the next one comes at most half
+// the previous interval later, so that the janitor keeps up with bursts of
+// expirations. The first run comes after the cleanup interval, or max if there
+// is none, limited to between min and max. min must be greater than zero, and
+// is raised to it otherwise.
+//
+// Finding the nearest expiration takes a scan of the cache holding the read
+// lock, unless WithExpirationIndex is also used.
+func WithAdaptiveCleanup(min, max time.Duration) Option {
+	return func(c *cache) {
+		if min < 1 {
+			min = 1
+		}
+		if max < min {
+			max = min
+		}
+		c.adaptiveCleanup = &adaptiveCleanup{min: min, max: max}
+	}
+}
+
+type adaptiveCleanup struct {
+	min, max time.Duration
+}
+
+// Returns d limited to between the minimum and maximum intervals, or the
+// maximum if d is less than one.
+func (a *adaptiveCleanup) clamp(d time.Duration) time.Duration {
+	switch {
+	case d < 1 || d > a.max:
+		return a.max
+	case d < a.min:
+		return a.min
+	}
+	return d
+}
+
+// Returns the interval until the next run, given the previous interval, the
+// number of items the last run deleted and, if upcoming is true, the time
+// until the nearest upcoming expiration.
+func (a *adaptiveCleanup) next(prev time.Duration, deleted int, until time.Duration, upcoming bool) time.Duration {
+	d := a.max
+	if upcoming {
+		d = until
+		if d < 1 {
+			d = a.min
+		}
+	}
+	if deleted > 0 && d > prev/2 {
+		d = prev / 2
+	}
+	if d < a.min {
+		d = a.min
+	}
+	return a.clamp(d)
+}
+
+// Returns the time until the nearest expiration of an unexpired item, and
+// whether there is one.
+func (c *cache) untilNextExpiration() (time.Duration, bool) {
+	now := c.now().UnixNano()
+	c.RLock()
+	defer c.RUnlock()
+
+	var next int64
+	if h := c.expirations; h != nil {
+		// The earliest entry may be stale or already past, which only
+		// makes the janitor come back sooner.
+		if h.Len() == 0 {
+			return 0, false
+		}
+		if next = (*h)[0].expiration; next < now {
+			next = now
+		}
+		return time.Duration(next - now), true
+	}
+	for _, v := range c.items {
+		if v.Expiration >= now && (next == 0 || v.Expiration < next) {
+			next = v.Expiration
+		}
+	}
+	if next == 0 {
+		return 0, false
+	}
+	return time.Duration(next - now), true
+}
@@ -0,0 +1,82 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAdaptiveCleanupNext(t *testing.T) {
+	a := &adaptiveCleanup{min: time.Second, max: time.Minute}
+	tests := []struct {
+		prev     time.Duration
+		deleted  int
+		until    time.Duration
+		upcoming bool
+		want     time.Duration
+	}{
+		{10 * time.Second, 0, 0, false, time.Minute},
+		{10 * time.Second, 0, 30 * time.Second, true, 30 * time.Second},
+		{10 * time.Second, 0, time.Hour, true, time.Minute},
+		{10 * time.Second, 0, time.Millisecond, true, time.Second},
+		{10 * time.Second, 0, 0, true, time.Second},
+		{10 * time.Second, 5, 30 * time.Second, true, 5 * time.Second},
+		{10 * time.Second, 5, 2 * time.Second, true, 2 * time.Second},
+		{time.Second, 5, 30 * time.Second, true, time.Second},
+	}
+	for _, tt := range tests {
+		if got := a.next(tt.prev, tt.deleted, tt.until, tt.upcoming); got != tt.want {
+			t.Errorf("next(%v, %d, %v, %v) = %v, want %v", tt.prev, tt.deleted, tt.until, tt.upcoming, got, tt.want)
+		}
+	}
+}
+
+func TestAdaptiveCleanup(t *testing.T) {
+	for _, index := range []bool{false, true} {
+		clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+		opts := []Option{WithClock(clock), WithAdaptiveCleanup(time.Second, time.Hour)}
+		if index {
+			opts = append(opts, WithExpirationIndex())
+		}
+		tc := New(DefaultExpiration, time.Minute, opts...)
+		expired := make(chan interface{}, 1)
+		tc.OnExpired(func(k interface{}, v interface{}) {
+			expired <- k
+		})
+		tc.Set("a", 1, 30*time.Second)
+		tc.Set("b", 2, 5*time.Minute)
+
+		clock.Advance(time.Minute)
+		if k := <-expired; k != "a" {
+			t.Fatalf("Expired %v, want a", k)
+		}
+		// After deleting a, the janitor comes back within half a minute
+		// rather than when b expires.
+		if !waitPeriod(clock, tc.janitor.ticker, 30*time.Second) {
+			t.Fatalf("Interval after deleting an item is not 30s (index: %v)", index)
+		}
+		clock.Advance(30 * time.Second)
+		if !waitPeriod(clock, tc.janitor.ticker, 3*time.Minute+30*time.Second) {
+			t.Fatalf("Interval is not the 3m30s until b expires (index: %v)", index)
+		}
+		clock.Advance(4 * time.Minute)
+		if k := <-expired; k != "b" {
+			t.Fatalf("Expired %v, want b", k)
+		}
+		tc.Close()
+	}
+}
+
+// Reports whether the fake ticker's period becomes d within a few seconds.
+func waitPeriod(clock *FakeClock, ticker Ticker, d time.Duration) bool {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		clock.mu.Lock()
+		period := ticker.(*fakeTicker).period
+		clock.mu.Unlock()
+		if period == d {
+			return true
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return false
+}
@@ -0,0 +1,173 @@
+package cache
+
+import "container/list"
+
+// WithARCEviction makes the cache evict items as picked by the Adaptive
+// Replacement Cache algorithm when it is full, instead of the least recently
+// used one. ARC splits the items into those used once since they were stored
+// and those used more often, and remembers the keys it recently evicted from
+// each part. A key stored again soon after its eviction shows which part was
+// cut too short, and the split moves toward it, so the cache adapts between
+// favoring recency, as during scans, and frequency, as with hotspots. The
+// evicted keys remembered are bounded by the limit set with WithMaxEntries,
+// or by the number of items in the cache if there is none. See WithMaxEntries
+// and WithMaxCost.
+func WithARCEviction() Option {
+	return func(c *cache) {
+		c.newPolicy = func(maxEntries int) EvictionPolicy {
+			return newARCPolicy(maxEntries)
+		}
+	}
+}
+
+// NewARCPolicy returns the policy used by WithARCEviction, sized for capacity
+// items, or for the number of items in the cache if capacity is zero. It is
+// for use with WithEvictionPolicy.
+func NewARCPolicy(capacity int) EvictionPolicy {
+	return newARCPolicy(capacity)
+}
+
+// The lists of ARC: keys used once (t1) and more often (t2) since they were
+// stored, and the keys recently evicted from each (b1 and b2). Each list
+// holds its most recently used key first.
+const (
+	arcT1 = iota
+	arcT2
+	arcB1
+	arcB2
+)
+
+type arcPolicy struct {
+	// The number of items ARC is sized for, or 0 to follow the number of
+	// items in the cache.
+	capacity int
+	// The target size of t1.
+	p       int
+	lists   [4]*list.List
+	entries map[interface{}]*arcEntry
+}
+
+type arcEntry struct {
+	list int
+	elem *list.Element
+}
+
+func newARCPolicy(capacity int) *arcPolicy {
+	if capacity < 0 {
+		capacity = 0
+	}
+	p := &arcPolicy{
+		capacity: capacity,
+		entries:  map[interface{}]*arcEntry{},
+	}
+	for i := range p.lists {
+		p.lists[i] = list.New()
+	}
+	return p
+}
+
+func (p *arcPolicy) len(l int) int {
+	return p.lists[l].Len()
+}
+
+// Returns the number of items ARC is sized for.
+func (p *arcPolicy) size() int {
+	if p.capacity > 0 {
+		return p.capacity
+	}
+	if n := p.len(arcT1) + p.len(arcT2); n > 0 {
+		return n
+	}
+	return 1
+}
+
+// Move k, which is tracked, to the front of list l.
+func (p *arcPolicy) move(k interface{}, e *arcEntry, l int) {
+	p.lists[e.list].Remove(e.elem)
+	e.list = l
+	e.elem = p.lists[l].PushFront(k)
+}
+
+func (p *arcPolicy) RecordInsert(k interface{}) {
+	e, found := p.entries[k]
+	if !found {
+		p.entries[k] = &arcEntry{arcT1, p.lists[arcT1].PushFront(k)}
+		p.trim()
+		return
+	}
+	switch e.list {
+	case arcT1, arcT2:
+		p.move(k, e, arcT2)
+	case arcB1:
+		// t1 was too short: grow it.
+		delta := 1
+		if n := p.len(arcB2) / p.len(arcB1); n > delta {
+			delta = n
+		}
+		if p.p += delta; p.p > p.size() {
+			p.p = p.size()
+		}
+		p.move(k, e, arcT2)
+	case arcB2:
+		// t2 was too short: shrink t1.
+		delta := 1
+		if n := p.len(arcB1) / p.len(arcB2); n > delta {
+			delta = n
+		}
+		if p.p -= delta; p.p < 0 {
+			p.p = 0
+		}
+		p.move(k, e, arcT2)
+	}
+	p.trim()
+}
+
+func (p *arcPolicy) RecordAccess(k interface{}) {
+	if e, found := p.entries[k]; found && (e.list == arcT1 || e.list == arcT2) {
+		p.move(k, e, arcT2)
+	}
+}
+
+// Forget the least recently evicted keys beyond the sizes ARC allows.
+func (p *arcPolicy) trim() {
+	c := p.size()
+	for p.len(arcB1) > 0 && p.len(arcT1)+p.len(arcB1) > c {
+		p.drop(arcB1)
+	}
+	for p.len(arcB2) > 0 && p.len(arcT1)+p.len(arcT2)+p.len(arcB1)+p.len(arcB2) > 2*c {
+		p.drop(arcB2)
+	}
+}
+
+// Forget the last key of list l.
+func (p *arcPolicy) drop(l int) {
+	back := p.lists[l].Back()
+	p.lists[l].Remove(back)
+	delete(p.entries, back.Value)
+}
+
+// Remembers k as evicted, if it is in the cache.
+func (p *arcPolicy) Remove(k interface{}) {
+	e, found := p.entries[k]
+	if !found {
+		return
+	}
+	switch e.list {
+	case arcT1:
+		p.move(k, e, arcB1)
+	case arcT2:
+		p.move(k, e, arcB2)
+	}
+	p.trim()
+}
+
+func (p *arcPolicy) Victim() (interface{}, bool) {
+	t1, t2 := p.lists[arcT1], p.lists[arcT2]
+	switch {
+	case t1.Len() > 0 && (t1.Len() > p.p || t2.Len() == 0):
+		return t1.Back().Value, true
+	case t2.Len() > 0:
+		return t2.Back().Value, true
+	}
+	return nil, false
+}
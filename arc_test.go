@@ -0,0 +1,81 @@
+package cache
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestARCPolicy(t *testing.T) {
+	p := newARCPolicy(2)
+	if _, found := p.Victim(); found {
+		t.Error("Empty policy returned a victim")
+	}
+	p.RecordInsert("a")
+	p.RecordInsert("b")
+	if k, _ := p.Victim(); k != "a" {
+		t.Errorf("Victim is %v, want a", k)
+	}
+	p.RecordAccess("a")
+	if k, _ := p.Victim(); k != "b" {
+		t.Errorf("Victim is %v, want b, used once", k)
+	}
+	p.Remove("b")
+	if p.entries["b"].list != arcB1 {
+		t.Error("Evicted key b is not remembered")
+	}
+
+	// Storing b again soon after its eviction makes room for keys used
+	// once.
+	p.RecordInsert("b")
+	if p.p != 1 {
+		t.Errorf("Target is %d after a hit in b1, want 1", p.p)
+	}
+	if p.entries["b"].list != arcT2 {
+		t.Error("b is not among the keys used more than once")
+	}
+	p.Remove("a")
+	p.RecordInsert("a")
+	if p.p != 0 {
+		t.Errorf("Target is %d after a hit in b2, want 0", p.p)
+	}
+
+	// Ghosts are bounded.
+	for i := 0; i < 100; i++ {
+		k := fmt.Sprint(i)
+		p.RecordInsert(k)
+		p.Remove(k)
+	}
+	if len(p.entries) > 4 {
+		t.Errorf("Policy tracks %d keys, want at most twice its capacity", len(p.entries))
+	}
+}
+
+func TestARCEviction(t *testing.T) {
+	tc := New(DefaultExpiration, 0, WithMaxEntries(4), WithARCEviction())
+	tc.Set("h1", 1, DefaultExpiration)
+	tc.Set("h2", 2, DefaultExpiration)
+	tc.Get("h1")
+	tc.Get("h2")
+	// A scan doesn't evict the hot keys.
+	for i := 0; i < 20; i++ {
+		tc.Set(fmt.Sprint("scan", i), i, DefaultExpiration)
+	}
+	for _, k := range []string{"h1", "h2", "scan19"} {
+		if _, found := tc.Get(k); !found {
+			t.Errorf("%s was evicted", k)
+		}
+	}
+	if err := tc.ConsistencyCheck(); err != nil {
+		t.Error(err)
+	}
+
+	// Without a limit, ghosts are bounded by the number of items.
+	tc = New(DefaultExpiration, 0, WithARCEviction())
+	for i := 0; i < 10; i++ {
+		tc.Set(i, i, DefaultExpiration)
+		tc.Delete(i)
+	}
+	if n := len(tc.access.policy.(*arcPolicy).entries); n > 2 {
+		t.Errorf("Policy tracks %d keys for an empty cache", n)
+	}
+}
@@ -0,0 +1,77 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// WithLoadCircuitBreaker stops the cache from calling loaders once threshold
+// loads in a row have failed: for the next cooldown, GetOrLoad and the other
+// loading methods fail fast with a *KeyError wrapping ErrCircuitOpen instead
+// of loading missing items. After the cooldown, a single load is let through
+// to try the backing store again: if it succeeds, loads resume as normal; if it
+// fails, the breaker opens for another cooldown. Callers waiting for a load
+// that is already in progress still get its result. With
+// WithStaleWhileRevalidate, stale values are still served while the breaker is
+// open, but not reloaded; refresh-ahead loads are skipped too. The breaker
+// counts the failures of all keys together, since they usually share one
+// backing store. Loads failing because a context was canceled or timed out
+// are not counted. threshold must be at least one, and is raised to it
+// otherwise.
+func WithLoadCircuitBreaker(threshold int, cooldown time.Duration) Option {
+	return func(c *cache) {
+		if threshold < 1 {
+			threshold = 1
+		}
+		c.breaker = &loadBreaker{threshold: threshold, cooldown: cooldown}
+	}
+}
+
+// The state of the circuit breaker. It has its own mutex, as the shards of a
+// split cache share it.
+type loadBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	// Consecutive failed loads.
+	failures int
+	// When the breaker stops rejecting loads, if it is open.
+	openUntil int64
+	// Whether the trial load after a cooldown is in progress.
+	probing bool
+}
+
+// Reports whether a load may start at now.
+func (b *loadBreaker) allow(now int64) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch {
+	case b.failures < b.threshold:
+		return true
+	case now < b.openUntil || b.probing:
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+// Record the outcome of a load that finished at now. Context errors come from
+// callers giving up, not from the backing store, so they count neither way.
+func (b *loadBreaker) record(err error, now int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if isContextError(err) {
+		return
+	}
+	if err == nil {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openUntil = now + int64(b.cooldown)
+	}
+}
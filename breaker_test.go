@@ -0,0 +1,106 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLoadCircuitBreaker(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	tc := New(DefaultExpiration, 0, WithClock(clock), WithLoadCircuitBreaker(3, time.Minute))
+	errDown := errors.New("down")
+	loads := 0
+	var loadErr error
+	load := func(k interface{}) (interface{}, time.Duration, error) {
+		loads++
+		if loadErr != nil {
+			return nil, 0, loadErr
+		}
+		return k, DefaultExpiration, nil
+	}
+
+	loadErr = errDown
+	for i := 0; i < 3; i++ {
+		if _, err := tc.GetOrLoad(i, load); err != errDown {
+			t.Fatalf("GetOrLoad returned %v, want %v", err, errDown)
+		}
+	}
+	_, err := tc.GetOrLoad(3, load)
+	var kerr *KeyError
+	if !errors.Is(err, ErrCircuitOpen) || !errors.As(err, &kerr) || kerr.Key != 3 {
+		t.Fatalf("GetOrLoad with the breaker open returned %v, want ErrCircuitOpen for 3", err)
+	}
+	if loads != 3 {
+		t.Errorf("Loaded %d times, want 3", loads)
+	}
+
+	// A failed trial opens the breaker for another cooldown.
+	clock.Advance(2 * time.Minute)
+	if _, err := tc.GetOrLoad(4, load); err != errDown {
+		t.Fatalf("Trial load returned %v, want %v", err, errDown)
+	}
+	if _, err := tc.GetOrLoad(5, load); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("GetOrLoad after a failed trial returned %v, want ErrCircuitOpen", err)
+	}
+
+	clock.Advance(2 * time.Minute)
+	loadErr = nil
+	for i := 6; i < 10; i++ {
+		if x, err := tc.GetOrLoad(i, load); err != nil || x != i {
+			t.Fatalf("GetOrLoad after a successful trial returned %v, %v", x, err)
+		}
+	}
+	if loads != 8 {
+		t.Errorf("Loaded %d times, want 8", loads)
+	}
+}
+
+func TestLoadCircuitBreakerStale(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	tc := New(time.Minute, 0, WithClock(clock), WithLoadCircuitBreaker(1, time.Hour), WithStaleWhileRevalidate(time.Hour))
+	tc.Set("a", "old", DefaultExpiration)
+	tc.GetOrLoad("b", func(k interface{}) (interface{}, time.Duration, error) {
+		return nil, 0, errors.New("down")
+	})
+	clock.Advance(2 * time.Minute)
+
+	x, err := tc.GetOrLoad("a", func(k interface{}) (interface{}, time.Duration, error) {
+		t.Error("Reloaded a stale item with the breaker open")
+		return nil, 0, nil
+	})
+	if err != nil || x != "old" {
+		t.Errorf("GetOrLoad returned %v, %v; want the stale value", x, err)
+	}
+}
+
+func TestLoadCircuitBreakerContextErrors(t *testing.T) {
+	tc := New(DefaultExpiration, 0, WithLoadCircuitBreaker(2, time.Minute))
+	for _, err := range []error{context.Canceled, context.DeadlineExceeded, context.Canceled} {
+		tc.GetOrLoad("a", func(k interface{}) (interface{}, time.Duration, error) {
+			return nil, 0, err
+		})
+	}
+	x, err := tc.GetOrLoad("a", func(k interface{}) (interface{}, time.Duration, error) {
+		return "loaded", DefaultExpiration, nil
+	})
+	if err != nil || x != "loaded" {
+		t.Errorf("GetOrLoad returned %v, %v after context errors, want the breaker closed", x, err)
+	}
+}
+
+func TestLoadCircuitBreakerGetOrLoadMany(t *testing.T) {
+	tc := New(DefaultExpiration, 0, WithLoadCircuitBreaker(1, time.Hour))
+	tc.GetOrLoad("a", func(k interface{}) (interface{}, time.Duration, error) {
+		return nil, 0, errors.New("down")
+	})
+	_, err := tc.GetOrLoadMany([]interface{}{"b", "c"}, func(missing []interface{}) (map[interface{}]ValueTTL, error) {
+		t.Error("Loaded with the breaker open")
+		return nil, nil
+	})
+	var kerr *KeyError
+	if !errors.Is(err, ErrCircuitOpen) || !errors.As(err, &kerr) || kerr.Key != "b" {
+		t.Errorf("GetOrLoadMany with the breaker open returned %v, want ErrCircuitOpen for b", err)
+	}
+}
@@ -1,9 +1,14 @@
 package cache
 
 import (
+	"context"
 	"fmt"
+	"hash/maphash"
+	"math/rand"
 	"runtime"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -12,12 +17,19 @@ type Item struct {
 	Expiration int64
 }
 
-// Returns true if the item has expired.
+// Returns true if the item has expired. This uses the system clock, not the
+// clock of the cache the item came from; for caches created with WithClock,
+// use ExpiredAt with the time of that clock instead.
 func (item Item) Expired() bool {
+	return item.ExpiredAt(time.Now())
+}
+
+// Returns true if the item has expired at t.
+func (item Item) ExpiredAt(t time.Time) bool {
 	if item.Expiration == 0 {
 		return false
 	}
-	return time.Now().UnixNano() > item.Expiration
+	return t.UnixNano() > item.Expiration
 }
 
 const (
@@ -35,72 +47,242 @@ type Cache struct {
 }
 
 type cache struct {
+	// Accessed atomically; kept first so the counters are 64-bit aligned.
+	stats statCounters
 	sync.RWMutex
-	defaultExpiration time.Duration
-	items             map[interface{}]Item
-	onEvicted         func(interface{}, interface{})
-	janitor           *janitor
+	defaultExpiration     time.Duration
+	cleanupInterval       time.Duration
+	clock                 Clock
+	items                 map[interface{}]Item
+	onEvicted             func(interface{}, interface{})
+	janitor               *janitor
+	events                *eventHub
+	keyFunc               func(interface{}) string
+	maxEntries            int
+	pressure              *capacityPressure
+	pending               []eviction
+	pendingIn             *[]eviction // if set, evictions are queued here instead
+	onEvictedWithReason   func(interface{}, interface{}, EvictionReason)
+	onExpired             func(interface{}, interface{})
+	maxCost               int64
+	totalCost             int64
+	costs                 map[interface{}]int64
+	maxPerTag             int
+	tagIndex              tagIndex
+	onMemoryPressure      func() int
+	hitWindow             *hitWindow
+	generation            uint64
+	timers                map[interface{}]*time.Timer
+	access                accessOrder
+	peakItems             int
+	clampLoadedExpiration bool
+	validator             func(key, value interface{}) bool
+	janitorBatchSize      int
+	expirations           *expirationHeap
+	adaptiveCleanup       *adaptiveCleanup
+	sliding               bool
+	ttlJitter             float64
+	maxStale              time.Duration
+	refreshAhead          *refreshAhead
+	negativeTTL           time.Duration
+	failures              map[interface{}]failedLoad
+	breaker               *loadBreaker
+	retry                 *RetryPolicy
+	keyLocks              *keyLocks
+	keyLocksOnce          sync.Once
+	writeThrough          *writeThrough
+	writeBehind           *writeBehind
+	newPolicy             func(maxEntries int) EvictionPolicy
+	tinyLFU               bool
+	coalescer             *coalescer
+	closeOnce             sync.Once
+	loads                 map[interface{}]*loadCall
+	snapshotter           *snapshotter
+	codec                 Codec
+	expvarName            string
+	numShards             int
+	// The shards holding the items if the cache is split, nil otherwise.
+	// See WithShards.
+	shards []*cache
+	seed   maphash.Seed
+	// The item count of all shards, if the cache is split, and how many
+	// items of this shard it includes.
+	count   *itemCount
+	counted int
+}
+
+// Returns the key under which k is stored.
+func (c *cache) key(k interface{}) interface{} {
+	if c.keyFunc == nil {
+		return k
+	}
+	return c.keyFunc(k)
 }
 
 // Add an item to the cache, replacing any existing item. If the duration is 0
 // (DefaultExpiration), the cache's default expiration time is used. If it is -1
-// (NoExpiration), the item never expires.
-func (c *cache) Set(k string, x interface{}, d time.Duration) {
+// (NoExpiration), the item never expires. With WithWriteThrough, the item is
+// written to the store first and not added if that fails; use TrySet to see
+// the error. With WithWriteBehind, the write is queued for the store.
+func (c *cache) Set(k interface{}, x interface{}, d time.Duration) {
+	c = c.shard(k)
+	if c.writeThrough != nil || c.writeBehind != nil {
+		c.TrySet(k, x, d)
+		return
+	}
+	c.setItem(k, x, d)
+}
+
+// Set without writing through to the store.
+func (c *cache) setItem(k interface{}, x interface{}, d time.Duration) {
+	k = c.key(k)
 	// "Inlining" of set
-	var e int64
-	if d == DefaultExpiration {
-		d = c.defaultExpiration
+	item := Item{
+		Object:     x,
+		Expiration: c.expiration(d),
 	}
-	if d > 0 {
-		e = time.Now().Add(d).UnixNano()
+	if c.coalescer != nil && c.coalescer.buffer(k, item) {
+		return
 	}
 	c.Lock()
-	defer c.Unlock()
-	c.items[k] = Item{
-		Object:     x,
-		Expiration: e,
-	}
+	defer c.unlock()
+	c.put(k, item)
 	// TODO: Calls to mu.Unlock are currently not deferred because defer
 	// adds ~200 ns (as of go1.)
 }
 
-func (c *cache) set(k interface{}, x interface{}, d time.Duration) {
-	var e int64
+func (c *cache) set(k interface{}, x interface{}, d time.Duration) bool {
+	return c.put(k, Item{
+		Object:     x,
+		Expiration: c.expiration(d),
+	})
+}
+
+// Returns the expiration of an item stored for d from now, or 0 if it doesn't
+// expire.
+func (c *cache) expiration(d time.Duration) int64 {
 	if d == DefaultExpiration {
 		d = c.defaultExpiration
 	}
-	if d > 0 {
-		e = time.Now().Add(d).UnixNano()
+	if d <= 0 {
+		return 0
+	}
+	return c.now().Add(c.jitter(d)).UnixNano()
+}
+
+// Store item under k, replacing any existing item and its tags. Returns false
+// if the admission filter rejected a new key. Must be called with the write
+// lock held.
+func (c *cache) put(k interface{}, item Item) bool {
+	delete(c.failures, k)
+	if !c.makeRoom(k) {
+		return false
+	}
+	old, replaced := c.get(k)
+	if replaced && c.onEvictedWithReason != nil {
+		c.queue(eviction{k, old.Object, ReasonReplaced})
+	}
+	c.untag(k)
+	c.setCost(k, 0)
+	if c.access.isPinned(k) {
+		item.Expiration = 0
+	}
+	c.items[k] = item
+	c.schedule(k, item.Expiration)
+	c.access.touch(k)
+	c.updatePeak()
+	atomic.AddUint64(&c.stats.sets, 1)
+	if replaced {
+		c.events.emit(Event{Op: EventReplace, Key: k, Value: item.Object, OldValue: old.Object})
+	} else {
+		c.events.emit(Event{Op: EventSet, Key: k, Value: item.Object})
+	}
+	return true
+}
+
+// Unlock the cache, then call the eviction callbacks for the items evicted
+// while the lock was held and notify the capacity pressure listener if needed.
+func (c *cache) unlock() {
+	c.release()()
+}
+
+// Release the write lock and return a function that calls the callbacks for
+// the items evicted while it was held, for callers holding several locks.
+func (c *cache) release() func() {
+	evicted := c.pending
+	c.pending = nil
+	onEvicted, onEvictedWithReason, onExpired := c.onEvicted, c.onEvictedWithReason, c.onExpired
+	onPressure, utilization := c.checkPressure()
+	c.Unlock()
+	return func() {
+		for _, v := range evicted {
+			if onEvicted != nil && v.reason != ReasonReplaced {
+				onEvicted(v.key, v.value)
+			}
+			if onEvictedWithReason != nil {
+				onEvictedWithReason(v.key, v.value, v.reason)
+			}
+			if onExpired != nil && v.reason == ReasonExpired {
+				onExpired(v.key, v.value)
+			}
+		}
+		if onPressure != nil {
+			onPressure(utilization)
+		}
+	}
+}
+
+// Queue an evicted item for the eviction callbacks. Must be called with the
+// write lock held.
+func (c *cache) queue(e eviction) {
+	if c.pendingIn != nil {
+		*c.pendingIn = append(*c.pendingIn, e)
+		return
+	}
+	c.pending = append(c.pending, e)
+}
+
+// Reset the expiration of an existing item without storing it anew.
+func (c *cache) extend(k interface{}, item *Item, d time.Duration) {
+	e := c.expiration(d)
+	if c.access.isPinned(k) {
+		e = 0
 	}
 	c.items[k] = Item{
-		Object:     x,
+		Object:     item.Object,
 		Expiration: e,
 	}
+	c.schedule(k, e)
 }
 
 // Add an item to the cache only if an item doesn't already exist for the given
-// key, or if the existing item has expired. Returns an error otherwise.
+// key, or if the existing item has expired. Returns a *KeyError wrapping
+// ErrAlreadyExists otherwise.
 func (c *cache) Add(k interface{}, x interface{}, d time.Duration) error {
+	c = c.shard(k)
+	k = c.key(k)
 	c.Lock()
-	defer c.Unlock()
+	defer c.unlock()
 
 	_, found := c.get(k)
 	if found {
-		return fmt.Errorf("Item %s already exists", k)
+		return &KeyError{k, ErrAlreadyExists}
 	}
 	c.set(k, x, d)
 	return nil
 }
 
 // Set a new value for the cache key only if it already exists, and the existing
-// item hasn't expired. Returns an error otherwise.
+// item hasn't expired. Returns a *KeyError wrapping ErrNotFound otherwise.
 func (c *cache) Replace(k interface{}, x interface{}, d time.Duration) error {
+	c = c.shard(k)
+	k = c.key(k)
 	c.Lock()
-	defer c.Unlock()
+	defer c.unlock()
 
 	_, found := c.get(k)
 	if !found {
-		return fmt.Errorf("Item %s doesn't exist", k)
+		return &KeyError{k, ErrNotFound}
 	}
 	c.set(k, x, d)
 	return nil
@@ -108,89 +290,299 @@ func (c *cache) Replace(k interface{}, x interface{}, d time.Duration) error {
 
 // Get an item from the cache. Returns the item or nil, and a bool indicating
 // whether the key was found.
-func (c *cache) Get(k string) (interface{}, bool) {
+func (c *cache) Get(k interface{}) (interface{}, bool) {
+	c = c.shard(k)
+	k = c.key(k)
 	c.RLock()
-	defer c.RUnlock()
 
 	// "Inlining" of get and Expired
 	item, found := c.items[k]
 	if !found {
+		c.recordLookup(false)
+		c.RUnlock()
 		return nil, false
 	}
 	if item.Expiration > 0 {
-		if time.Now().UnixNano() > item.Expiration {
+		if c.now().UnixNano() > item.Expiration {
+			c.recordLookup(false)
+			lazy := c.onExpired != nil
+			c.RUnlock()
+			if lazy {
+				c.Lock()
+				c.expireStale(k)
+				c.unlock()
+			}
 			return nil, false
 		}
 	}
+	c.recordLookup(true)
+	c.access.used(k)
+	c.RUnlock()
+	if c.dueForRefresh(k, item) {
+		c.Lock()
+		c.refresh(k)
+		c.unlock()
+	}
+	if c.sliding && item.Expiration > 0 && c.defaultExpiration > 0 {
+		c.slide(k)
+	}
 	return item.Object, true
 }
 
+// GetWithGeneration gets an item from the cache like Get, and also returns the
+// cache's generation: a counter that starts at zero and is incremented by every
+// Flush. Comparing generations tells whether a value was read before or after
+// a flush.
+func (c *cache) GetWithGeneration(k interface{}) (interface{}, uint64, bool) {
+	c = c.shard(k)
+	k = c.key(k)
+	c.RLock()
+	defer c.RUnlock()
+
+	item, found := c.get(k)
+	c.recordLookup(found)
+	if !found {
+		return nil, c.generation, false
+	}
+	c.access.used(k)
+	return item.Object, c.generation, true
+}
+
+// GetWithExpiration returns an item and its expiration time from the cache.
+// It returns the item or nil, the expiration time if one is set (if the item
+// never expires a zero value for time.Time is returned), and a bool indicating
+// whether the key was found.
+func (c *cache) GetWithExpiration(k interface{}) (interface{}, time.Time, bool) {
+	c = c.shard(k)
+	k = c.key(k)
+	c.RLock()
+	defer c.RUnlock()
+
+	item, found := c.get(k)
+	c.recordLookup(found)
+	if !found {
+		return nil, time.Time{}, false
+	}
+	c.access.used(k)
+	return item.Object, expirationTime(item.Expiration), true
+}
+
+// TTL returns the remaining lifetime of an unexpired item, or NoExpiration if
+// it never expires, and a bool indicating whether the key was found. Unlike
+// Get, it doesn't count as a lookup or an access.
+func (c *cache) TTL(k interface{}) (time.Duration, bool) {
+	c = c.shard(k)
+	k = c.key(k)
+	c.RLock()
+	defer c.RUnlock()
+
+	item, found := c.get(k)
+	if !found {
+		return 0, false
+	}
+	if item.Expiration == 0 {
+		return NoExpiration, true
+	}
+	return expirationTime(item.Expiration).Sub(c.now()), true
+}
+
 // GetAndExtend an item from the cache. Returns the item or
 // nil, and a bool indicating  whether the key was found. The item's
 // expiration time is extended by d, if found.
 func (c *cache) GetAndExtend(k interface{}, d time.Duration) (interface{}, bool) {
+	c = c.shard(k)
+	k = c.key(k)
 	if d == DefaultExpiration {
 		d = c.defaultExpiration
 	}
 
 	c.Lock()
-	defer c.Unlock()
+	defer c.unlock()
 
 	item, found := c.get(k)
+	c.recordLookup(found)
 	if !found {
+		c.expireStale(k)
 		return nil, false
 	}
 
 	if d > 0 {
-		c.set(k, item.Object, d)
+		c.extend(k, item, d)
 	}
+	c.access.used(k)
 	return item.Object, true
 }
 
+// Touch resets the expiration of an unexpired item to d from now, as with Set,
+// without reading or rewriting its value. Returns false if the item doesn't
+// exist.
+func (c *cache) Touch(k interface{}, d time.Duration) bool {
+	c = c.shard(k)
+	k = c.key(k)
+	c.Lock()
+	defer c.unlock()
+
+	item, found := c.get(k)
+	if !found {
+		c.expireStale(k)
+		return false
+	}
+	c.extend(k, item, d)
+	return true
+}
+
+// Persist removes the expiration of an unexpired item in place, so that it
+// never expires. Returns false if the item doesn't exist.
+func (c *cache) Persist(k interface{}) bool {
+	return c.Touch(k, NoExpiration)
+}
+
+// Pin exempts an unexpired item from expiration and eviction until Unpin is
+// called for it: it is never removed by the janitor, to make room under the
+// limits set with WithMaxEntries and WithMaxCost, or by EvictLRU and
+// TriggerMemoryPressure. Storing a new value under the key keeps it pinned and
+// without expiration. The limits may be exceeded while pinned items take up
+// the room. Deleting the item, explicitly or by Flush, unpins it, and the
+// limit set with WithMaxPerTag still applies. Returns false if the item
+// doesn't exist.
+func (c *cache) Pin(k interface{}) bool {
+	c = c.shard(k)
+	k = c.key(k)
+	c.Lock()
+	defer c.Unlock()
+
+	item, found := c.get(k)
+	if !found {
+		return false
+	}
+	c.access.pin(k)
+	c.extend(k, item, NoExpiration)
+	return true
+}
+
+// Unpin makes a pinned item subject to eviction again and sets its expiration
+// to d from now, as with Set. Returns false if the item doesn't exist.
+func (c *cache) Unpin(k interface{}, d time.Duration) bool {
+	c = c.shard(k)
+	k = c.key(k)
+	c.Lock()
+	defer c.Unlock()
+
+	item, found := c.get(k)
+	if !found {
+		return false
+	}
+	c.access.unpin(k)
+	c.extend(k, item, d)
+	return true
+}
+
 type loader func(k interface{}) (interface{}, time.Duration, error)
 
 // GetOrLoad an item from the cache. If the key is present in the cache,
 // return it's item. Otherwise load a new item using the load() callback, add
 // it to the cache and return it.
+//
+// Concurrent calls for the same missing key share a single call to load().
+// load() runs without the cache locked, so other keys can be read and written
+// while it does; it may even use the cache itself, as long as it doesn't load
+// the key it was called for. If load() panics, the panic is recovered and all
+// the calls sharing it return a *KeyError wrapping a *PanicError.
 func (c *cache) GetOrLoad(k interface{}, load loader) (interface{}, error) {
+	c = c.shard(k)
+	key := c.key(k)
 	c.Lock()
-	defer c.Unlock()
 
-	item, found := c.get(k)
+	item, found := c.get(key)
 	if !found {
-		object, d, err := load(k)
-		if err == nil {
-			c.set(k, object, d)
+		if x, ok := c.serveStale(key, func() (interface{}, time.Duration, error) {
+			return load(k)
+		}); ok {
+			c.unlock()
+			return x, nil
 		}
-		return object, err
+	}
+	c.recordLookup(found)
+	if !found {
+		c.expireStale(key)
+		return c.loadShared(context.Background(), key, func() (interface{}, time.Duration, error) {
+			return load(k)
+		})
 	}
 
+	c.access.used(key)
+	if c.dueForRefresh(key, *item) {
+		c.refresh(key)
+	}
+	c.unlock()
 	return item.Object, nil
 }
 
+// GetOrLoadWithFallbackValue works like GetOrLoad, but if load returns an
+// error, fallback is called with the key and that error. If fallback returns
+// true, the value it returns is stored with the expiration it returns and
+// returned without an error. Otherwise the loader's error is returned and
+// nothing is stored.
+func (c *cache) GetOrLoadWithFallbackValue(k interface{}, load loader, fallback func(k interface{}, err error) (interface{}, time.Duration, bool)) (interface{}, error) {
+	c = c.shard(k)
+	key := c.key(k)
+	c.Lock()
+
+	item, found := c.get(key)
+	c.recordLookup(found)
+	if found {
+		c.access.used(key)
+		c.unlock()
+		return item.Object, nil
+	}
+	c.expireStale(key)
+	return c.loadShared(context.Background(), key, func() (interface{}, time.Duration, error) {
+		object, d, err := load(k)
+		if err != nil {
+			var ok bool
+			object, d, ok = fallback(k, err)
+			if !ok {
+				return nil, 0, err
+			}
+		}
+		return object, d, nil
+	})
+}
+
 // GetAndExtendOrLoad an item from the cache. If the key is present in the cache,
 // return it's item and extend it's expiration. Otherwise load a new item using
 // the load() callback, add it to the cache and return it.
+//
+// A loaded item is stored with the duration returned by load(), not d. If that
+// is NoExpiration the item never expires, unless the cache was created with
+// WithClampLoadedExpiration, in which case it expires after d.
 func (c *cache) GetAndExtendOrLoad(k interface{}, d time.Duration, load loader) (interface{}, error) {
+	c = c.shard(k)
 	if d == DefaultExpiration {
 		d = c.defaultExpiration
 	}
+	key := c.key(k)
 
 	c.Lock()
-	defer c.Unlock()
 
-	item, found := c.get(k)
+	item, found := c.get(key)
+	c.recordLookup(found)
 	if !found {
-		object, d, err := load(k)
-		if err == nil {
-			c.set(k, object, d)
-		}
-		return object, err
+		c.expireStale(key)
+		return c.loadShared(context.Background(), key, func() (interface{}, time.Duration, error) {
+			object, ld, err := load(k)
+			if ld == NoExpiration && c.clampLoadedExpiration {
+				ld = d
+			}
+			return object, ld, err
+		})
 	}
 
 	if d > 0 {
-		c.set(k, item.Object, d)
+		c.extend(key, item, d)
 	}
+	c.access.used(key)
+	c.unlock()
 	return item.Object, nil
 }
 
@@ -201,56 +593,431 @@ func (c *cache) get(k interface{}) (*Item, bool) {
 	}
 	// "Inlining" of Expired
 	if item.Expiration > 0 {
-		if time.Now().UnixNano() > item.Expiration {
+		if c.now().UnixNano() > item.Expiration {
 			return nil, false
 		}
 	}
 	return &item, true
 }
 
+// Add n to a numeric value, preserving its type. Returns false if x is not of
+// an integer or floating point type.
+func addInt64(x interface{}, n int64) (interface{}, bool) {
+	switch v := x.(type) {
+	case int:
+		return v + int(n), true
+	case int8:
+		return v + int8(n), true
+	case int16:
+		return v + int16(n), true
+	case int32:
+		return v + int32(n), true
+	case int64:
+		return v + n, true
+	case uint:
+		return v + uint(n), true
+	case uintptr:
+		return v + uintptr(n), true
+	case uint8:
+		return v + uint8(n), true
+	case uint16:
+		return v + uint16(n), true
+	case uint32:
+		return v + uint32(n), true
+	case uint64:
+		return v + uint64(n), true
+	case float32:
+		return v + float32(n), true
+	case float64:
+		return v + float64(n), true
+	}
+	return nil, false
+}
+
+// IncrementMany adds each delta to the numeric value stored under its key in a
+// single write lock. Existing items keep their type and expiration; missing or
+// expired keys are set to the delta as an int64 with the expiration d. If any
+// existing value is not numeric, an error wrapping ErrNotNumeric is returned
+// and no deltas are applied.
+func (c *cache) IncrementMany(deltas map[interface{}]int64, d time.Duration) error {
+	keyed := make(map[interface{}]int64, len(deltas))
+	for k, n := range deltas {
+		keyed[c.key(k)] += n
+	}
+
+	unlock := c.lockAll()
+	defer unlock()
+
+	return incrementMany(keyed, d, c.keyShard)
+}
+
+// Add the deltas to the values under their converted keys, each in the cache
+// returned by shard, which must be locked. See IncrementMany.
+func incrementMany(keyed map[interface{}]int64, d time.Duration, shard func(k interface{}) *cache) error {
+	for k := range keyed {
+		if item, found := shard(k).get(k); found {
+			if _, ok := addInt64(item.Object, 0); !ok {
+				return &KeyError{k, ErrNotNumeric}
+			}
+		}
+	}
+	for k, n := range keyed {
+		c := shard(k)
+		item, found := c.get(k)
+		if !found {
+			c.set(k, n, d)
+			continue
+		}
+		v, _ := addInt64(item.Object, n)
+		c.update(k, item, v)
+	}
+	return nil
+}
+
+// Store v under k in place of the unexpired item, keeping its expiration,
+// tags, cost and priority. Must be called with the write lock held.
+func (c *cache) update(k interface{}, item *Item, v interface{}) {
+	c.items[k] = Item{
+		Object:     v,
+		Expiration: item.Expiration,
+	}
+	p := c.access.priority(k)
+	c.access.touch(k)
+	c.access.prioritize(k, p)
+	atomic.AddUint64(&c.stats.sets, 1)
+	c.events.emit(Event{Op: EventReplace, Key: k, Value: v, OldValue: item.Object})
+}
+
+// UpdateMany atomically reads the unexpired values of keys, passes them to f
+// and stores the map f returns, all under a single write lock. Every returned
+// entry is stored with the expiration d; keys that f omits from its result are
+// deleted. f must not access the cache.
+func (c *cache) UpdateMany(keys []interface{}, f func(current map[interface{}]interface{}) map[interface{}]interface{}, d time.Duration) {
+	unlock := c.lockAll()
+	defer unlock()
+
+	updateMany(keys, f, d, c.key, c.keyShard)
+}
+
+// Update the values of keys, each in the cache that shard returns for its
+// converted key, which must be locked. See UpdateMany.
+func updateMany(keys []interface{}, f func(current map[interface{}]interface{}) map[interface{}]interface{}, d time.Duration, key func(k interface{}) interface{}, shard func(k interface{}) *cache) {
+	current := make(map[interface{}]interface{}, len(keys))
+	for _, k := range keys {
+		k2 := key(k)
+		if item, found := shard(k2).get(k2); found {
+			current[k] = item.Object
+		}
+	}
+	updated := f(current)
+	for _, k := range keys {
+		if _, keep := updated[k]; !keep {
+			k2 := key(k)
+			shard(k2).evict(k2, EventDelete)
+		}
+	}
+	for k, x := range updated {
+		k2 := key(k)
+		shard(k2).set(k2, x, d)
+	}
+}
+
 // Delete an item from the cache. Does nothing if the key is not in the cache.
+// With WithWriteThrough, the key is deleted from the store first and not from
+// the cache if that fails; use TryDelete to see the error. With
+// WithWriteBehind, the deletion is queued for the store.
 func (c *cache) Delete(k interface{}) {
-	c.Lock()
-	v, evicted := c.delete(k)
-	c.Unlock()
-	if evicted {
-		c.onEvicted(k, v)
+	c = c.shard(k)
+	if c.writeThrough != nil || c.writeBehind != nil {
+		c.TryDelete(k)
+		return
 	}
+	c.deleteItem(k)
 }
 
-func (c *cache) delete(k interface{}) (interface{}, bool) {
-	if c.onEvicted != nil {
-		if v, found := c.items[k]; found {
-			delete(c.items, k)
-			return v.Object, true
-		}
+// Delete without writing through to the store.
+func (c *cache) deleteItem(k interface{}) {
+	k = c.key(k)
+	if c.coalescer != nil {
+		c.coalescer.discard(k)
+	}
+	c.Lock()
+	defer c.unlock()
+
+	c.evict(k, EventDelete)
+}
+
+func (c *cache) delete(k interface{}, op EventOp) (interface{}, bool) {
+	delete(c.failures, k)
+	c.untag(k)
+	c.unschedule(k)
+	c.access.remove(k)
+	c.setCost(k, 0)
+	v, found := c.items[k]
+	if !found {
+		return nil, false
 	}
 	delete(c.items, k)
+	if c.count != nil {
+		c.recount()
+	}
+	c.stats.removed(op)
+	if c.hasEvictionCallback() || c.events.active() {
+		c.events.emit(Event{Op: op, Key: k, Value: v.Object})
+		return v.Object, c.hasEvictionCallback()
+	}
 	return nil, false
 }
 
-type keyAndValue struct {
-	key   interface{}
-	value interface{}
+// Delete several items from the cache in a single write lock. Keys that are
+// not in the cache are ignored.
+func (c *cache) DeleteMany(keys []interface{}) {
+	if c.shards != nil {
+		for s, keys := range c.byShard(keys) {
+			s.DeleteMany(keys)
+		}
+		return
+	}
+	c.Lock()
+	defer c.unlock()
+
+	for _, k := range keys {
+		c.evict(c.key(k), EventDelete)
+	}
+}
+
+// DeleteWhere deletes every unexpired item for which match returns true in a
+// single write lock, calling OnEvicted for them after releasing it, and returns
+// the number of items deleted. match is called with the lock held and must not
+// access the cache.
+func (c *cache) DeleteWhere(match func(k, v interface{}) bool) int {
+	if c.shards != nil {
+		n := 0
+		for _, s := range c.shards {
+			n += s.DeleteWhere(match)
+		}
+		return n
+	}
+	c.Lock()
+	defer c.unlock()
+
+	now := c.now().UnixNano()
+	n := 0
+	for k, v := range c.items {
+		// "Inlining" of Expired
+		if v.Expiration > 0 && now > v.Expiration {
+			continue
+		}
+		if match(k, v.Object) {
+			c.evict(k, EventDelete)
+			n++
+		}
+	}
+	return n
+}
+
+// TakeN atomically removes and returns up to n unexpired items for which ready
+// returns true, calling OnEvicted for them after releasing the lock. This makes
+// it possible to use the cache as a work queue with several consumers: no item
+// is returned twice. The order in which items are considered is unspecified.
+// ready is called with the write lock held and must not access the cache.
+func (c *cache) TakeN(n int, ready func(key, value interface{}) bool) []KeyAndValue {
+	if c.shards != nil {
+		var taken []KeyAndValue
+		for _, s := range c.shards {
+			if len(taken) >= n {
+				break
+			}
+			taken = append(taken, s.TakeN(n-len(taken), ready)...)
+		}
+		return taken
+	}
+	c.Lock()
+	defer c.unlock()
+
+	var taken []KeyAndValue
+	now := c.now().UnixNano()
+	for k, v := range c.items {
+		if len(taken) >= n {
+			break
+		}
+		if v.Expiration > 0 && now > v.Expiration {
+			continue
+		}
+		if ready(k, v.Object) {
+			taken = append(taken, KeyAndValue{k, v.Object})
+		}
+	}
+	for _, kv := range taken {
+		c.evict(kv.Key, EventDelete)
+	}
+	return taken
+}
+
+// A KeyAndValue is a key paired with the value stored under it.
+type KeyAndValue struct {
+	Key   interface{}
+	Value interface{}
 }
 
 // Delete all expired items from the cache.
 func (c *cache) DeleteExpired() {
-	var evictedItems []keyAndValue
-	now := time.Now().UnixNano()
+	for _, s := range c.parts() {
+		s.deleteExpired()
+	}
+}
+
+// Delete all expired items, returning the number deleted.
+func (c *cache) deleteExpired() int {
+	now := c.now().UnixNano()
 	c.Lock()
+	defer c.unlock()
+	c.deleteExpiredFailures(now)
+	if c.expirations != nil {
+		return c.deleteIndexed(now, 0)
+	}
+	deleted := 0
 	for k, v := range c.items {
 		// "Inlining" of expired
 		if v.Expiration > 0 && now > v.Expiration {
-			ov, evicted := c.delete(k)
-			if evicted {
-				evictedItems = append(evictedItems, keyAndValue{k, ov})
+			c.evict(k, EventExpire)
+			deleted++
+		}
+	}
+	return deleted
+}
+
+// Delete the expired items for the janitor, returning the number deleted.
+// With WithJanitorBatchSize, the items are found holding only the read lock
+// and deleted a batch at a time, releasing the lock between batches.
+func (c *cache) janitorDeleteExpired() int {
+	if c.janitorBatchSize < 1 {
+		return c.deleteExpired()
+	}
+	now := c.now().UnixNano()
+	if c.negativeTTL > 0 {
+		c.Lock()
+		c.deleteExpiredFailures(now)
+		c.unlock()
+	}
+	deleted := 0
+	if c.expirations != nil {
+		for {
+			n := c.deleteIndexedBatch(now)
+			deleted += n
+			if n < c.janitorBatchSize {
+				return deleted
 			}
 		}
 	}
-	c.Unlock()
-	for _, v := range evictedItems {
-		c.onEvicted(v.key, v.value)
+	var expired []interface{}
+	c.RLock()
+	for k, v := range c.items {
+		if v.Expiration > 0 && now > v.Expiration {
+			expired = append(expired, k)
+		}
+	}
+	c.RUnlock()
+
+	for len(expired) > 0 {
+		n := c.janitorBatchSize
+		if n > len(expired) {
+			n = len(expired)
+		}
+		deleted += c.deleteExpiredKeys(expired[:n], now)
+		expired = expired[n:]
+	}
+	return deleted
+}
+
+// Delete a batch of the indexed items that have expired at now. Returns the
+// number deleted.
+func (c *cache) deleteIndexedBatch(now int64) int {
+	c.Lock()
+	defer c.unlock()
+	return c.deleteIndexed(now, c.janitorBatchSize)
+}
+
+// Delete the items under keys that are still expired at now, returning the
+// number deleted.
+func (c *cache) deleteExpiredKeys(keys []interface{}, now int64) int {
+	c.Lock()
+	defer c.unlock()
+	deleted := 0
+	for _, k := range keys {
+		// The item may have been replaced since it was found.
+		if v, found := c.items[k]; found && v.Expiration > 0 && now > v.Expiration {
+			c.evict(k, EventExpire)
+			deleted++
+		}
+	}
+	return deleted
+}
+
+// CollectExpired returns the items that have expired but have not yet been
+// deleted, without deleting them. This lets expired items be reconciled with
+// another system before removing them with DeleteMany, rather than from the
+// eviction callback while they are being cleaned up.
+func (c *cache) CollectExpired() []KeyAndValue {
+	var expired []KeyAndValue
+	now := c.now().UnixNano()
+	for _, s := range c.parts() {
+		s.RLock()
+		for k, v := range s.items {
+			if v.Expiration > 0 && now > v.Expiration {
+				expired = append(expired, KeyAndValue{k, v.Object})
+			}
+		}
+		s.RUnlock()
+	}
+	return expired
+}
+
+// Reports whether a and b are equal, treating values that can't be compared
+// with == (such as slices) as different.
+func equal(a, b interface{}) (eq bool) {
+	defer func() {
+		if recover() != nil {
+			eq = false
+		}
+	}()
+	return a == b
+}
+
+// Delete the unexpired items rejected by the janitor's validator. The
+// validator runs without holding the lock; items that were changed while it
+// ran are kept.
+func (c *cache) deleteInvalid() {
+	if c.validator == nil {
+		return
+	}
+	c.RLock()
+	items := make(map[interface{}]Item, len(c.items))
+	now := c.now().UnixNano()
+	for k, v := range c.items {
+		if v.Expiration <= 0 || now <= v.Expiration {
+			items[k] = v
+		}
+	}
+	c.RUnlock()
+
+	var invalid []interface{}
+	for k, v := range items {
+		if !c.validator(k, v.Object) {
+			invalid = append(invalid, k)
+		}
+	}
+	if len(invalid) == 0 {
+		return
+	}
+
+	c.Lock()
+	defer c.unlock()
+	for _, k := range invalid {
+		v, found := c.items[k]
+		if found && v.Expiration == items[k].Expiration && equal(v.Object, items[k].Object) {
+			c.evict(k, EventDelete)
+		}
 	}
 }
 
@@ -258,86 +1025,532 @@ func (c *cache) DeleteExpired() {
 // item is evicted from the cache. (Including when it is deleted manually, but
 // not when it is overwritten.) Set to nil to disable.
 func (c *cache) OnEvicted(f func(interface{}, interface{})) {
+	for _, s := range c.shards {
+		s.OnEvicted(f)
+	}
 	c.Lock()
 	defer c.Unlock()
 
 	c.onEvicted = f
 }
 
+// Returns the default expiration that Set and the other methods use when given
+// DefaultExpiration: NoExpiration (-1) if items don't expire by default,
+// otherwise a positive duration. A default expiration less than one passed to
+// New or NewFrom is reported as NoExpiration.
+func (c *cache) EffectiveDefaultExpiration() time.Duration {
+	if c.defaultExpiration < 0 {
+		return NoExpiration
+	}
+	return c.defaultExpiration
+}
+
+// Copies all unexpired items in the cache into a new map and returns it.
+func (c *cache) Items() map[interface{}]Item {
+	if c.shards != nil {
+		items := map[interface{}]Item{}
+		for _, s := range c.shards {
+			s.RLock()
+			for k, v := range s.liveItems() {
+				items[k] = v
+			}
+			s.RUnlock()
+		}
+		return items
+	}
+	c.RLock()
+	defer c.RUnlock()
+
+	return c.liveItems()
+}
+
+// Keys returns the keys of all unexpired items in the cache, in no particular
+// order, without copying their values.
+func (c *cache) Keys() []interface{} {
+	if c.shards != nil {
+		var keys []interface{}
+		for _, s := range c.shards {
+			keys = append(keys, s.Keys()...)
+		}
+		return keys
+	}
+	c.RLock()
+	defer c.RUnlock()
+
+	keys := make([]interface{}, 0, len(c.items))
+	now := c.now().UnixNano()
+	for k, v := range c.items {
+		// "Inlining" of Expired
+		if v.Expiration > 0 && now > v.Expiration {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// Range calls f with the key and value of every unexpired item in the cache, in
+// no particular order, until f returns false. The items are copied under the
+// read lock before f is first called, so f sees a consistent snapshot and may
+// access the cache itself. In a cache split with WithShards, each shard is
+// copied separately.
+func (c *cache) Range(f func(k, v interface{}) bool) {
+	var items []KeyAndValue
+	now := c.now().UnixNano()
+	for _, s := range c.parts() {
+		s.RLock()
+		for k, v := range s.items {
+			// "Inlining" of Expired
+			if v.Expiration > 0 && now > v.Expiration {
+				continue
+			}
+			items = append(items, KeyAndValue{k, v.Object})
+		}
+		s.RUnlock()
+	}
+
+	for _, kv := range items {
+		if !f(kv.Key, kv.Value) {
+			return
+		}
+	}
+}
+
 // Returns the number of items in the cache. This may include items that have
 // expired, but have not yet been cleaned up.
 func (c *cache) ItemCount() int {
+	if c.shards != nil {
+		n := 0
+		for _, s := range c.shards {
+			n += s.ItemCount()
+		}
+		return n
+	}
 	c.Lock()
 	defer c.Unlock()
 
 	return len(c.items)
 }
 
+// CountAndSample returns the number of unexpired items and a random sample of
+// up to sampleSize of them, both taken under the same read lock, of every
+// shard at once, so that they describe the same state of the cache.
+func (c *cache) CountAndSample(sampleSize int) (int, []KeyAndValue) {
+	if sampleSize < 0 {
+		sampleSize = 0
+	}
+	parts := c.parts()
+	for _, s := range parts {
+		s.RLock()
+	}
+	count := 0
+	sample := make([]KeyAndValue, 0, sampleSize)
+	for _, s := range parts {
+		sample = s.sample(&count, sample, sampleSize)
+	}
+	for _, s := range parts {
+		s.RUnlock()
+	}
+	return count, sample
+}
+
+// Add the unexpired items to a random sample of up to sampleSize of the count
+// items seen so far, counting them. Must be called with c locked.
+func (c *cache) sample(count *int, sample []KeyAndValue, sampleSize int) []KeyAndValue {
+	now := c.now().UnixNano()
+	for k, v := range c.items {
+		if v.Expiration > 0 && now > v.Expiration {
+			continue
+		}
+		*count++
+		// Reservoir sampling: the i-th item replaces a random one of the
+		// sample with probability sampleSize/i.
+		if len(sample) < sampleSize {
+			sample = append(sample, KeyAndValue{k, v.Object})
+		} else if j := rand.Intn(*count); j < sampleSize {
+			sample[j] = KeyAndValue{k, v.Object}
+		}
+	}
+	return sample
+}
+
+// Partition returns a new cache holding the unexpired items for which belongs
+// returns true, with their remaining expirations. The new cache has the same
+// default expiration, cleanup interval, key function, limits and number of
+// shards as c, but no callbacks, tags or costs. c is left untouched. belongs is called with the
+// read lock held and must not access the cache.
+func (c *cache) Partition(belongs func(key, value interface{}) bool) *Cache {
+	items, ci, opts := c.partition(belongs)
+	return newCacheWithJanitor(c.defaultExpiration, ci, items, opts)
+}
+
+// Returns the items for which belongs returns true, and the cleanup interval
+// and options of a cache like c to hold them.
+func (c *cache) partition(belongs func(key, value interface{}) bool) (map[interface{}]Item, time.Duration, []Option) {
+	items := make(map[interface{}]Item)
+	now := c.now().UnixNano()
+	parts := c.parts()
+	for _, s := range parts {
+		s.RLock()
+		for k, v := range s.items {
+			if v.Expiration > 0 && now > v.Expiration {
+				continue
+			}
+			if belongs(k, v.Object) {
+				items[k] = v
+			}
+		}
+		s.RUnlock()
+	}
+	c.RLock()
+	var ci time.Duration
+	if c.janitor != nil {
+		ci = c.janitor.Interval
+	}
+	newPolicy, tinyLFU, track := c.newPolicy, c.tinyLFU, c.access.track
+	opts := []Option{WithKeyFunc(c.keyFunc), WithMaxEntries(c.maxEntries), WithMaxPerTag(c.maxPerTag), WithMaxCost(c.maxCost), WithClock(c.clock), WithShards(len(parts)), func(c *cache) {
+		c.newPolicy = newPolicy
+		c.tinyLFU = tinyLFU
+		c.access.track = track
+	}}
+	c.RUnlock()
+	return items, ci, opts
+}
+
+// Returns the expiration e as a time, or the zero time if e is zero.
+func expirationTime(e int64) time.Time {
+	if e == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, e)
+}
+
+// Returns the expiration t in nanoseconds, or zero if t is the zero time.
+func expirationNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+// Merge copies the unexpired items of other into c. Keys that only other holds
+// are copied with their expirations. For keys both caches hold, resolve is
+// called with both values and expirations (the zero time meaning no
+// expiration), and the value and expiration it returns are stored. Returns the
+// number of items in c that were added or changed. resolve is called with c's
+// write lock held and must not access c.
+func (c *cache) Merge(other *Cache, resolve func(key, mine, theirs interface{}, mineExp, theirsExp time.Time) (value interface{}, exp time.Time)) int {
+	if other.cache == c {
+		return 0
+	}
+	theirs := make(map[interface{}]Item)
+	now := c.now().UnixNano()
+	for _, s := range other.parts() {
+		s.RLock()
+		for k, v := range s.items {
+			if v.Expiration <= 0 || now <= v.Expiration {
+				theirs[k] = v
+			}
+		}
+		s.RUnlock()
+	}
+	return c.merge(theirs, resolve)
+}
+
+// Merge the given unexpired items into c. See Merge.
+func (c *cache) merge(theirs map[interface{}]Item, resolve func(key, mine, theirs interface{}, mineExp, theirsExp time.Time) (value interface{}, exp time.Time)) int {
+	if c.shards != nil {
+		changed := 0
+		for s, theirs := range c.itemsByShard(theirs) {
+			changed += s.merge(theirs, resolve)
+		}
+		return changed
+	}
+	c.Lock()
+	defer c.unlock()
+
+	changed := 0
+	for k, t := range theirs {
+		m, found := c.get(k)
+		if !found {
+			c.put(k, t)
+			changed++
+			continue
+		}
+		x, exp := resolve(k, m.Object, t.Object, expirationTime(m.Expiration), expirationTime(t.Expiration))
+		e := expirationNano(exp)
+		if e == m.Expiration && equal(x, m.Object) {
+			continue
+		}
+		c.put(k, Item{
+			Object:     x,
+			Expiration: e,
+		})
+		changed++
+	}
+	return changed
+}
+
+// Orders keys as strings: string keys sort before other keys and are compared
+// directly, other keys are compared by their fmt.Sprint representation.
+func naturalKeyLess(a, b interface{}) bool {
+	sa, aok := a.(string)
+	sb, bok := b.(string)
+	if aok != bok {
+		return aok
+	}
+	if !aok {
+		sa, sb = fmt.Sprint(a), fmt.Sprint(b)
+	}
+	return sa < sb
+}
+
+// SortedItems returns a copy of all unexpired items in the cache, sorted by key
+// using less. If less is nil, string keys are sorted lexicographically and
+// come before other keys, which are sorted by their fmt.Sprint representation.
+func (c *cache) SortedItems(less func(a, b interface{}) bool) []KeyAndValue {
+	if less == nil {
+		less = naturalKeyLess
+	}
+	var items []KeyAndValue
+	for k, v := range c.Items() {
+		items = append(items, KeyAndValue{k, v.Object})
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return less(items[i].Key, items[j].Key)
+	})
+	return items
+}
+
+// Record the item count if it is the highest seen. Must be called with the
+// write lock held, after storing an item.
+func (c *cache) updatePeak() {
+	if c.count != nil {
+		c.recount()
+		return
+	}
+	if n := len(c.items); n > c.peakItems {
+		c.peakItems = n
+	}
+}
+
+// Returns the highest number of items the cache has held since it was created
+// or ResetPeakItemCount was last called. Like ItemCount, this may include
+// items that had expired but had not yet been cleaned up.
+func (c *cache) PeakItemCount() int {
+	if c.count != nil {
+		return int(atomic.LoadInt64(&c.count.peak))
+	}
+	c.RLock()
+	defer c.RUnlock()
+
+	return c.peakItems
+}
+
+// Reset the peak item count to the current number of items.
+func (c *cache) ResetPeakItemCount() {
+	if c.count != nil {
+		unlock := c.lockAll()
+		defer unlock()
+
+		atomic.StoreInt64(&c.count.peak, atomic.LoadInt64(&c.count.n))
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+
+	c.peakItems = len(c.items)
+}
+
 // Delete all items from the cache.
 func (c *cache) Flush() {
-	var evictedItems []keyAndValue
-	now := time.Now().UnixNano()
+	if c.shards != nil {
+		for _, s := range c.shards {
+			s.Flush()
+		}
+		return
+	}
+	if c.coalescer != nil {
+		c.coalescer.discardAll()
+	}
+	now := c.now().UnixNano()
 	c.Lock()
+	defer c.unlock()
 	for k, v := range c.items {
 		// "Inlining" of expired
 		if v.Expiration <= 0 || now <= v.Expiration {
-			ov, evicted := c.delete(k)
-			if evicted {
-				evictedItems = append(evictedItems, keyAndValue{k, ov})
-			}
+			c.evict(k, EventFlush)
 		}
 	}
 	c.items = map[interface{}]Item{}
-	c.Unlock()
-	for _, v := range evictedItems {
-		c.onEvicted(v.key, v.value)
+	c.failures = nil
+	c.resetIndex()
+	c.tagIndex = tagIndex{}
+	c.access.reset()
+	c.costs = nil
+	c.totalCost = 0
+	if c.timers != nil {
+		for _, t := range c.timers {
+			t.Stop()
+		}
+		c.timers = map[interface{}]*time.Timer{}
 	}
+	if c.count != nil {
+		c.recount()
+	}
+	c.generation++
 }
 
 type janitor struct {
 	Interval time.Duration
+	ticker   Ticker
 	stop     chan bool
+	stopOnce sync.Once
+}
+
+// Stop the janitor, waiting for a cleanup in progress to finish. Calling it
+// more than once has no effect.
+func (j *janitor) Stop() {
+	j.stopOnce.Do(func() {
+		j.stop <- true
+	})
 }
 
 func (j *janitor) Run(c *cache) {
-	j.stop = make(chan bool)
-	ticker := time.NewTicker(j.Interval)
+	// Changed after every run with WithAdaptiveCleanup.
+	interval := j.Interval
 	for {
 		select {
-		case <-ticker.C:
-			c.DeleteExpired()
+		case <-j.ticker.C():
+			deleted := 0
+			var until time.Duration
+			upcoming := false
+			for _, s := range c.parts() {
+				deleted += s.janitorDeleteExpired()
+				s.deleteInvalid()
+				if c.adaptiveCleanup == nil {
+					continue
+				}
+				if d, found := s.untilNextExpiration(); found && (!upcoming || d < until) {
+					until, upcoming = d, true
+				}
+			}
+			if c.adaptiveCleanup != nil {
+				interval = c.adaptiveCleanup.next(interval, deleted, until, upcoming)
+				j.ticker.Reset(interval)
+			}
 		case <-j.stop:
-			ticker.Stop()
+			j.ticker.Stop()
 			return
 		}
 	}
 }
 
 func stopJanitor(c *Cache) {
-	c.janitor.stop <- true
+	c.close()
+}
+
+// Close stops the janitor and any other background goroutines of the cache,
+// commits writes buffered by WithWriteCoalescing and flushes the queue of
+// WithWriteBehind to its store. The cache remains usable afterwards, but
+// expired items are no longer deleted automatically, and Set writes directly
+// instead of buffering. Calling Close more than once has no effect. Servers
+// should call Close on shutdown instead of relying on the finalizer, which
+// only runs once the cache has been garbage collected.
+func (c *Cache) Close() {
+	runtime.SetFinalizer(c, nil)
+	c.close()
+}
+
+// StopJanitor stops the goroutine that deletes expired items every cleanup
+// interval, leaving other background goroutines running. When it returns, the
+// janitor is not running and will not run again. Expired items are still
+// hidden from lookups and can be deleted with DeleteExpired. Calling
+// StopJanitor more than once, or on a cache without a janitor, has no effect.
+func (c *cache) StopJanitor() {
+	if c.janitor != nil {
+		c.janitor.Stop()
+	}
+}
+
+func (c *cache) close() {
+	c.closeOnce.Do(func() {
+		c.StopJanitor()
+		for _, s := range c.parts() {
+			if s.coalescer != nil {
+				s.stopCoalescing()
+			}
+		}
+		if c.snapshotter != nil {
+			c.stopSnapshots()
+		}
+		if c.writeBehind != nil {
+			c.stopWriteBehind()
+		}
+	})
 }
 
 func runJanitor(c *cache, ci time.Duration) {
 	j := &janitor{
 		Interval: ci,
+		ticker:   c.newTicker(ci),
+		stop:     make(chan bool),
 	}
 	c.janitor = j
 	go j.Run(c)
 }
 
-func newCache(de time.Duration, m map[interface{}]Item) *cache {
-	if de == 0 {
-		de = -1
-	}
+func newCache(de time.Duration, ci time.Duration, m map[interface{}]Item, opts []Option) *cache {
 	c := &cache{
 		defaultExpiration: de,
+		cleanupInterval:   ci,
 		items:             m,
+		events:            &eventHub{},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.defaultExpiration == 0 {
+		c.defaultExpiration = -1
+	}
+	if c.adaptiveCleanup != nil {
+		c.cleanupInterval = c.adaptiveCleanup.clamp(c.cleanupInterval)
+	}
+	c.access.now = c.now
+	if n := c.shardCount(); n > 1 {
+		c.split(n, opts)
+		return c
+	}
+	if c.maxEntries > 0 || c.maxCost > 0 || c.newPolicy != nil {
+		c.access.track = true
 	}
+	if c.newPolicy != nil {
+		c.access.policy = c.newPolicy(c.maxEntries)
+		c.access.newPolicy = func() EvictionPolicy {
+			return c.newPolicy(c.maxEntries)
+		}
+	}
+	if c.tinyLFU && c.maxEntries > 0 {
+		c.access.filter = newTinyLFU(c.maxEntries)
+	}
+	for k, v := range m {
+		c.schedule(k, v.Expiration)
+		c.access.touch(k)
+	}
+	c.updatePeak()
 	return c
 }
 
-func newCacheWithJanitor(de time.Duration, ci time.Duration, m map[interface{}]Item) *Cache {
-	c := newCache(de, m)
+func newCacheWithJanitor(de time.Duration, ci time.Duration, m map[interface{}]Item, opts []Option) *Cache {
+	return startCache(newCache(de, ci, m, opts))
+}
+
+// Start the janitor and other background goroutines c was configured with.
+func startCache(c *cache) *Cache {
+	ci := c.cleanupInterval
+	if c.expvarName != "" {
+		publishStats(c.expvarName, c.Stats)
+	}
 	// This trick ensures that the janitor goroutine (which--granted it
 	// was enabled--is running DeleteExpired on c forever) does not keep
 	// the returned C object from being garbage collected. When it is
@@ -346,19 +1559,49 @@ func newCacheWithJanitor(de time.Duration, ci time.Duration, m map[interface{}]I
 	C := &Cache{c}
 	if ci > 0 {
 		runJanitor(c, ci)
+	}
+	background := ci > 0
+	for _, s := range c.parts() {
+		if s.coalescer != nil {
+			go s.coalescer.run(s)
+			background = true
+		}
+	}
+	if c.snapshotter != nil {
+		go c.snapshotter.run(c)
+	}
+	if c.writeBehind != nil {
+		c.startWriteBehind()
+	}
+	if background || c.snapshotter != nil || c.writeBehind != nil {
 		runtime.SetFinalizer(C, stopJanitor)
 	}
 	return C
 }
 
+// Return a new cache configured with opts. Without WithDefaultExpiration the
+// items in the cache never expire (by default), and without
+// WithCleanupInterval expired items are not deleted from the cache before
+// calling c.DeleteExpired().
+//
+//	c := cache.NewWithOptions(
+//		cache.WithDefaultExpiration(5*time.Minute),
+//		cache.WithCleanupInterval(10*time.Minute),
+//		cache.WithMaxEntries(10000),
+//	)
+func NewWithOptions(opts ...Option) *Cache {
+	return newCacheWithJanitor(0, 0, make(map[interface{}]Item), opts)
+}
+
 // Return a new cache with a given default expiration duration and cleanup
 // interval. If the expiration duration is less than one (or NoExpiration),
 // the items in the cache never expire (by default), and must be deleted
 // manually. If the cleanup interval is less than one, expired items are not
-// deleted from the cache before calling c.DeleteExpired().
-func New(defaultExpiration, cleanupInterval time.Duration) *Cache {
+// deleted from the cache before calling c.DeleteExpired(). Optional behavior
+// can be enabled by passing Options.
+func New(defaultExpiration, cleanupInterval time.Duration, opts ...Option) *Cache {
 	items := make(map[interface{}]Item)
-	return newCacheWithJanitor(defaultExpiration, cleanupInterval, items)
+	return newCacheWithJanitor(defaultExpiration, cleanupInterval, items, opts)
 }
 
 // Return a new cache with a given default expiration duration and cleanup
@@ -375,13 +1618,19 @@ func New(defaultExpiration, cleanupInterval time.Duration) *Cache {
 //
 // Only the cache's methods synchronize access to this map, so it is not
 // recommended to keep any references to the map around after creating a cache.
-// If need be, the map can be accessed at a later point using c.Items() (subject
-// to the same caveat.)
+// If need be, a copy of the unexpired items can be retrieved at a later point
+// using c.Items().
 //
 // Note regarding serialization: When using e.g. gob, make sure to
 // gob.Register() the individual types stored in the cache before encoding a
 // map retrieved with c.Items(), and to register those same types before
 // decoding a blob containing an items map.
-func NewFrom(defaultExpiration, cleanupInterval time.Duration, items map[interface{}]Item) *Cache {
-	return newCacheWithJanitor(defaultExpiration, cleanupInterval, items)
+//
+// When WithKeyFunc is used, the keys of items must already be the converted
+// strings.
+//
+// Unlike New, NewFrom doesn't split the cache into shards unless WithShards
+// is given, in which case the items are moved into the shards' own maps.
+func NewFrom(defaultExpiration, cleanupInterval time.Duration, items map[interface{}]Item, opts ...Option) *Cache {
+	return newCacheWithJanitor(defaultExpiration, cleanupInterval, items, append([]Option{WithShards(1)}, opts...))
 }
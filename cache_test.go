@@ -557,12 +557,12 @@ func benchmarkCacheGetManyConcurrent(b *testing.B, exp time.Duration) {
 	wg := new(sync.WaitGroup)
 	wg.Add(n)
 	for _, v := range keys {
-		go func() {
+		go func(v string) {
 			for j := 0; j < each; j++ {
 				tc.Get(v)
 			}
 			wg.Done()
-		}()
+		}(v)
 	}
 	b.StartTimer()
 	wg.Wait()
@@ -624,12 +624,12 @@ func BenchmarkRWMutexMapSetDelete(b *testing.B) {
 
 func BenchmarkCacheSetDeleteSingleLock(b *testing.B) {
 	b.StopTimer()
-	tc := New(DefaultExpiration, 0)
+	tc := New(DefaultExpiration, 0, WithShards(1))
 	b.StartTimer()
 	for i := 0; i < b.N; i++ {
 		tc.Lock()
 		tc.set("foo", "bar", DefaultExpiration)
-		tc.delete("foo")
+		tc.delete("foo", EventDelete)
 		tc.Unlock()
 	}
 }
@@ -649,7 +649,7 @@ func BenchmarkRWMutexMapSetDeleteSingleLock(b *testing.B) {
 
 func BenchmarkDeleteExpiredLoop(b *testing.B) {
 	b.StopTimer()
-	tc := New(5*time.Minute, 0)
+	tc := New(5*time.Minute, 0, WithShards(1))
 	tc.Lock()
 	for i := 0; i < 100000; i++ {
 		tc.set(strconv.Itoa(i), "bar", DefaultExpiration)
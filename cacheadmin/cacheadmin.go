@@ -0,0 +1,129 @@
+// Package cacheadmin provides an HTTP handler for looking into a live cache.
+//
+//	c := cache.New(5*time.Minute, 10*time.Minute)
+//	http.Handle("/debug/cache/", cacheadmin.Handler(c, authorize))
+//
+// The handler serves these endpoints, under any path prefix:
+//
+//	GET  .../keys?prefix=p  the keys of the unexpired items, as a sorted JSON
+//	                        array of strings, optionally only those starting
+//	                        with p
+//	GET  .../item?key=k     the metadata of the item under the string key k as
+//	                        a JSON object: its value's type, expiration, TTL,
+//	                        age, number of accesses and cost; not its value
+//	POST .../delete?key=k   delete the item under the string key k
+//	POST .../flush          delete all items
+//
+// Keys that aren't strings are listed as formatted by fmt.Sprint, and can't be
+// looked up or deleted.
+package cacheadmin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rumsrami/cache"
+)
+
+// A Cache is the cache the handler looks into, such as a *cache.Cache.
+type Cache interface {
+	Keys() []interface{}
+	Inspect(k interface{}) (cache.InspectResult, bool)
+	Delete(k interface{})
+	Flush()
+}
+
+// Metadata describes an item, as served by the item endpoint.
+type Metadata struct {
+	Key        string     `json:"key"`
+	Type       string     `json:"type"`
+	Expiration *time.Time `json:"expiration,omitempty"`
+	// The remaining lifetime in seconds, or -1 if the item never expires.
+	TTL float64 `json:"ttl"`
+	// The time since the value was stored, in seconds.
+	Age      float64 `json:"age"`
+	Accesses uint64  `json:"accesses"`
+	Cost     int64   `json:"cost"`
+}
+
+// Handler returns a handler serving the endpoints described in the package
+// documentation for c. Every request is first passed to authorize; if it
+// returns false, the request is rejected with 403 Forbidden. authorize must
+// not be nil.
+func Handler(c Cache, authorize func(r *http.Request) bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !authorize(r) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		endpoint := path.Base(r.URL.Path)
+		method := http.MethodGet
+		if endpoint == "delete" || endpoint == "flush" {
+			method = http.MethodPost
+		}
+		switch endpoint {
+		case "keys", "item", "delete", "flush":
+			if r.Method != method {
+				w.Header().Set("Allow", method)
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+		default:
+			http.NotFound(w, r)
+			return
+		}
+
+		switch endpoint {
+		case "keys":
+			prefix := r.URL.Query().Get("prefix")
+			keys := []string{}
+			for _, k := range c.Keys() {
+				if s := fmt.Sprint(k); strings.HasPrefix(s, prefix) {
+					keys = append(keys, s)
+				}
+			}
+			sort.Strings(keys)
+			writeJSON(w, keys)
+		case "item":
+			key := r.URL.Query().Get("key")
+			item, found := c.Inspect(key)
+			if !found {
+				http.NotFound(w, r)
+				return
+			}
+			writeJSON(w, metadata(key, item))
+		case "delete":
+			c.Delete(r.URL.Query().Get("key"))
+			w.WriteHeader(http.StatusNoContent)
+		case "flush":
+			c.Flush()
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+}
+
+func metadata(key string, item cache.InspectResult) Metadata {
+	m := Metadata{
+		Key:      key,
+		Type:     fmt.Sprintf("%T", item.Value),
+		TTL:      -1,
+		Age:      item.Age.Seconds(),
+		Accesses: item.Accesses,
+		Cost:     item.Cost,
+	}
+	if item.TTL != cache.NoExpiration {
+		m.Expiration = &item.Expiration
+		m.TTL = item.TTL.Seconds()
+	}
+	return m
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,74 @@
+package cacheadmin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rumsrami/cache"
+)
+
+func do(h http.Handler, method, url string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(method, url, nil))
+	return w
+}
+
+func TestHandler(t *testing.T) {
+	c := cache.New(cache.DefaultExpiration, 0)
+	c.Set("user:1", "a", time.Hour)
+	c.Set("user:2", "b", cache.NoExpiration)
+	c.SetWithCost("session:1", []byte("xyz"), 3, cache.NoExpiration)
+	h := Handler(c, func(r *http.Request) bool {
+		return r.Header.Get("X-Forwarded-For") == ""
+	})
+
+	w := do(h, http.MethodGet, "/debug/cache/keys?prefix=user:")
+	var keys []string
+	if err := json.Unmarshal(w.Body.Bytes(), &keys); err != nil || len(keys) != 2 || keys[0] != "user:1" {
+		t.Errorf("keys returned %s (%v)", w.Body, err)
+	}
+
+	w = do(h, http.MethodGet, "/debug/cache/item?key=session:1")
+	var m Metadata
+	if err := json.Unmarshal(w.Body.Bytes(), &m); err != nil {
+		t.Fatalf("item returned %s (%v)", w.Body, err)
+	}
+	if m.Type != "[]uint8" || m.Cost != 3 || m.TTL != -1 || m.Expiration != nil {
+		t.Errorf("item returned %+v", m)
+	}
+	w = do(h, http.MethodGet, "/debug/cache/item?key=user:1")
+	json.Unmarshal(w.Body.Bytes(), &m)
+	if m.TTL <= 3500 || m.Expiration == nil {
+		t.Errorf("item returned %+v for an expiring item", m)
+	}
+	if w := do(h, http.MethodGet, "/debug/cache/item?key=missing"); w.Code != http.StatusNotFound {
+		t.Errorf("item of a missing key returned %d", w.Code)
+	}
+
+	if w := do(h, http.MethodGet, "/debug/cache/delete?key=user:1"); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET delete returned %d", w.Code)
+	}
+	do(h, http.MethodPost, "/debug/cache/delete?key=user:1")
+	if _, found := c.Get("user:1"); found {
+		t.Error("delete did not delete the item")
+	}
+	do(h, http.MethodPost, "/debug/cache/flush")
+	if n := c.ItemCount(); n != 0 {
+		t.Errorf("Item count after flush is %d", n)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/debug/cache/flush", nil)
+	r.Header.Set("X-Forwarded-For", "10.0.0.1")
+	w = httptest.NewRecorder()
+	c.Set("a", 1, cache.NoExpiration)
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusForbidden || c.ItemCount() != 1 {
+		t.Errorf("Unauthorized flush returned %d", w.Code)
+	}
+	if w := do(h, http.MethodGet, "/debug/cache/unknown"); w.Code != http.StatusNotFound {
+		t.Errorf("Unknown endpoint returned %d", w.Code)
+	}
+}
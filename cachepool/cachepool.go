@@ -0,0 +1,287 @@
+// Package cachepool spreads keys over a pool of remote cache nodes, such as
+// servers of the grpccache or memcached packages, with consistent hashing, so
+// that a fleet of processes can form a simple distributed cache.
+//
+//	p := cachepool.New()
+//	p.Add("10.0.0.1:11211", memcached.NewClient("10.0.0.1:11211"))
+//	p.Add("10.0.0.2:11211", memcached.NewClient("10.0.0.2:11211"))
+//	err := p.Set(ctx, "alice", data, time.Minute)
+//
+// Each key is stored on one node. Adding or removing a node only moves the
+// keys of about one node's share of the ring. A node that fails several
+// requests in a row is taken out of the ring for a while, and its keys go to
+// the next node in the meantime. A Pool has the methods of a cache.L2, so it
+// can be the second tier of a cache.Tiered.
+package cachepool
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// A Node is a remote cache, such as a *grpccache.Client or a
+// *memcached.Client. If it also has a method
+//
+//	Unavailable(err error) bool
+//
+// only the errors for which it returns true, meaning that the node could not
+// be reached or did not answer, count as failures for WithFailover; others,
+// such as a reply that a value is too large, don't take the node out. The
+// clients of both packages have it. Otherwise every error counts.
+type Node interface {
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, key string) error
+}
+
+// ErrNoNodes is returned when the pool has no node available.
+var ErrNoNodes = errors.New("cachepool: no nodes available")
+
+// An Option configures a Pool.
+type Option func(*Pool)
+
+// WithReplicas places each node at n points of the ring instead of 100. More
+// points spread keys more evenly at the cost of memory.
+func WithReplicas(n int) Option {
+	return func(p *Pool) {
+		if n > 0 {
+			p.replicas = n
+		}
+	}
+}
+
+// WithFailover takes a node out of the ring for cooldown after it fails
+// threshold requests in a row, instead of after 3 failures for 30 seconds.
+// Once the cooldown has passed, the node gets requests again; one more failure
+// takes it out again. Requests that fail because their context is done don't
+// count, nor do error replies from nodes that tell them apart (see Node). A
+// threshold less than one disables failover.
+func WithFailover(threshold int, cooldown time.Duration) Option {
+	return func(p *Pool) {
+		p.threshold = threshold
+		p.cooldown = cooldown
+	}
+}
+
+// A Pool spreads keys over its nodes. It is safe for concurrent use.
+type Pool struct {
+	replicas  int
+	threshold int
+	cooldown  time.Duration
+	// The time, for tests.
+	now func() time.Time
+
+	mu    sync.RWMutex
+	nodes map[string]*member
+	// The points of the ring, sorted by hash.
+	ring []point
+}
+
+// A node of the pool and its health.
+type member struct {
+	name string
+	node Node
+
+	mu       sync.Mutex
+	failures int
+	down     time.Time
+}
+
+type point struct {
+	hash uint64
+	m    *member
+}
+
+// New returns an empty pool.
+func New(opts ...Option) *Pool {
+	p := &Pool{
+		replicas:  100,
+		threshold: 3,
+		cooldown:  30 * time.Second,
+		now:       time.Now,
+		nodes:     map[string]*member{},
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// hash returns the position of s on the ring. FNV-1a barely changes the high
+// bits of the hash when only the last bytes differ, as with "key1" and "key2",
+// so the hash is mixed to spread such strings over the ring.
+func hash(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
+
+// Add adds node to the pool under name, which places it on the ring and
+// should be the same in all processes, such as its address. A node already
+// added under name is replaced.
+func (p *Pool) Add(name string, node Node) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.remove(name)
+	m := &member{name: name, node: node}
+	p.nodes[name] = m
+	for i := 0; i < p.replicas; i++ {
+		p.ring = append(p.ring, point{hash(strconv.Itoa(i) + "-" + name), m})
+	}
+	sort.Slice(p.ring, func(i, j int) bool {
+		return p.ring[i].hash < p.ring[j].hash
+	})
+}
+
+// Remove removes the node added under name. Its keys go to the other nodes.
+func (p *Pool) Remove(name string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.remove(name)
+}
+
+func (p *Pool) remove(name string) {
+	m, found := p.nodes[name]
+	if !found {
+		return
+	}
+	delete(p.nodes, name)
+	ring := p.ring[:0]
+	for _, pt := range p.ring {
+		if pt.m != m {
+			ring = append(ring, pt)
+		}
+	}
+	p.ring = ring
+}
+
+// Nodes returns the names of the nodes in the pool, sorted.
+func (p *Pool) Nodes() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	names := make([]string, 0, len(p.nodes))
+	for name := range p.nodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NodeFor returns the name of the node that key goes to, skipping nodes out of
+// the ring after failing, and false if no node is available.
+func (p *Pool) NodeFor(key string) (string, bool) {
+	m := p.pick(key)
+	if m == nil {
+		return "", false
+	}
+	return m.name, true
+}
+
+// Returns the first available node at or after the hash of key on the ring.
+func (p *Pool) pick(key string) *member {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if len(p.ring) == 0 {
+		return nil
+	}
+	h := hash(key)
+	start := sort.Search(len(p.ring), func(i int) bool {
+		return p.ring[i].hash >= h
+	})
+	now := p.now()
+	seen := map[*member]bool{}
+	for i := 0; i < len(p.ring) && len(seen) < len(p.nodes); i++ {
+		m := p.ring[(start+i)%len(p.ring)].m
+		if seen[m] {
+			continue
+		}
+		seen[m] = true
+		if m.available(now) {
+			return m
+		}
+	}
+	return nil
+}
+
+func (m *member) available(now time.Time) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return !now.Before(m.down)
+}
+
+// Record the outcome of a request to m.
+func (p *Pool) record(ctx context.Context, m *member, err error) {
+	if err != nil && ctx.Err() != nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err == nil || !unavailable(m.node, err) {
+		m.failures = 0
+		return
+	}
+	m.failures++
+	if p.threshold > 0 && m.failures >= p.threshold {
+		m.down = p.now().Add(p.cooldown)
+		// Once the cooldown has passed, one more failure takes the node
+		// out again.
+		m.failures = p.threshold - 1
+	}
+}
+
+// Reports whether err, returned by node, means that the node failed rather than
+// refused the request.
+func unavailable(node Node, err error) bool {
+	if n, ok := node.(interface{ Unavailable(err error) bool }); ok {
+		return n.Unavailable(err)
+	}
+	return true
+}
+
+// Get returns the value stored under key on its node, and whether it was
+// found.
+func (p *Pool) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	m := p.pick(key)
+	if m == nil {
+		return nil, false, ErrNoNodes
+	}
+	value, found, err := m.node.Get(ctx, key)
+	p.record(ctx, m, err)
+	return value, found, err
+}
+
+// Set stores value under key on its node for ttl, as understood by the node.
+func (p *Pool) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	m := p.pick(key)
+	if m == nil {
+		return ErrNoNodes
+	}
+	err := m.node.Set(ctx, key, value, ttl)
+	p.record(ctx, m, err)
+	return err
+}
+
+// Delete deletes key from its node.
+func (p *Pool) Delete(ctx context.Context, key string) error {
+	m := p.pick(key)
+	if m == nil {
+		return ErrNoNodes
+	}
+	err := m.node.Delete(ctx, key)
+	p.record(ctx, m, err)
+	return err
+}
@@ -0,0 +1,247 @@
+package cachepool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rumsrami/cache"
+	"github.com/rumsrami/cache/memcached"
+)
+
+var _ cache.L2 = (*Pool)(nil)
+
+// A node holding values in a map.
+type mapNode struct {
+	mu     sync.Mutex
+	values map[string][]byte
+	err    error
+}
+
+func newMapNode() *mapNode {
+	return &mapNode{values: map[string][]byte{}}
+}
+
+func (n *mapNode) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.err != nil {
+		return nil, false, n.err
+	}
+	v, found := n.values[key]
+	return v, found, nil
+}
+
+func (n *mapNode) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.err != nil {
+		return n.err
+	}
+	n.values[key] = value
+	return nil
+}
+
+func (n *mapNode) Delete(ctx context.Context, key string) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.err != nil {
+		return n.err
+	}
+	delete(n.values, key)
+	return nil
+}
+
+func TestPool(t *testing.T) {
+	ctx := context.Background()
+	p := New()
+	if _, _, err := p.Get(ctx, "a"); err != ErrNoNodes {
+		t.Errorf("got %v from an empty pool, want ErrNoNodes", err)
+	}
+	nodes := map[string]*mapNode{}
+	for _, name := range []string{"n1", "n2", "n3"} {
+		nodes[name] = newMapNode()
+		p.Add(name, nodes[name])
+	}
+	if got := fmt.Sprint(p.Nodes()); got != "[n1 n2 n3]" {
+		t.Errorf("got nodes %s", got)
+	}
+
+	const keys = 3000
+	before := map[string]string{}
+	for i := 0; i < keys; i++ {
+		k := fmt.Sprint("key", i)
+		if err := p.Set(ctx, k, []byte(k), 0); err != nil {
+			t.Fatal(err)
+		}
+		before[k], _ = p.NodeFor(k)
+		if _, found := nodes[before[k]].values[k]; !found {
+			t.Fatalf("%s is not on %s", k, before[k])
+		}
+	}
+	for name, n := range nodes {
+		if len(n.values) < keys/6 {
+			t.Errorf("%s has %d of %d keys", name, len(n.values), keys)
+		}
+	}
+	for k := range before {
+		if v, found, err := p.Get(ctx, k); err != nil || !found || string(v) != k {
+			t.Fatalf("got %q, %v, %v for %s", v, found, err, k)
+		}
+	}
+
+	// Removing a node only moves its keys.
+	p.Remove("n2")
+	for k, was := range before {
+		now, _ := p.NodeFor(k)
+		if was != "n2" && now != was {
+			t.Fatalf("%s moved from %s to %s", k, was, now)
+		}
+		if now == "n2" {
+			t.Fatalf("%s still goes to a removed node", k)
+		}
+	}
+	if err := p.Delete(ctx, "key1"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFailover(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	p := New(WithFailover(2, time.Minute))
+	p.now = func() time.Time { return now }
+	a, b := newMapNode(), newMapNode()
+	p.Add("a", a)
+	p.Add("b", b)
+
+	// A key that goes to a.
+	var k string
+	for i := 0; ; i++ {
+		k = fmt.Sprint("key", i)
+		if name, _ := p.NodeFor(k); name == "a" {
+			break
+		}
+	}
+	a.err = errors.New("down")
+	for i := 0; i < 2; i++ {
+		if err := p.Set(ctx, k, []byte("v"), 0); err != a.err {
+			t.Fatalf("got %v, want a's error", err)
+		}
+	}
+	if name, _ := p.NodeFor(k); name != "b" {
+		t.Fatalf("%s goes to %s after a failed, want b", k, name)
+	}
+	if err := p.Set(ctx, k, []byte("v"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, found := b.values[k]; !found {
+		t.Error("the key didn't go to b")
+	}
+
+	// After the cooldown, a gets requests again, and one failure takes it
+	// out again.
+	now = now.Add(time.Minute)
+	if name, _ := p.NodeFor(k); name != "a" {
+		t.Fatalf("%s goes to %s after the cooldown, want a", k, name)
+	}
+	p.Get(ctx, k)
+	if name, _ := p.NodeFor(k); name != "b" {
+		t.Fatalf("%s goes to %s after a failed again, want b", k, name)
+	}
+
+	// Canceled requests don't count.
+	now = now.Add(time.Minute)
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	p.Get(canceled, k)
+	if name, _ := p.NodeFor(k); name != "a" {
+		t.Errorf("%s goes to %s after a canceled request, want a", k, name)
+	}
+
+	b.err = a.err
+	p.Get(ctx, k)
+	p.Get(ctx, "other")
+	p.Get(ctx, "other")
+	if _, _, err := p.Get(ctx, k); err != ErrNoNodes && err != a.err {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestFailoverErrorReplies(t *testing.T) {
+	ctx := context.Background()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := memcached.NewServer(cache.New(cache.NoExpiration, 0))
+	s.MaxItemSize = 1
+	go s.Serve(l)
+	defer s.Close()
+	client := memcached.NewClient(l.Addr().String())
+	defer client.Close()
+	p := New(WithFailover(1, time.Minute))
+	p.Add("a", client)
+
+	// A value too large for the server is refused, but the node is fine.
+	for i := 0; i < 3; i++ {
+		var se *memcached.ServerError
+		if err := p.Set(ctx, "k", []byte("too large"), 0); !errors.As(err, &se) {
+			t.Fatalf("got %v, want a server error", err)
+		}
+	}
+	if name, _ := p.NodeFor("k"); name != "a" {
+		t.Errorf("node was taken out after error replies")
+	}
+	if err := p.Set(ctx, "k", []byte("v"), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	s.Close()
+	p.Get(ctx, "k")
+	if _, found := p.NodeFor("k"); found {
+		t.Error("node was not taken out after failing")
+	}
+}
+
+func TestMemcachedNodes(t *testing.T) {
+	ctx := context.Background()
+	p := New()
+	caches := map[string]*cache.Cache{}
+	for i := 0; i < 2; i++ {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := cache.New(cache.NoExpiration, 0)
+		s := memcached.NewServer(c)
+		go s.Serve(l)
+		defer s.Close()
+		client := memcached.NewClient(l.Addr().String())
+		defer client.Close()
+		caches[l.Addr().String()] = c
+		p.Add(l.Addr().String(), client)
+	}
+	for i := 0; i < 20; i++ {
+		k := fmt.Sprint("key", i)
+		if err := p.Set(ctx, k, []byte(k), time.Minute); err != nil {
+			t.Fatal(err)
+		}
+		name, _ := p.NodeFor(k)
+		if _, found := caches[name].Get(k); !found {
+			t.Errorf("%s is not on %s", k, name)
+		}
+		if v, found, err := p.Get(ctx, k); err != nil || !found || string(v) != k {
+			t.Errorf("got %q, %v, %v for %s", v, found, err, k)
+		}
+	}
+	for _, c := range caches {
+		if c.ItemCount() == 0 {
+			t.Error("a node got no keys")
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package cache
+
+import "sync/atomic"
+
+// WithMaxEntries limits the cache to max items. When the cache is full,
+// storing a new key evicts the least recently used item, or the one picked by
+// the policy set with an option such as WithLFUEviction or
+// WithEvictionPolicy, and calls the eviction callbacks for it with
+// ReasonCapacity (or ReasonExpired, if it had expired). With
+// WithTinyLFUAdmission, a new key may instead not be stored. A max less than
+// one means no limit.
+func WithMaxEntries(max int) Option {
+	return func(c *cache) {
+		c.maxEntries = max
+	}
+}
+
+type capacityPressure struct {
+	threshold float64
+	f         func(float64)
+	armed     bool
+}
+
+// OnCapacityPressure sets an (optional) function that is called with the
+// current utilization (item count divided by the limit set with
+// WithMaxEntries) when it rises to threshold or above. The function is called
+// once per crossing: it isn't called again until utilization has dropped below
+// threshold and risen back up. It does nothing for caches without a limit. Set
+// f to nil to disable.
+func (c *cache) OnCapacityPressure(threshold float64, f func(utilization float64)) {
+	c.Lock()
+	defer c.Unlock()
+
+	if f == nil {
+		c.pressure = nil
+		return
+	}
+	c.pressure = &capacityPressure{
+		threshold: threshold,
+		f:         f,
+		armed:     true,
+	}
+}
+
+// Returns the pressure callback and utilization to report if the cache just
+// crossed its pressure threshold. Must be called with the write lock held.
+func (c *cache) checkPressure() (func(float64), float64) {
+	p := c.pressure
+	if p == nil || c.maxEntries <= 0 {
+		return nil, 0
+	}
+	u := float64(len(c.items)) / float64(c.maxEntries)
+	if u < p.threshold {
+		p.armed = true
+		return nil, 0
+	}
+	if !p.armed {
+		return nil, 0
+	}
+	p.armed = false
+	return p.f, u
+}
+
+// Make room for a new key k if the cache is full. Returns false if the
+// admission filter rejects k instead. Must be called with the write lock held,
+// before k is stored.
+func (c *cache) makeRoom(k interface{}) bool {
+	if c.maxEntries <= 0 {
+		return true
+	}
+	c.access.count(k)
+	if len(c.items) < c.maxEntries {
+		return true
+	}
+	if _, found := c.items[k]; found {
+		return true
+	}
+	if victim, expired, found := c.victim(); found && !expired && !c.access.admit(k, victim) {
+		atomic.AddUint64(&c.stats.rejections, 1)
+		return false
+	}
+	c.evictN(len(c.items) - c.maxEntries + 1)
+	return true
+}
+
+// Evict up to n items and return the number evicted. Must be called with the
+// write lock held.
+func (c *cache) evictN(n int) int {
+	evicted := 0
+	for ; evicted < n && len(c.items) > 0; evicted++ {
+		victim, expired, found := c.victim()
+		if !found {
+			break
+		}
+		if expired {
+			c.evict(victim, EventExpire)
+		} else {
+			c.evict(victim, EventEvict)
+		}
+	}
+	return evicted
+}
+
+// Pick the item to evict, and report whether it has expired. Returns false if
+// all items are pinned.
+func (c *cache) victim() (k interface{}, expired bool, found bool) {
+	k, found = c.access.victim()
+	if _, ok := c.items[k]; !found || !ok || c.access.isPinned(k) {
+		// Items not tracked in the access order, which shouldn't happen,
+		// or a policy picking a key that isn't in the cache or is pinned.
+		found = false
+		for key := range c.items {
+			if !c.access.isPinned(key) {
+				k, found = key, true
+				break
+			}
+		}
+		if !found {
+			return nil, false, false
+		}
+	}
+	v := c.items[k]
+	return k, v.Expiration > 0 && c.now().UnixNano() > v.Expiration, true
+}
+
+// Remove k and queue it for the eviction callback, which is run by unlock.
+func (c *cache) evict(k interface{}, op EventOp) {
+	v, evicted := c.delete(k, op)
+	if evicted {
+		c.queue(eviction{k, v, reasonFor(op)})
+	}
+}
+
+// OnMemoryPressure sets an (optional) function that decides how many items to
+// evict when TriggerMemoryPressure is called. Set to nil to disable.
+func (c *cache) OnMemoryPressure(f func() int) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.onMemoryPressure = f
+}
+
+// TriggerMemoryPressure asks the function set with OnMemoryPressure how many
+// items to shed and evicts that many, in the order they would be evicted when
+// the cache is full. Call it from your own memory monitor. The function is
+// called without holding the lock, so it may inspect the cache. Returns the
+// number of items evicted.
+func (c *cache) TriggerMemoryPressure() int {
+	c.RLock()
+	f := c.onMemoryPressure
+	c.RUnlock()
+	if f == nil {
+		return 0
+	}
+	n := f()
+	if n <= 0 {
+		return 0
+	}
+
+	unlock := c.lockAll()
+	defer unlock()
+
+	if c.shards == nil {
+		return c.evictN(n)
+	}
+	victim := func(s *cache) (interface{}, bool) {
+		k, _, found := s.victim()
+		return k, found
+	}
+	evicted := 0
+	for ; evicted < n; evicted++ {
+		s, k, found := c.nextVictim(victim, true)
+		if !found {
+			break
+		}
+		if s.expired(s.items[k]) {
+			s.evict(k, EventExpire)
+		} else {
+			s.evict(k, EventEvict)
+		}
+	}
+	return evicted
+}
@@ -0,0 +1,122 @@
+package cache
+
+import "testing"
+
+func TestMaxEntries(t *testing.T) {
+	tc := New(DefaultExpiration, 0, WithMaxEntries(10))
+	evicted := 0
+	tc.OnEvicted(func(k interface{}, v interface{}) {
+		evicted++
+	})
+	for i := 0; i < 20; i++ {
+		tc.Set(i, i, DefaultExpiration)
+	}
+	if n := tc.ItemCount(); n != 10 {
+		t.Errorf("Item count is %d, want 10", n)
+	}
+	if evicted != 10 {
+		t.Errorf("%d items were evicted, want 10", evicted)
+	}
+	if _, found := tc.Get(19); !found {
+		t.Error("The last item stored was evicted")
+	}
+}
+
+func TestCapacityPressure(t *testing.T) {
+	tc := New(DefaultExpiration, 0, WithMaxEntries(10))
+	var fired []float64
+	tc.OnCapacityPressure(0.8, func(u float64) {
+		fired = append(fired, u)
+	})
+
+	for i := 0; i < 20; i++ {
+		tc.Set(i, i, DefaultExpiration)
+	}
+	if len(fired) != 1 {
+		t.Fatalf("Pressure callback fired %d times, want 1", len(fired))
+	}
+	if fired[0] != 0.8 {
+		t.Errorf("Reported utilization is %v, want 0.8", fired[0])
+	}
+
+	tc.Flush()
+	for i := 0; i < 5; i++ {
+		tc.Set(i, i, DefaultExpiration)
+	}
+	if len(fired) != 1 {
+		t.Fatalf("Pressure callback fired %d times below the threshold", len(fired))
+	}
+	for i := 20; i < 30; i++ {
+		tc.Set(i, i, DefaultExpiration)
+	}
+	if len(fired) != 2 {
+		t.Errorf("Pressure callback fired %d times after a second crossing, want 2", len(fired))
+	}
+}
+
+func TestMemoryPressure(t *testing.T) {
+	tc := New(DefaultExpiration, 0)
+	if n := tc.TriggerMemoryPressure(); n != 0 {
+		t.Errorf("Evicted %d items without a pressure function", n)
+	}
+	for i := 0; i < 20; i++ {
+		tc.Set(i, i, DefaultExpiration)
+	}
+	evicted := 0
+	tc.OnEvicted(func(k interface{}, v interface{}) {
+		evicted++
+	})
+	tc.OnMemoryPressure(func() int {
+		return tc.ItemCount() / 4
+	})
+
+	if n := tc.TriggerMemoryPressure(); n != 5 {
+		t.Errorf("TriggerMemoryPressure evicted %d items, want 5", n)
+	}
+	if n := tc.ItemCount(); n != 15 {
+		t.Errorf("Item count is %d, want 15", n)
+	}
+	if evicted != 5 {
+		t.Errorf("OnEvicted was called %d times, want 5", evicted)
+	}
+}
+
+func TestMaxEntriesLRU(t *testing.T) {
+	tc := New(DefaultExpiration, 0, WithMaxEntries(3))
+	type evictedItem struct {
+		k      interface{}
+		reason EvictionReason
+	}
+	var evicted []evictedItem
+	tc.OnEvictedWithReason(func(k interface{}, v interface{}, reason EvictionReason) {
+		evicted = append(evicted, evictedItem{k, reason})
+	})
+	tc.Set("a", 1, DefaultExpiration)
+	tc.Set("b", 2, DefaultExpiration)
+	tc.Set("c", 3, DefaultExpiration)
+	tc.Get("a")
+	tc.Set("d", 4, DefaultExpiration)
+	tc.Set("c", 5, DefaultExpiration)
+	tc.Set("e", 6, DefaultExpiration)
+	tc.Delete("e")
+
+	want := []evictedItem{
+		{"b", ReasonCapacity},
+		{"c", ReasonReplaced},
+		{"a", ReasonCapacity},
+		{"e", ReasonDeleted},
+	}
+	if len(evicted) != len(want) {
+		t.Fatalf("Evicted %v, want %v", evicted, want)
+	}
+	for i := range want {
+		if evicted[i] != want[i] {
+			t.Errorf("Eviction %d was %v, want %v", i, evicted[i], want[i])
+		}
+	}
+	for _, k := range []string{"c", "d"} {
+		if _, found := tc.Get(k); !found {
+			t.Errorf("Did not find %s", k)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// A Clock tells the time to a cache. The cache uses it to compute and check
+// expirations, and to run its janitor, so that tests can control time with a
+// FakeClock instead of sleeping. Eager expiration (WithEagerExpiration), write
+// coalescing and snapshots always use real timers.
+type Clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) Ticker
+}
+
+// A Ticker delivers ticks like a time.Ticker.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+	Reset(d time.Duration)
+}
+
+// WithClock makes the cache use clock instead of the system clock.
+func WithClock(clock Clock) Option {
+	return func(c *cache) {
+		c.clock = clock
+	}
+}
+
+// Returns the current time according to the cache's clock.
+func (c *cache) now() time.Time {
+	if c.clock == nil {
+		return time.Now()
+	}
+	return c.clock.Now()
+}
+
+// Returns a ticker from the cache's clock.
+func (c *cache) newTicker(d time.Duration) Ticker {
+	if c.clock == nil {
+		return realTicker{time.NewTicker(d)}
+	}
+	return c.clock.NewTicker(d)
+}
+
+// Returns true if the item has expired according to the cache's clock.
+func (c *cache) expired(item Item) bool {
+	return item.ExpiredAt(c.now())
+}
+
+type realTicker struct {
+	t *time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time   { return t.t.C }
+func (t realTicker) Stop()                 { t.t.Stop() }
+func (t realTicker) Reset(d time.Duration) { t.t.Reset(d) }
+
+// A FakeClock is a Clock that only moves when it is told to. Tickers created
+// from it tick when Advance moves the time past their next tick; like those of
+// a time.Ticker, ticks are dropped when the receiver isn't keeping up.
+type FakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers []*fakeTicker
+}
+
+// NewFakeClock returns a FakeClock set to now.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now returns the clock's current time.
+func (f *FakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// NewTicker returns a ticker that ticks every d of fake time.
+func (f *FakeClock) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("non-positive interval for FakeClock.NewTicker")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t := &fakeTicker{
+		clock:  f,
+		c:      make(chan time.Time, 1),
+		period: d,
+		next:   f.now.Add(d),
+	}
+	f.tickers = append(f.tickers, t)
+	return t
+}
+
+// Advance moves the clock forward by d, firing the tickers that are due.
+func (f *FakeClock) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+	for _, t := range f.tickers {
+		if t.stopped || t.next.After(f.now) {
+			continue
+		}
+		select {
+		case t.c <- f.now:
+		default:
+		}
+		for !t.next.After(f.now) {
+			t.next = t.next.Add(t.period)
+		}
+	}
+}
+
+type fakeTicker struct {
+	clock   *FakeClock
+	c       chan time.Time
+	period  time.Duration
+	next    time.Time
+	stopped bool
+}
+
+func (t *fakeTicker) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTicker) Stop() {
+	t.clock.mu.Lock()
+	t.stopped = true
+	t.clock.mu.Unlock()
+}
+
+func (t *fakeTicker) Reset(d time.Duration) {
+	t.clock.mu.Lock()
+	t.period = d
+	t.next = t.clock.now.Add(d)
+	t.stopped = false
+	t.clock.mu.Unlock()
+}
@@ -0,0 +1,97 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClockExpiration(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	tc := New(time.Hour, 0, WithClock(clock))
+
+	tc.Set("a", 1, DefaultExpiration)
+	tc.Set("b", 2, 2*time.Hour)
+	clock.Advance(59 * time.Minute)
+	if _, found := tc.Get("a"); !found {
+		t.Error("a expired before its expiration")
+	}
+	if ttl, _ := tc.TTL("b"); ttl != 61*time.Minute {
+		t.Errorf("TTL of b is %v, want 61m", ttl)
+	}
+
+	clock.Advance(2 * time.Minute)
+	if _, found := tc.Get("a"); found {
+		t.Error("Found a after its expiration")
+	}
+	if _, found := tc.Get("b"); !found {
+		t.Error("b expired before its expiration")
+	}
+	_, expiration, _ := tc.GetWithExpiration("b")
+	if want := clock.Now().Add(59 * time.Minute); !expiration.Equal(want) {
+		t.Errorf("Expiration of b is %v, want %v", expiration, want)
+	}
+
+	item := tc.Items()["b"]
+	if item.ExpiredAt(clock.Now()) {
+		t.Error("b has expired at the clock's time")
+	}
+	if !item.ExpiredAt(clock.Now().Add(time.Hour)) {
+		t.Error("b hasn't expired an hour later")
+	}
+}
+
+func TestClockJanitor(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	tc := New(time.Minute, 10*time.Minute, WithClock(clock))
+	defer tc.Close()
+	evicted := make(chan interface{}, 1)
+	tc.OnEvicted(func(k interface{}, v interface{}) {
+		evicted <- k
+	})
+
+	tc.Set("a", 1, DefaultExpiration)
+	clock.Advance(5 * time.Minute)
+	if n := tc.ItemCount(); n != 1 {
+		t.Fatalf("Item count before the janitor ran is %d, want 1", n)
+	}
+	clock.Advance(5 * time.Minute)
+	select {
+	case k := <-evicted:
+		if k != "a" {
+			t.Errorf("The janitor evicted %v, want a", k)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("The janitor did not run when the clock passed the cleanup interval")
+	}
+}
+
+func TestFakeClockTicker(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	ticker := clock.NewTicker(time.Second)
+
+	clock.Advance(500 * time.Millisecond)
+	select {
+	case <-ticker.C():
+		t.Fatal("Ticked before the interval passed")
+	default:
+	}
+	clock.Advance(3 * time.Second)
+	select {
+	case <-ticker.C():
+	default:
+		t.Fatal("Did not tick after the interval passed")
+	}
+	select {
+	case <-ticker.C():
+		t.Fatal("Ticks were not dropped when the receiver was behind")
+	default:
+	}
+
+	ticker.Stop()
+	clock.Advance(time.Hour)
+	select {
+	case <-ticker.C():
+		t.Fatal("Ticked after Stop")
+	default:
+	}
+}
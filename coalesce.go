@@ -0,0 +1,114 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// WithWriteCoalescing buffers the values passed to Set and commits only the
+// latest value for each key once every flushInterval, so that rapid updates of
+// the same key take the cache's lock once per interval instead of once per
+// call. Until a buffered value is committed, lookups return the previously
+// committed value: reads may lag writes by up to flushInterval. Delete and
+// Flush discard buffered values for the keys they remove; other methods don't
+// see buffered values at all. Close commits the buffer. An interval that is
+// not greater than zero disables coalescing.
+func WithWriteCoalescing(flushInterval time.Duration) Option {
+	return func(c *cache) {
+		if flushInterval <= 0 {
+			c.coalescer = nil
+			return
+		}
+		c.coalescer = &coalescer{
+			interval: flushInterval,
+			pending:  map[interface{}]Item{},
+			stop:     make(chan struct{}),
+			done:     make(chan struct{}),
+		}
+	}
+}
+
+// Buffered writes waiting to be committed to the cache.
+type coalescer struct {
+	mu       sync.Mutex
+	interval time.Duration
+	pending  map[interface{}]Item
+	closed   bool
+	flushes  uint64
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// Buffer item under k. Returns false if the coalescer has been stopped and the
+// write must be made directly.
+func (co *coalescer) buffer(k interface{}, item Item) bool {
+	co.mu.Lock()
+	defer co.mu.Unlock()
+
+	if co.closed {
+		return false
+	}
+	co.pending[k] = item
+	return true
+}
+
+// Discard the buffered write for k, if any.
+func (co *coalescer) discard(k interface{}) {
+	co.mu.Lock()
+	delete(co.pending, k)
+	co.mu.Unlock()
+}
+
+func (co *coalescer) discardAll() {
+	co.mu.Lock()
+	co.pending = map[interface{}]Item{}
+	co.mu.Unlock()
+}
+
+func (co *coalescer) run(c *cache) {
+	defer close(co.done)
+	ticker := time.NewTicker(co.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.flushWrites()
+		case <-co.stop:
+			return
+		}
+	}
+}
+
+// Commit the buffered writes to the cache.
+func (c *cache) flushWrites() {
+	co := c.coalescer
+	co.mu.Lock()
+	pending := co.pending
+	if len(pending) == 0 {
+		co.mu.Unlock()
+		return
+	}
+	co.pending = map[interface{}]Item{}
+	co.flushes++
+	// Lock the cache before releasing the buffer, so that a Delete racing
+	// with this flush can't be overtaken by the value it discarded.
+	c.Lock()
+	co.mu.Unlock()
+	defer c.unlock()
+
+	for k, item := range pending {
+		c.put(k, item)
+	}
+}
+
+// Stop committing buffered writes periodically and commit what is left.
+// Writes made afterwards go directly to the cache.
+func (c *cache) stopCoalescing() {
+	co := c.coalescer
+	co.mu.Lock()
+	co.closed = true
+	co.mu.Unlock()
+	close(co.stop)
+	<-co.done
+	c.flushWrites()
+}
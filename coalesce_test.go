@@ -0,0 +1,52 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWriteCoalescing(t *testing.T) {
+	// The interval is long enough that only Close commits the buffer.
+	tc := New(DefaultExpiration, 0, WithWriteCoalescing(time.Hour))
+	const sets = 10000
+	for i := 0; i < sets; i++ {
+		tc.Set("gauge", i, DefaultExpiration)
+	}
+	tc.Set("deleted", 1, DefaultExpiration)
+	tc.Delete("deleted")
+	if _, found := tc.Get("gauge"); found {
+		t.Error("Found a buffered value before it was committed")
+	}
+	tc.Close()
+
+	co := tc.shard("gauge").coalescer
+	co.mu.Lock()
+	flushes := co.flushes
+	co.mu.Unlock()
+	if flushes != 1 {
+		t.Errorf("Cache was locked %d times to commit %d sets; want 1", flushes, sets)
+	}
+	if x, found := tc.Get("gauge"); !found || x.(int) != sets-1 {
+		t.Errorf("gauge is %v, %v after Close; want %d", x, found, sets-1)
+	}
+	if _, found := tc.Get("deleted"); found {
+		t.Error("Found a value that was deleted while buffered")
+	}
+
+	tc.Set("after", 1, DefaultExpiration)
+	if _, found := tc.Get("after"); !found {
+		t.Error("Set after Close was not committed")
+	}
+	tc.Close()
+}
+
+func TestWriteCoalescingDisabled(t *testing.T) {
+	for _, d := range []time.Duration{0, -time.Second} {
+		tc := New(DefaultExpiration, 0, WithWriteCoalescing(d))
+		tc.Set("a", 1, DefaultExpiration)
+		if x, found := tc.Get("a"); !found || x != 1 {
+			t.Errorf("WithWriteCoalescing(%v): got %v, %t, want the value set", d, x, found)
+		}
+		tc.Close()
+	}
+}
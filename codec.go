@@ -0,0 +1,116 @@
+package cache
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// A Codec serializes the items of a cache. Save, Load, SaveFile, LoadFile and
+// snapshots use the cache's codec, which is GobCodec unless another one is
+// set with WithCodec.
+type Codec interface {
+	// Write items to w.
+	Encode(w io.Writer, items map[interface{}]Item) error
+	// Read items written by Encode from r.
+	Decode(r io.Reader) (map[interface{}]Item, error)
+}
+
+// WithCodec makes the cache save and load its items with codec instead of
+// GobCodec.
+func WithCodec(codec Codec) Option {
+	return func(c *cache) {
+		c.codec = codec
+	}
+}
+
+// Returns the codec used to save and load the cache.
+func (c *cache) itemCodec() Codec {
+	if c.codec == nil {
+		return GobCodec{}
+	}
+	return c.codec
+}
+
+// GobCodec encodes items with encoding/gob. The types of all keys and values
+// are registered with gob.Register when encoding; types that aren't builtin
+// must also be registered before decoding.
+type GobCodec struct{}
+
+func (GobCodec) Encode(w io.Writer, items map[interface{}]Item) (err error) {
+	defer func() {
+		if x := recover(); x != nil {
+			err = fmt.Errorf("Error registering item types with Gob library")
+		}
+	}()
+	for k, v := range items {
+		gob.Register(k)
+		gob.Register(v.Object)
+	}
+	return gob.NewEncoder(w).Encode(&items)
+}
+
+func (GobCodec) Decode(r io.Reader) (map[interface{}]Item, error) {
+	items := map[interface{}]Item{}
+	if err := gob.NewDecoder(r).Decode(&items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// An item as written by JSONCodec.
+type jsonItem struct {
+	Key        interface{} `json:"key"`
+	Value      interface{} `json:"value"`
+	Expiration *time.Time  `json:"expiration,omitempty"`
+}
+
+// JSONCodec encodes items as a JSON array of objects with "key", "value" and,
+// for items that expire, "expiration" fields, sorted by key like SortedItems.
+// Expirations are written as RFC 3339 timestamps. Since no type information is
+// saved, keys and values are decoded the way encoding/json decodes into an
+// interface{}: numbers become float64s, objects map[string]interface{}s, and
+// so on. Decode returns an error for keys that are objects or arrays, which
+// can't be map keys, such as struct keys written by Encode.
+type JSONCodec struct{}
+
+func (JSONCodec) Encode(w io.Writer, items map[interface{}]Item) error {
+	out := make([]jsonItem, 0, len(items))
+	for k, v := range items {
+		ji := jsonItem{Key: k, Value: v.Object}
+		if v.Expiration > 0 {
+			e := expirationTime(v.Expiration)
+			ji.Expiration = &e
+		}
+		out = append(out, ji)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return naturalKeyLess(out[i].Key, out[j].Key)
+	})
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+func (JSONCodec) Decode(r io.Reader) (map[interface{}]Item, error) {
+	var in []jsonItem
+	if err := json.NewDecoder(r).Decode(&in); err != nil {
+		return nil, err
+	}
+	items := make(map[interface{}]Item, len(in))
+	for _, ji := range in {
+		if ji.Key != nil && !reflect.TypeOf(ji.Key).Comparable() {
+			return nil, fmt.Errorf("cache: can't use JSON key %v, decoded as %T, as a map key", ji.Key, ji.Key)
+		}
+		item := Item{Object: ji.Value}
+		if ji.Expiration != nil {
+			item.Expiration = expirationNano(*ji.Expiration)
+		}
+		items[ji.Key] = item
+	}
+	return items, nil
+}
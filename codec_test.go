@@ -0,0 +1,82 @@
+package cache
+
+import (
+	"bytes"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// A codec that records how often it was used.
+type countingCodec struct {
+	JSONCodec
+	encodes, decodes int
+}
+
+func (cc *countingCodec) Encode(w io.Writer, items map[interface{}]Item) error {
+	cc.encodes++
+	return cc.JSONCodec.Encode(w, items)
+}
+
+func (cc *countingCodec) Decode(r io.Reader) (map[interface{}]Item, error) {
+	cc.decodes++
+	return cc.JSONCodec.Decode(r)
+}
+
+func TestWithCodec(t *testing.T) {
+	codec := &countingCodec{}
+	tc := New(DefaultExpiration, 0, WithCodec(codec))
+	tc.Set("a", "a", time.Hour)
+
+	buf := &bytes.Buffer{}
+	if err := tc.Save(buf); err != nil {
+		t.Fatal("Couldn't save cache:", err)
+	}
+	if !strings.Contains(buf.String(), `"key": "a"`) {
+		t.Error("cache wasn't saved as JSON:", buf.String())
+	}
+
+	oc := New(DefaultExpiration, 0, WithCodec(codec))
+	if err := oc.Load(buf); err != nil {
+		t.Fatal("Couldn't load cache:", err)
+	}
+	if x, found := oc.Get("a"); !found || x != "a" {
+		t.Error("a wasn't loaded:", x)
+	}
+	if codec.encodes != 1 || codec.decodes != 1 {
+		t.Errorf("codec was used for %d encodes and %d decodes, want 1 and 1", codec.encodes, codec.decodes)
+	}
+}
+
+func TestSnapshotWithCodec(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.json")
+	tc := New(DefaultExpiration, 0, WithCodec(JSONCodec{}), WithAutoSnapshot(path, time.Hour))
+	tc.Set("a", "a", DefaultExpiration)
+	tc.Close()
+
+	if _, err := NewFromSnapshot(path, DefaultExpiration, 0); err == nil {
+		t.Error("a JSON snapshot was decoded with gob")
+	}
+	oc, err := NewFromSnapshot(path, DefaultExpiration, 0, WithCodec(JSONCodec{}))
+	if err != nil {
+		t.Fatal("Couldn't create cache from snapshot:", err)
+	}
+	if x, found := oc.Get("a"); !found || x != "a" {
+		t.Error("a wasn't loaded from snapshot:", x)
+	}
+}
+
+func TestJSONCodecUnhashableKey(t *testing.T) {
+	type point struct{ X, Y int }
+	tc := New(DefaultExpiration, 0)
+	tc.Set(point{1, 2}, "a", DefaultExpiration)
+	buf := &bytes.Buffer{}
+	if err := tc.ExportJSON(buf); err != nil {
+		t.Fatal(err)
+	}
+	if err := New(DefaultExpiration, 0).ImportJSON(buf); err == nil {
+		t.Error("Imported an object key without an error")
+	}
+}
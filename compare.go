@@ -0,0 +1,137 @@
+package cache
+
+import "time"
+
+// CompareAndSwap stores new under k with the expiration d, as with Set, only if
+// k holds an unexpired item whose value equals old. Values are compared with
+// ==, so pointers match only if they point to the same thing, and values that
+// can't be compared (such as slices) never match. Returns true if new was
+// stored.
+func (c *cache) CompareAndSwap(k, old, new interface{}, d time.Duration) bool {
+	c = c.shard(k)
+	k = c.key(k)
+	c.Lock()
+	defer c.unlock()
+
+	item, found := c.get(k)
+	if !found || !equal(item.Object, old) {
+		return false
+	}
+	c.set(k, new, d)
+	return true
+}
+
+// CompareAndDelete deletes the unexpired item under k, calling OnEvicted for
+// it, only if its value equals expected, compared as by CompareAndSwap.
+// Returns true if the item was deleted.
+func (c *cache) CompareAndDelete(k, expected interface{}) bool {
+	c = c.shard(k)
+	k = c.key(k)
+	c.Lock()
+	defer c.unlock()
+
+	item, found := c.get(k)
+	if !found || !equal(item.Object, expected) {
+		return false
+	}
+	c.evict(k, EventDelete)
+	return true
+}
+
+// Swap stores v under k with the expiration d, as with Set, and returns the
+// value of the unexpired item it replaced, if any, along with a bool indicating
+// whether there was one.
+func (c *cache) Swap(k, v interface{}, d time.Duration) (prev interface{}, existed bool) {
+	c = c.shard(k)
+	k = c.key(k)
+	c.Lock()
+	defer c.unlock()
+
+	item, found := c.get(k)
+	c.set(k, v, d)
+	if !found {
+		return nil, false
+	}
+	return item.Object, true
+}
+
+// GetOrSet returns the value of the unexpired item under k and true if there
+// is one. Otherwise it stores v under k with the expiration d, as with Set, and
+// returns v and false. Like sync.Map's LoadOrStore, of several goroutines
+// calling it for a missing key, exactly one stores its value and all get that
+// value.
+func (c *cache) GetOrSet(k, v interface{}, d time.Duration) (actual interface{}, loaded bool) {
+	c = c.shard(k)
+	k = c.key(k)
+	c.Lock()
+	defer c.unlock()
+
+	item, found := c.get(k)
+	c.recordLookup(found)
+	if found {
+		c.access.used(k)
+		return item.Object, true
+	}
+	c.set(k, v, d)
+	return v, false
+}
+
+// Pop gets an item from the cache and deletes it in one step, calling
+// OnEvicted for it. Returns the item or nil, and a bool indicating whether the
+// key was found. Of several goroutines popping the same item, only one gets it.
+func (c *cache) Pop(k interface{}) (interface{}, bool) {
+	c = c.shard(k)
+	k = c.key(k)
+	if c.coalescer != nil {
+		c.coalescer.discard(k)
+	}
+	c.Lock()
+	defer c.unlock()
+
+	item, found := c.get(k)
+	c.recordLookup(found)
+	if !found {
+		c.expireStale(k)
+		return nil, false
+	}
+	c.evict(k, EventDelete)
+	return item.Object, true
+}
+
+// Unchanged can be returned by the function passed to Update to leave the item
+// as it is, keeping its expiration.
+var Unchanged interface{} = unchanged{}
+
+type unchanged struct{}
+
+// Update reads, modifies and writes the item under k in one step. fn is called
+// with the value of the unexpired item under k and true, or with nil and false
+// if there is none. If it returns keep true, the value it returns is stored
+// with the expiration d, as with Set but keeping the item's priority;
+// otherwise the item, if any, is deleted, calling OnEvicted for it. If it
+// returns Unchanged as the new value, the item, if any, is left as it is. fn is called with the cache's write lock held, so
+// no other goroutine can change the item in the meantime; it must be quick and
+// must not use the cache.
+func (c *cache) Update(k interface{}, fn func(old interface{}, exists bool) (new interface{}, d time.Duration, keep bool)) {
+	c = c.shard(k)
+	k = c.key(k)
+	c.Lock()
+	defer c.unlock()
+
+	var old interface{}
+	item, found := c.get(k)
+	if found {
+		old = item.Object
+	} else {
+		c.expireStale(k)
+	}
+	v, d, keep := fn(old, found)
+	if v == Unchanged {
+		return
+	}
+	if keep {
+		c.rewrite(k, v, d)
+	} else if found {
+		c.evict(k, EventDelete)
+	}
+}
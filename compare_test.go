@@ -0,0 +1,250 @@
+package cache
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCompareAndSwap(t *testing.T) {
+	tc := New(DefaultExpiration, 0)
+	tc.Set("a", 1, DefaultExpiration)
+
+	if tc.CompareAndSwap("a", 2, 3, DefaultExpiration) {
+		t.Error("swapped a with the wrong old value")
+	}
+	if !tc.CompareAndSwap("a", 1, 3, DefaultExpiration) {
+		t.Error("didn't swap a")
+	}
+	if x, _ := tc.Get("a"); x != 3 {
+		t.Error("a is not 3:", x)
+	}
+	if tc.CompareAndSwap("missing", nil, 1, DefaultExpiration) {
+		t.Error("swapped a missing item")
+	}
+
+	p, q := &TestStruct{Num: 1}, &TestStruct{Num: 1}
+	tc.Set("p", p, DefaultExpiration)
+	if tc.CompareAndSwap("p", q, 2, DefaultExpiration) {
+		t.Error("swapped with a different pointer to an equal value")
+	}
+	if !tc.CompareAndSwap("p", p, 2, DefaultExpiration) {
+		t.Error("didn't swap with the same pointer")
+	}
+
+	tc.Set("s", []int{1}, DefaultExpiration)
+	if tc.CompareAndSwap("s", []int{1}, 2, DefaultExpiration) {
+		t.Error("swapped a slice")
+	}
+}
+
+func TestCompareAndSwapConcurrent(t *testing.T) {
+	tc := New(DefaultExpiration, 0)
+	tc.Set("n", 0, DefaultExpiration)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				for {
+					x, _ := tc.Get("n")
+					if tc.CompareAndSwap("n", x, x.(int)+1, DefaultExpiration) {
+						break
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if x, _ := tc.Get("n"); x != 1000 {
+		t.Error("n is not 1000:", x)
+	}
+}
+
+func TestCompareAndDelete(t *testing.T) {
+	tc := New(DefaultExpiration, 0)
+	var evicted []interface{}
+	tc.OnEvicted(func(k, v interface{}) {
+		evicted = append(evicted, v)
+	})
+	tc.Set("a", 1, DefaultExpiration)
+
+	if tc.CompareAndDelete("a", 2) {
+		t.Error("deleted a with the wrong value")
+	}
+	if _, found := tc.Get("a"); !found {
+		t.Error("a was deleted")
+	}
+	if !tc.CompareAndDelete("a", 1) {
+		t.Error("didn't delete a")
+	}
+	if _, found := tc.Get("a"); found {
+		t.Error("a wasn't deleted")
+	}
+	if tc.CompareAndDelete("a", 1) {
+		t.Error("deleted a missing item")
+	}
+	if len(evicted) != 1 || evicted[0] != 1 {
+		t.Error("OnEvicted was called for", evicted)
+	}
+}
+
+func TestSwap(t *testing.T) {
+	tc := New(DefaultExpiration, 0)
+	if prev, existed := tc.Swap("a", 1, DefaultExpiration); existed || prev != nil {
+		t.Errorf("Swap of a missing item returned %v, %v", prev, existed)
+	}
+	if prev, existed := tc.Swap("a", 2, DefaultExpiration); !existed || prev != 1 {
+		t.Errorf("Swap returned %v, %v, want 1, true", prev, existed)
+	}
+	if x, _ := tc.Get("a"); x != 2 {
+		t.Error("a is not 2:", x)
+	}
+}
+
+func TestPop(t *testing.T) {
+	tc := New(DefaultExpiration, 0)
+	var evicted int
+	tc.OnEvicted(func(k, v interface{}) {
+		evicted++
+	})
+	tc.Set("token", "t", DefaultExpiration)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var popped []interface{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if x, found := tc.Pop("token"); found {
+				mu.Lock()
+				popped = append(popped, x)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(popped) != 1 || popped[0] != "t" {
+		t.Error("popped", popped)
+	}
+	if _, found := tc.Get("token"); found {
+		t.Error("token is still in the cache")
+	}
+	if evicted != 1 {
+		t.Errorf("OnEvicted was called %d times, want 1", evicted)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	tc := New(DefaultExpiration, 0)
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			tc.Update("list", func(old interface{}, exists bool) (interface{}, time.Duration, bool) {
+				list, _ := old.([]int)
+				return append(list, i), DefaultExpiration, true
+			})
+		}(i)
+	}
+	wg.Wait()
+	if x, _ := tc.Get("list"); len(x.([]int)) != 50 {
+		t.Errorf("list has %d elements after 50 appends", len(x.([]int)))
+	}
+
+	evicted := 0
+	tc.OnEvicted(func(k interface{}, v interface{}) {
+		evicted++
+	})
+	tc.Update("list", func(old interface{}, exists bool) (interface{}, time.Duration, bool) {
+		return nil, 0, false
+	})
+	if _, found := tc.Get("list"); found || evicted != 1 {
+		t.Errorf("Update with keep false left the item: found %v, evicted %d", found, evicted)
+	}
+	tc.Update("missing", func(old interface{}, exists bool) (interface{}, time.Duration, bool) {
+		if exists || old != nil {
+			t.Errorf("fn was called with %v, %v for a missing item", old, exists)
+		}
+		return nil, 0, false
+	})
+	if evicted != 1 {
+		t.Error("Update of a missing item called OnEvicted")
+	}
+}
+
+func TestUpdateUnchanged(t *testing.T) {
+	tc := New(DefaultExpiration, 0)
+	tc.Set("a", 1, time.Hour)
+	_, want, _ := tc.GetWithExpiration("a")
+	events, cancel := tc.Watch("a")
+	defer cancel()
+	tc.Update("a", func(old interface{}, exists bool) (interface{}, time.Duration, bool) {
+		return Unchanged, NoExpiration, true
+	})
+	if x, e, found := tc.GetWithExpiration("a"); !found || x != 1 || !e.Equal(want) {
+		t.Errorf("got %v, %v, %t; want 1, %v, true", x, e, found, want)
+	}
+	tc.Update("a", func(old interface{}, exists bool) (interface{}, time.Duration, bool) {
+		return Unchanged, 0, false
+	})
+	if _, found := tc.Get("a"); !found {
+		t.Error("Update returning Unchanged with keep false deleted the item")
+	}
+	if n := len(events); n != 0 {
+		t.Errorf("got %d events, want none", n)
+	}
+}
+
+func TestTypedUpdate(t *testing.T) {
+	tc := NewTyped[string, int](DefaultExpiration, 0)
+	for i := 0; i < 3; i++ {
+		tc.Update("n", func(old int, exists bool) (int, time.Duration, bool) {
+			return old + 1, DefaultExpiration, true
+		})
+	}
+	if n, _ := tc.Get("n"); n != 3 {
+		t.Errorf("n is %d, want 3", n)
+	}
+}
+
+func TestGetOrSet(t *testing.T) {
+	tc := New(DefaultExpiration, 0)
+	var wg sync.WaitGroup
+	results := make([]interface{}, 20)
+	stored := make([]bool, 20)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			x, loaded := tc.GetOrSet("a", i, DefaultExpiration)
+			results[i], stored[i] = x, !loaded
+		}(i)
+	}
+	wg.Wait()
+	winners := 0
+	for i := range results {
+		if stored[i] {
+			winners++
+			if results[i] != i {
+				t.Errorf("GetOrSet that stored %d returned %v", i, results[i])
+			}
+		}
+		if x, _ := tc.Get("a"); results[i] != x {
+			t.Errorf("GetOrSet returned %v, but a is %v", results[i], x)
+		}
+	}
+	if winners != 1 {
+		t.Errorf("%d calls stored their value, want 1", winners)
+	}
+
+	tc.Set("b", 1, time.Nanosecond)
+	<-time.After(time.Millisecond)
+	if x, loaded := tc.GetOrSet("b", 2, DefaultExpiration); loaded || x != 2 {
+		t.Errorf("GetOrSet of an expired item returned %v, %v; want 2, false", x, loaded)
+	}
+}
@@ -0,0 +1,110 @@
+package cache
+
+import "fmt"
+
+// ConsistencyCheck verifies the cache's internal invariants and returns an
+// error describing the first violation found, or nil. It takes the write lock
+// for the duration of the check, so it is meant for tests and debugging after
+// heavy concurrent use, not for regular operation.
+func (c *cache) ConsistencyCheck() error {
+	for i, s := range c.shards {
+		if err := s.ConsistencyCheck(); err != nil {
+			return fmt.Errorf("shard %d: %w", i, err)
+		}
+		s.RLock()
+		for k := range s.items {
+			if c.keyShard(k) != s {
+				s.RUnlock()
+				return fmt.Errorf("shard %d holds key %v of another shard", i, k)
+			}
+		}
+		s.RUnlock()
+	}
+	c.Lock()
+	defer c.Unlock()
+
+	if len(c.pending) != 0 {
+		return fmt.Errorf("%d evicted items are waiting for the eviction callback", len(c.pending))
+	}
+	// Pinned items may take up the room, leaving room for one other.
+	if c.maxEntries > 0 && len(c.items) > c.maxEntries && len(c.items) > len(c.access.pinned)+1 {
+		return fmt.Errorf("cache holds %d items, but is limited to %d", len(c.items), c.maxEntries)
+	}
+	if n := c.access.len(); n != len(c.items) {
+		return fmt.Errorf("access order tracks %d keys, but the cache holds %d items", n, len(c.items))
+	}
+	for k := range c.access.elems {
+		if _, found := c.items[k]; !found {
+			return fmt.Errorf("access order tracks missing key %v", k)
+		}
+	}
+	for k := range c.access.pinned {
+		if _, found := c.access.elems[k]; !found {
+			return fmt.Errorf("pinned key %v isn't tracked", k)
+		}
+		if c.items[k].Expiration != 0 {
+			return fmt.Errorf("pinned item %v expires", k)
+		}
+	}
+	var cost int64
+	for k, v := range c.costs {
+		if _, found := c.items[k]; !found {
+			return fmt.Errorf("cost is recorded for missing key %v", k)
+		}
+		cost += v
+	}
+	if cost != c.totalCost {
+		return fmt.Errorf("item costs add up to %d, but the total cost is %d", cost, c.totalCost)
+	}
+	if c.maxCost > 0 && c.totalCost > c.maxCost && len(c.items) > len(c.access.pinned) {
+		return fmt.Errorf("total cost is %d, but is limited to %d", c.totalCost, c.maxCost)
+	}
+	if c.expirations != nil {
+		// Entries for deleted items or old expirations are allowed, but
+		// every item that expires must have one for its expiration.
+		h := *c.expirations
+		indexed := map[interface{}]bool{}
+		for i, e := range h {
+			if i > 0 && h.Less(i, (i-1)/2) {
+				return fmt.Errorf("expiration index entry %d for %v is out of heap order", i, e.key)
+			}
+			if v, found := c.items[e.key]; found && v.Expiration == e.expiration {
+				indexed[e.key] = true
+			}
+		}
+		for k, v := range c.items {
+			if v.Expiration > 0 && !indexed[k] {
+				return fmt.Errorf("item %v expires, but its expiration isn't indexed", k)
+			}
+		}
+	}
+	for k := range c.timers {
+		if _, found := c.items[k]; !found {
+			return fmt.Errorf("expiration timer is running for missing key %v", k)
+		}
+	}
+	for tag, l := range c.tagIndex.tags {
+		if l.Len() == 0 {
+			return fmt.Errorf("tag %q has no items", tag)
+		}
+		if c.maxPerTag > 0 && l.Len() > c.maxPerTag {
+			return fmt.Errorf("tag %q has %d items, but is limited to %d", tag, l.Len(), c.maxPerTag)
+		}
+		for e := l.Front(); e != nil; e = e.Next() {
+			if c.tagIndex.tagged[e.Value][tag] != e {
+				return fmt.Errorf("tag %q lists %v, which isn't indexed as tagged with it", tag, e.Value)
+			}
+		}
+	}
+	for k, tags := range c.tagIndex.tagged {
+		if _, found := c.items[k]; !found {
+			return fmt.Errorf("tagged key %v is not in the cache", k)
+		}
+		for tag := range tags {
+			if c.tagIndex.tags[tag] == nil {
+				return fmt.Errorf("key %v is tagged with unknown tag %q", k, tag)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package cache
+
+import (
+	"math/rand"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Run one cache operation chosen by op on key k.
+func runOp(tc *Cache, op byte, k int) {
+	switch op % 9 {
+	case 0:
+		tc.Set(k, k, DefaultExpiration)
+	case 1:
+		tc.Set(k, k, time.Millisecond)
+	case 2:
+		tc.Get(k)
+	case 3:
+		tc.Delete(k)
+	case 4:
+		tc.Add(k, k, DefaultExpiration)
+	case 5:
+		tc.GetAndExtend(k, time.Millisecond)
+	case 6:
+		tc.IncrementMany(map[interface{}]int64{k: 1}, DefaultExpiration)
+	case 7:
+		tc.DeleteExpired()
+	case 8:
+		tc.SetWithTags(k, k, DefaultExpiration, strconv.Itoa(k%3))
+	}
+}
+
+func TestConsistencyCheckConcurrent(t *testing.T) {
+	tc := New(5*time.Millisecond, time.Millisecond, WithMaxEntries(50), WithMaxPerTag(10), WithExpirationIndex())
+	tc.OnEvicted(func(k interface{}, v interface{}) {})
+	const workers = 8
+	wg := new(sync.WaitGroup)
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func(seed int64) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(seed))
+			for j := 0; j < 2000; j++ {
+				runOp(tc, byte(r.Intn(256)), r.Intn(100))
+			}
+		}(int64(i))
+	}
+	wg.Wait()
+	if err := tc.ConsistencyCheck(); err != nil {
+		t.Error(err)
+	}
+}
+
+func FuzzConsistencyCheck(f *testing.F) {
+	f.Add([]byte{0, 1, 2, 3, 4, 5, 6, 7})
+	f.Add([]byte{1, 1, 1, 7, 0, 0, 3, 3})
+	f.Fuzz(func(t *testing.T, ops []byte) {
+		tc := New(DefaultExpiration, 0, WithMaxEntries(4), WithMaxPerTag(2), WithExpirationIndex())
+		tc.OnEvicted(func(k interface{}, v interface{}) {})
+		wg := new(sync.WaitGroup)
+		for i := 0; i+1 < len(ops); i += 2 {
+			wg.Add(1)
+			go func(op byte, k int) {
+				defer wg.Done()
+				runOp(tc, op, k)
+			}(ops[i], int(ops[i+1]%8))
+		}
+		wg.Wait()
+		if err := tc.ConsistencyCheck(); err != nil {
+			t.Error(err)
+		}
+	})
+}
@@ -0,0 +1,73 @@
+package cache
+
+import "time"
+
+// WithMaxCost limits the total cost of the items in the cache to max. Costs are
+// given with SetWithCost, in any unit (typically an approximate size in
+// bytes); items stored by other methods cost nothing. When storing an item
+// pushes the total over max, items are evicted as for WithMaxEntries until it
+// fits, calling the eviction callbacks with ReasonCapacity. An item that
+// costs more than max on its own is evicted right away. A max less than one
+// means no limit.
+func WithMaxCost(max int64) Option {
+	return func(c *cache) {
+		c.maxCost = max
+	}
+}
+
+// SetWithCost adds an item to the cache like Set, recording its cost toward the
+// limit set with WithMaxCost. Storing the key again replaces its cost.
+func (c *cache) SetWithCost(k interface{}, x interface{}, cost int64, d time.Duration) {
+	c = c.shard(k)
+	k = c.key(k)
+	c.Lock()
+	defer c.unlock()
+
+	if !c.set(k, x, d) {
+		return
+	}
+	c.setCost(k, cost)
+	if c.maxCost > 0 && cost > c.maxCost && !c.access.isPinned(k) {
+		c.evict(k, EventEvict)
+		return
+	}
+	for c.maxCost > 0 && c.totalCost > c.maxCost && len(c.items) > 0 {
+		if c.evictN(1) == 0 {
+			break
+		}
+	}
+}
+
+// Record the cost of the item stored under k. Must be called with the write
+// lock held.
+func (c *cache) setCost(k interface{}, cost int64) {
+	old, found := c.costs[k]
+	if !found && cost == 0 {
+		return
+	}
+	c.totalCost += cost - old
+	if cost == 0 {
+		delete(c.costs, k)
+		return
+	}
+	if c.costs == nil {
+		c.costs = map[interface{}]int64{}
+	}
+	c.costs[k] = cost
+}
+
+// Returns the total cost of the items in the cache, as given to SetWithCost.
+// This may include items that have expired, but have not yet been cleaned up.
+func (c *cache) TotalCost() int64 {
+	if c.shards != nil {
+		var cost int64
+		for _, s := range c.shards {
+			cost += s.TotalCost()
+		}
+		return cost
+	}
+	c.RLock()
+	defer c.RUnlock()
+
+	return c.totalCost
+}
@@ -0,0 +1,45 @@
+package cache
+
+import "testing"
+
+func TestMaxCost(t *testing.T) {
+	tc := New(DefaultExpiration, 0, WithMaxCost(100))
+	var evicted []interface{}
+	tc.OnEvictedWithReason(func(k interface{}, v interface{}, reason EvictionReason) {
+		if reason == ReasonCapacity {
+			evicted = append(evicted, k)
+		}
+	})
+	tc.SetWithCost("a", 1, 40, DefaultExpiration)
+	tc.SetWithCost("b", 2, 40, DefaultExpiration)
+	tc.Set("free", 0, DefaultExpiration)
+	tc.Get("a")
+	tc.SetWithCost("c", 3, 30, DefaultExpiration)
+
+	if len(evicted) != 1 || evicted[0] != "b" {
+		t.Errorf("Evicted %v, want [b]", evicted)
+	}
+	if n := tc.TotalCost(); n != 70 {
+		t.Errorf("Total cost is %d, want 70", n)
+	}
+
+	tc.SetWithCost("a", 1, 10, DefaultExpiration)
+	if n := tc.TotalCost(); n != 40 {
+		t.Errorf("Total cost after replacing a is %d, want 40", n)
+	}
+	tc.Delete("c")
+	if n := tc.TotalCost(); n != 10 {
+		t.Errorf("Total cost after deleting c is %d, want 10", n)
+	}
+
+	tc.SetWithCost("huge", 0, 1000, DefaultExpiration)
+	if _, found := tc.Get("huge"); found {
+		t.Error("Kept an item costing more than the limit")
+	}
+	if _, found := tc.Get("a"); !found {
+		t.Error("Evicted a to make room for an item that can't fit")
+	}
+	if err := tc.ConsistencyCheck(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,50 @@
+package cache
+
+import "time"
+
+// WithEagerExpiration deletes each item exactly when it expires, using a timer
+// per item, instead of waiting for the janitor or the next DeleteExpired. The
+// timer is rescheduled whenever the item's expiration changes and stopped when
+// it is deleted. Timers cost memory and scheduling overhead per item, so this
+// is only appropriate for caches holding a modest number of items.
+func WithEagerExpiration() Option {
+	return func(c *cache) {
+		c.timers = map[interface{}]*time.Timer{}
+	}
+}
+
+// Schedule deletion of k at the expiration e, replacing any earlier timer, and
+// record e in the expiration index. Must be called with the write lock held,
+// after k is stored.
+func (c *cache) schedule(k interface{}, e int64) {
+	c.index(k, e)
+	if c.timers == nil {
+		return
+	}
+	c.unschedule(k)
+	if e <= 0 {
+		return
+	}
+	c.timers[k] = time.AfterFunc(time.Duration(e-c.now().UnixNano()), func() {
+		c.expire(k, e)
+	})
+}
+
+// Stop the expiration timer for k, if any. Must be called with the write lock
+// held.
+func (c *cache) unschedule(k interface{}) {
+	if t, found := c.timers[k]; found {
+		t.Stop()
+		delete(c.timers, k)
+	}
+}
+
+// Delete k if it still has the expiration e.
+func (c *cache) expire(k interface{}, e int64) {
+	c.Lock()
+	defer c.unlock()
+
+	if item, found := c.items[k]; found && item.Expiration == e {
+		c.evict(k, EventExpire)
+	}
+}
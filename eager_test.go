@@ -0,0 +1,50 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEagerExpiration(t *testing.T) {
+	tc := New(DefaultExpiration, 0, WithShards(1), WithEagerExpiration())
+	evictedAt := make(chan time.Time, 1)
+	tc.OnEvicted(func(k interface{}, v interface{}) {
+		if k == "a" {
+			evictedAt <- time.Now()
+		}
+	})
+
+	start := time.Now()
+	tc.Set("a", 1, 20*time.Millisecond)
+	tc.Set("b", 2, 10*time.Millisecond)
+	tc.Set("b", 2, NoExpiration)
+	tc.Set("c", 3, 10*time.Millisecond)
+	tc.Delete("c")
+
+	select {
+	case at := <-evictedAt:
+		if d := at.Sub(start); d < 20*time.Millisecond || d > 35*time.Millisecond {
+			t.Errorf("a was deleted after %v, want about 20ms", d)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("a was not deleted")
+	}
+	tc.RLock()
+	_, found := tc.items["a"]
+	tc.RUnlock()
+	if found {
+		t.Error("a is still stored after it expired")
+	}
+	if _, found := tc.Get("b"); !found {
+		t.Error("b was deleted after being set to never expire")
+	}
+	if err := tc.ConsistencyCheck(); err != nil {
+		t.Error(err)
+	}
+	tc.RLock()
+	timers := len(tc.timers)
+	tc.RUnlock()
+	if timers != 0 {
+		t.Errorf("%d timers are still running", timers)
+	}
+}
@@ -0,0 +1,55 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// Returned by Add when an unexpired item already exists.
+	ErrAlreadyExists = errors.New("item already exists")
+	// Returned by Replace and the increment methods when there is no
+	// unexpired item.
+	ErrNotFound = errors.New("item not found")
+	// Returned by the increment methods when the item's value is not a
+	// number.
+	ErrNotNumeric = errors.New("value is not numeric")
+	// Returned by IncrementFloat when the item's value is not a float32 or
+	// float64.
+	ErrNotFloat = errors.New("value does not have type float32 or float64")
+	// Returned by the loading methods, instead of calling the loader, while
+	// the circuit breaker set with WithLoadCircuitBreaker is open.
+	ErrCircuitOpen = errors.New("load circuit breaker is open")
+	// Reported for writes that are dropped because the queue set up with
+	// WithWriteBehind is full.
+	ErrWriteQueueFull = errors.New("write-behind queue is full")
+)
+
+// A KeyError records an error and the key for which it happened. Use
+// errors.Is to test for one of the errors above, and errors.As to get the key.
+type KeyError struct {
+	Key interface{}
+	Err error
+}
+
+func (e *KeyError) Error() string {
+	return fmt.Sprintf("key %v: %v", e.Key, e.Err)
+}
+
+func (e *KeyError) Unwrap() error {
+	return e.Err
+}
+
+// A PanicError is returned, wrapped in a *KeyError, by the loading methods
+// when the loader panics. The panic is recovered so that the callers waiting
+// for the load are released and the key can be loaded again.
+type PanicError struct {
+	// The value passed to panic.
+	Value interface{}
+	// The stack trace of the goroutine that panicked.
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("loader panicked: %v", e.Value)
+}
@@ -0,0 +1,40 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestErrors(t *testing.T) {
+	tc := New(DefaultExpiration, 0)
+	tc.Set("a", "a", DefaultExpiration)
+
+	err := tc.Add("a", 1, DefaultExpiration)
+	if !errors.Is(err, ErrAlreadyExists) {
+		t.Error("Add didn't return ErrAlreadyExists:", err)
+	}
+	var ke *KeyError
+	if !errors.As(err, &ke) || ke.Key != "a" {
+		t.Error("Add's error doesn't have the key:", err)
+	}
+	if err.Error() != "key a: item already exists" {
+		t.Error("Add's error is", err)
+	}
+
+	if err := tc.Replace("b", 1, DefaultExpiration); !errors.Is(err, ErrNotFound) {
+		t.Error("Replace didn't return ErrNotFound:", err)
+	}
+	if err := tc.Increment("b", 1); !errors.Is(err, ErrNotFound) {
+		t.Error("Increment didn't return ErrNotFound:", err)
+	}
+	if err := tc.Increment("a", 1); !errors.Is(err, ErrNotNumeric) {
+		t.Error("Increment didn't return ErrNotNumeric:", err)
+	}
+	if err := tc.IncrementFloat("a", 1); !errors.Is(err, ErrNotFloat) {
+		t.Error("IncrementFloat didn't return ErrNotFloat:", err)
+	}
+	err = tc.IncrementMany(map[interface{}]int64{"a": 1}, DefaultExpiration)
+	if !errors.Is(err, ErrNotNumeric) {
+		t.Error("IncrementMany didn't return ErrNotNumeric:", err)
+	}
+}
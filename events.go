@@ -0,0 +1,192 @@
+package cache
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// EventOp identifies the kind of change an Event describes.
+type EventOp int
+
+const (
+	// An item was stored under a key that had no unexpired item.
+	EventSet EventOp = iota
+	// An item was removed with Delete or a similar method.
+	EventDelete
+	// An expired item was removed from the cache.
+	EventExpire
+	// An item was evicted to make room for other items.
+	EventEvict
+	// An unexpired item was overwritten. OldValue holds its value.
+	EventReplace
+	// An item was removed by Flush.
+	EventFlush
+)
+
+func (op EventOp) String() string {
+	switch op {
+	case EventSet:
+		return "set"
+	case EventDelete:
+		return "delete"
+	case EventExpire:
+		return "expire"
+	case EventEvict:
+		return "evict"
+	case EventReplace:
+		return "replace"
+	case EventFlush:
+		return "flush"
+	}
+	return "unknown"
+}
+
+// An Event describes a single change made to the cache. Value is the new value
+// for EventSet and EventReplace, and the removed value for the other ops.
+type Event struct {
+	Op       EventOp
+	Key      interface{}
+	Value    interface{}
+	OldValue interface{}
+}
+
+type subscriber struct {
+	ch   chan Event
+	once sync.Once
+	// If watch is set, only events for key are delivered.
+	watch bool
+	key   interface{}
+}
+
+type eventHub struct {
+	mu      sync.Mutex
+	subs    map[*subscriber]struct{}
+	count   int32
+	dropped uint64
+}
+
+// Returns true if anyone is listening. Cheap enough to call on every write.
+func (h *eventHub) active() bool {
+	return atomic.LoadInt32(&h.count) > 0
+}
+
+// Deliver e to every subscriber without blocking. Events that don't fit in a
+// subscriber's buffer are dropped and counted.
+func (h *eventHub) emit(e Event) {
+	if !h.active() {
+		return
+	}
+	h.mu.Lock()
+	for s := range h.subs {
+		if s.watch && !equal(s.key, e.Key) {
+			continue
+		}
+		select {
+		case s.ch <- e:
+		default:
+			atomic.AddUint64(&h.dropped, 1)
+		}
+	}
+	h.mu.Unlock()
+}
+
+func (h *eventHub) subscribe(buffer int) *subscriber {
+	if buffer < 0 {
+		buffer = 0
+	}
+	return h.add(&subscriber{ch: make(chan Event, buffer)})
+}
+
+func (h *eventHub) add(s *subscriber) *subscriber {
+	h.mu.Lock()
+	if h.subs == nil {
+		h.subs = map[*subscriber]struct{}{}
+	}
+	h.subs[s] = struct{}{}
+	atomic.StoreInt32(&h.count, int32(len(h.subs)))
+	h.mu.Unlock()
+	return s
+}
+
+func (h *eventHub) unsubscribe(s *subscriber) {
+	s.once.Do(func() {
+		h.remove(s)
+		close(s.ch)
+	})
+}
+
+// Stop delivering events to s without closing its channel.
+func (h *eventHub) remove(s *subscriber) {
+	h.mu.Lock()
+	delete(h.subs, s)
+	atomic.StoreInt32(&h.count, int32(len(h.subs)))
+	h.mu.Unlock()
+}
+
+// Events returns a channel receiving every event for all keys in the cache, in
+// the order they happened, along with a function that stops delivery and
+// closes the channel.
+//
+// Sends never block the cache: when the channel's buffer is full the event is
+// dropped and counted (see DroppedEvents), so pick a buffer large enough for
+// the expected burst size and drain the channel promptly.
+func (c *cache) Events(buffer int) (<-chan Event, func()) {
+	s := c.events.subscribe(buffer)
+	return s.ch, func() {
+		c.events.unsubscribe(s)
+	}
+}
+
+// Number of events buffered for a channel returned by Watch.
+const watchBuffer = 16
+
+// Watch returns a channel receiving the events for the key k only: when it is
+// set, replaced, deleted, expired, evicted or flushed. The returned function
+// stops delivery and closes the channel. As with Events, sends never block the
+// cache; the channel buffers a few events, and events that don't fit are
+// dropped and counted.
+func (c *cache) Watch(k interface{}) (<-chan Event, func()) {
+	s := c.events.add(&subscriber{
+		ch:    make(chan Event, watchBuffer),
+		watch: true,
+		key:   c.key(k),
+	})
+	return s.ch, func() {
+		c.events.unsubscribe(s)
+	}
+}
+
+// Number of events buffered for a function registered with Subscribe.
+const subscribeBuffer = 1024
+
+// Subscribe calls f with every event for all keys in the cache, in the order
+// they happened, from a separate goroutine, and returns a function that stops
+// the calls. f may access the cache. cancel waits for a running call to f to
+// return, so f must not call it. Events are buffered while f runs; as with
+// Events, those that don't fit in the buffer are dropped and counted.
+func (c *cache) Subscribe(f func(Event)) (cancel func()) {
+	events, stop := c.Events(subscribeBuffer)
+	return subscribe(events, stop, f)
+}
+
+// Call f with the events from the channel in a new goroutine, and return a
+// function that stops it with stop and waits for it to finish.
+func subscribe(events <-chan Event, stop func(), f func(Event)) (cancel func()) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for e := range events {
+			f(e)
+		}
+	}()
+	return func() {
+		stop()
+		<-done
+	}
+}
+
+// Returns the number of events that were dropped because a subscriber's
+// channel was full.
+func (c *cache) DroppedEvents() uint64 {
+	return atomic.LoadUint64(&c.events.dropped)
+}
@@ -0,0 +1,135 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEvents(t *testing.T) {
+	tc := New(DefaultExpiration, 0)
+	events, stop := tc.Events(10)
+
+	tc.Set("a", 1, DefaultExpiration)
+	tc.Set("b", 2, DefaultExpiration)
+	tc.Delete("a")
+	tc.Set("c", 3, time.Millisecond)
+	<-time.After(5 * time.Millisecond)
+	tc.DeleteExpired()
+	stop()
+
+	want := []Event{
+		{Op: EventSet, Key: "a", Value: 1},
+		{Op: EventSet, Key: "b", Value: 2},
+		{Op: EventDelete, Key: "a", Value: 1},
+		{Op: EventSet, Key: "c", Value: 3},
+		{Op: EventExpire, Key: "c", Value: 3},
+	}
+	var got []Event
+	for e := range events {
+		got = append(got, e)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d events, want %d: %v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("event %d is %v, want %v", i, got[i], want[i])
+		}
+	}
+
+	tc.Set("d", 4, DefaultExpiration)
+	if n := tc.DroppedEvents(); n != 0 {
+		t.Errorf("%d events were dropped after unsubscribing", n)
+	}
+}
+
+func TestEventsDropWhenFull(t *testing.T) {
+	tc := New(DefaultExpiration, 0)
+	events, stop := tc.Events(2)
+	defer stop()
+
+	for i := 0; i < 5; i++ {
+		tc.Set(i, i, DefaultExpiration)
+	}
+	for i := 0; i < 2; i++ {
+		e := <-events
+		if e.Op != EventSet || e.Key != i {
+			t.Errorf("event %d is %v", i, e)
+		}
+	}
+	if n := tc.DroppedEvents(); n != 3 {
+		t.Errorf("DroppedEvents is %d, want 3", n)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	tc := New(DefaultExpiration, 0)
+	var got []Event
+	cancel := tc.Subscribe(func(e Event) {
+		got = append(got, e)
+	})
+
+	tc.Set("a", 1, DefaultExpiration)
+	tc.Set("a", 2, DefaultExpiration)
+	tc.Replace("a", 3, DefaultExpiration)
+	tc.Set("b", 4, DefaultExpiration)
+	tc.Flush()
+	cancel()
+	tc.Set("c", 5, DefaultExpiration)
+
+	want := []Event{
+		{Op: EventSet, Key: "a", Value: 1},
+		{Op: EventReplace, Key: "a", Value: 2, OldValue: 1},
+		{Op: EventReplace, Key: "a", Value: 3, OldValue: 2},
+		{Op: EventSet, Key: "b", Value: 4},
+	}
+	if len(got) != len(want)+2 {
+		t.Fatalf("got %d events, want %d: %v", len(got), len(want)+2, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("event %d is %v, want %v", i, got[i], want[i])
+		}
+	}
+	flushed := map[interface{}]interface{}{}
+	for _, e := range got[len(want):] {
+		if e.Op != EventFlush {
+			t.Errorf("event %v isn't a flush", e)
+		}
+		flushed[e.Key] = e.Value
+	}
+	if flushed["a"] != 3 || flushed["b"] != 4 {
+		t.Error("flushed", flushed)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	tc := New(DefaultExpiration, 0)
+	events, stop := tc.Watch("config")
+
+	tc.Set("config", 1, DefaultExpiration)
+	tc.Set("other", 2, DefaultExpiration)
+	tc.Set("config", 3, time.Millisecond)
+	tc.Delete("other")
+	<-time.After(5 * time.Millisecond)
+	tc.DeleteExpired()
+	stop()
+
+	want := []Event{
+		{Op: EventSet, Key: "config", Value: 1},
+		{Op: EventReplace, Key: "config", Value: 3, OldValue: 1},
+		{Op: EventExpire, Key: "config", Value: 3},
+	}
+	var got []Event
+	for e := range events {
+		got = append(got, e)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d events, want %d: %v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("event %d is %v, want %v", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package cache
+
+// An EvictionReason tells why an item left the cache.
+type EvictionReason int
+
+const (
+	// The item was removed explicitly, e.g. with Delete.
+	ReasonDeleted EvictionReason = iota
+	// The item expired.
+	ReasonExpired
+	// The item was evicted to make room because the cache was full.
+	ReasonCapacity
+	// The item was overwritten with a new value, e.g. by Set or Replace.
+	// Only the function set with OnEvictedWithReason is called for it.
+	ReasonReplaced
+	// The item was removed by Flush.
+	ReasonFlushed
+)
+
+func (r EvictionReason) String() string {
+	switch r {
+	case ReasonDeleted:
+		return "deleted"
+	case ReasonExpired:
+		return "expired"
+	case ReasonCapacity:
+		return "capacity"
+	case ReasonReplaced:
+		return "replaced"
+	case ReasonFlushed:
+		return "flushed"
+	}
+	return "unknown"
+}
+
+// Returns the reason an item removed with the event op left the cache.
+func reasonFor(op EventOp) EvictionReason {
+	switch op {
+	case EventExpire:
+		return ReasonExpired
+	case EventEvict:
+		return ReasonCapacity
+	case EventFlush:
+		return ReasonFlushed
+	}
+	return ReasonDeleted
+}
+
+// An item waiting for the eviction callbacks to be called.
+type eviction struct {
+	key    interface{}
+	value  interface{}
+	reason EvictionReason
+}
+
+// Sets an (optional) function that is called with the key, value and the
+// reason when an item is evicted from the cache, in addition to the function
+// set with OnEvicted. Unlike that function, it is also called with
+// ReasonReplaced when an unexpired item is overwritten. Set to nil to disable.
+func (c *cache) OnEvictedWithReason(f func(k interface{}, v interface{}, reason EvictionReason)) {
+	for _, s := range c.shards {
+		s.OnEvictedWithReason(f)
+	}
+	c.Lock()
+	defer c.Unlock()
+
+	c.onEvictedWithReason = f
+}
+
+// Sets an (optional) function that is called with the key and value when an
+// item expires: when the janitor or DeleteExpired removes it, or when a lookup
+// finds it expired, in which case it is removed right away. Unlike OnEvicted,
+// it isn't called for items that are deleted, flushed, replaced or evicted to
+// make room. Set to nil to disable.
+func (c *cache) OnExpired(f func(k interface{}, v interface{})) {
+	for _, s := range c.shards {
+		s.OnExpired(f)
+	}
+	c.Lock()
+	defer c.Unlock()
+
+	c.onExpired = f
+}
+
+// Remove k if it is an expired item and an OnExpired function is set, so that
+// the function is called for it. Must be called with the write lock held, by a
+// lookup that found k expired.
+func (c *cache) expireStale(k interface{}) {
+	if c.onExpired == nil {
+		return
+	}
+	if item, found := c.items[k]; found && c.expired(item) {
+		c.evict(k, EventExpire)
+	}
+}
+
+// Returns true if removed items must be queued for an eviction callback.
+func (c *cache) hasEvictionCallback() bool {
+	return c.onEvicted != nil || c.onEvictedWithReason != nil || c.onExpired != nil
+}
@@ -0,0 +1,84 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEvictionReasons(t *testing.T) {
+	type evicted struct {
+		k      interface{}
+		v      interface{}
+		reason EvictionReason
+	}
+	var got []evicted
+	var plain int
+	tc := New(DefaultExpiration, 0)
+	tc.OnEvicted(func(k, v interface{}) {
+		plain++
+	})
+	tc.OnEvictedWithReason(func(k, v interface{}, reason EvictionReason) {
+		got = append(got, evicted{k, v, reason})
+	})
+
+	tc.Set("a", 1, DefaultExpiration)
+	tc.Set("a", 2, DefaultExpiration)
+	tc.Replace("a", 3, DefaultExpiration)
+	tc.Delete("a")
+	tc.Set("b", 4, DefaultExpiration)
+	tc.Flush()
+
+	want := []evicted{
+		{"a", 1, ReasonReplaced},
+		{"a", 2, ReasonReplaced},
+		{"a", 3, ReasonDeleted},
+		{"b", 4, ReasonFlushed},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got evictions %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("eviction %d is %v, want %v", i, got[i], want[i])
+		}
+	}
+	if plain != 2 {
+		t.Errorf("OnEvicted was called %d times, want 2", plain)
+	}
+}
+
+func TestOnExpired(t *testing.T) {
+	var expired []interface{}
+	tc := New(DefaultExpiration, 0)
+	tc.OnExpired(func(k, v interface{}) {
+		expired = append(expired, k)
+	})
+
+	tc.Set("a", 1, time.Millisecond)
+	tc.Set("b", 2, time.Millisecond)
+	tc.Set("c", 3, time.Millisecond)
+	tc.Set("d", 4, DefaultExpiration)
+	<-time.After(5 * time.Millisecond)
+
+	if _, found := tc.Get("a"); found {
+		t.Error("Found a when it should have expired")
+	}
+	tc.GetOrLoad("b", func(k interface{}) (interface{}, time.Duration, error) {
+		return 5, DefaultExpiration, nil
+	})
+	tc.Delete("d")
+	tc.DeleteExpired()
+
+	want := []interface{}{"a", "b", "c"}
+	if len(expired) != len(want) {
+		t.Fatalf("OnExpired was called for %v, want %v", expired, want)
+	}
+	for i := range want {
+		if expired[i] != want[i] {
+			t.Errorf("expired item %d is %v, want %v", i, expired[i], want[i])
+		}
+	}
+	if n := tc.ItemCount(); n != 1 {
+		t.Errorf("ItemCount is %d, want 1", n)
+	}
+}
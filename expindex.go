@@ -0,0 +1,84 @@
+package cache
+
+import "container/heap"
+
+// WithExpirationIndex keeps the items that expire in a heap ordered by
+// expiration, so that DeleteExpired and the janitor only visit the items that
+// have expired instead of scanning the whole cache. This costs an entry in the
+// heap for every expiration set, and a little more work on every write; it
+// pays off for large caches with frequent cleanups. Entries for items that
+// are deleted or given a new expiration are left in the heap and skipped when
+// they come up, and the heap is rebuilt when they outnumber the items.
+func WithExpirationIndex() Option {
+	return func(c *cache) {
+		c.expirations = &expirationHeap{}
+	}
+}
+
+// An expiration recorded in the index. It is stale if the item under key no
+// longer has this expiration.
+type expirationEntry struct {
+	key        interface{}
+	expiration int64
+}
+
+type expirationHeap []expirationEntry
+
+func (h expirationHeap) Len() int            { return len(h) }
+func (h expirationHeap) Less(i, j int) bool  { return h[i].expiration < h[j].expiration }
+func (h expirationHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *expirationHeap) Push(x interface{}) { *h = append(*h, x.(expirationEntry)) }
+func (h *expirationHeap) Pop() interface{} {
+	old := *h
+	e := old[len(old)-1]
+	old[len(old)-1] = expirationEntry{}
+	*h = old[:len(old)-1]
+	return e
+}
+
+// Record that k expires at e. Must be called with the write lock held, after
+// k is stored.
+func (c *cache) index(k interface{}, e int64) {
+	if c.expirations == nil || e <= 0 {
+		return
+	}
+	heap.Push(c.expirations, expirationEntry{k, e})
+	if n := c.expirations.Len(); n > 64 && n > 2*len(c.items) {
+		c.rebuildIndex()
+	}
+}
+
+// Rebuild the index from the items, dropping its stale entries.
+func (c *cache) rebuildIndex() {
+	h := make(expirationHeap, 0, len(c.items))
+	for k, v := range c.items {
+		if v.Expiration > 0 {
+			h = append(h, expirationEntry{k, v.Expiration})
+		}
+	}
+	heap.Init(&h)
+	*c.expirations = h
+}
+
+// Remove up to n entries that have expired at now from the index, or all of
+// them if n is less than one, and evict their items. Returns the number of
+// items evicted. Must be called with the write lock held.
+func (c *cache) deleteIndexed(now int64, n int) int {
+	h := c.expirations
+	evicted := 0
+	for h.Len() > 0 && (*h)[0].expiration < now && (n < 1 || evicted < n) {
+		e := heap.Pop(h).(expirationEntry)
+		if v, found := c.items[e.key]; found && v.Expiration == e.expiration {
+			c.evict(e.key, EventExpire)
+			evicted++
+		}
+	}
+	return evicted
+}
+
+// Empty the index. Must be called with the write lock held.
+func (c *cache) resetIndex() {
+	if c.expirations != nil {
+		*c.expirations = expirationHeap{}
+	}
+}
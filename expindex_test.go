@@ -0,0 +1,102 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExpirationIndex(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	tc := New(time.Minute, 0, WithClock(clock), WithExpirationIndex())
+	var expired []interface{}
+	tc.OnExpired(func(k interface{}, v interface{}) {
+		expired = append(expired, k)
+	})
+	tc.Set("a", 1, DefaultExpiration)
+	tc.Set("b", 2, 3*time.Minute)
+	tc.Set("c", 3, NoExpiration)
+	tc.Set("d", 4, DefaultExpiration)
+	tc.Set("d", 4, 5*time.Minute)
+	tc.Set("e", 5, DefaultExpiration)
+	tc.Delete("e")
+
+	clock.Advance(2 * time.Minute)
+	tc.DeleteExpired()
+	if len(expired) != 1 || expired[0] != "a" {
+		t.Errorf("Expired %v, want [a]", expired)
+	}
+	clock.Advance(2 * time.Minute)
+	tc.DeleteExpired()
+	if len(expired) != 2 || expired[1] != "b" {
+		t.Errorf("Expired %v, want [a b]", expired)
+	}
+	for _, k := range []string{"c", "d"} {
+		if _, found := tc.Get(k); !found {
+			t.Errorf("Did not find %s", k)
+		}
+	}
+
+	tc.Flush()
+	tc.Set("f", 6, DefaultExpiration)
+	clock.Advance(2 * time.Minute)
+	tc.DeleteExpired()
+	if n := tc.ItemCount(); n != 0 {
+		t.Errorf("Item count after DeleteExpired is %d, want 0", n)
+	}
+}
+
+func TestExpirationIndexRebuild(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	tc := New(time.Minute, 0, WithClock(clock), WithExpirationIndex())
+	for i := 0; i < 1000; i++ {
+		tc.Set("a", i, time.Duration(i+1)*time.Second)
+	}
+	if n := tc.expirations.Len(); n > 64 {
+		t.Errorf("Index has %d entries for one item", n)
+	}
+	clock.Advance(time.Hour)
+	tc.DeleteExpired()
+	if n := tc.ItemCount(); n != 0 {
+		t.Errorf("Item count after DeleteExpired is %d, want 0", n)
+	}
+}
+
+func TestExpirationIndexBatches(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	tc := New(time.Minute, 0, WithShards(1), WithClock(clock), WithExpirationIndex(), WithJanitorBatchSize(3))
+	for i := 0; i < 10; i++ {
+		tc.Set(i, i, DefaultExpiration)
+	}
+	tc.Set(10, 10, NoExpiration)
+	clock.Advance(2 * time.Minute)
+	tc.janitorDeleteExpired()
+	if n := tc.ItemCount(); n != 1 {
+		t.Errorf("Item count after the janitor ran is %d, want 1", n)
+	}
+}
+
+func TestExpirationIndexConsistencyCheck(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	tc := New(time.Minute, 0, WithShards(1), WithClock(clock), WithExpirationIndex())
+	for i := 0; i < 10; i++ {
+		tc.Set(i, i, time.Duration(i+1)*time.Second)
+	}
+	tc.Set(3, 3, time.Hour)
+	tc.Delete(4)
+	tc.Persist(5)
+	if err := tc.ConsistencyCheck(); err != nil {
+		t.Fatal(err)
+	}
+
+	h := *tc.expirations
+	h[0], h[len(h)-1] = h[len(h)-1], h[0]
+	if err := tc.ConsistencyCheck(); err == nil {
+		t.Error("ConsistencyCheck accepted an index out of heap order")
+	}
+	tc.rebuildIndex()
+	h = *tc.expirations
+	*tc.expirations = h[:len(h)-1]
+	if err := tc.ConsistencyCheck(); err == nil {
+		t.Error("ConsistencyCheck accepted an item missing from the index")
+	}
+}
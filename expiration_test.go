@@ -0,0 +1,134 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCollectExpired(t *testing.T) {
+	tc := New(DefaultExpiration, 0)
+	tc.Set("a", 1, time.Millisecond)
+	tc.Set("b", 2, time.Millisecond)
+	tc.Set("c", 3, DefaultExpiration)
+	<-time.After(5 * time.Millisecond)
+
+	expired := tc.CollectExpired()
+	if len(expired) != 2 {
+		t.Fatalf("Collected %v, want a and b", expired)
+	}
+	if n := tc.ItemCount(); n != 3 {
+		t.Errorf("Item count is %d after collecting, want 3", n)
+	}
+
+	evicted := 0
+	tc.OnEvicted(func(k interface{}, v interface{}) {
+		evicted++
+	})
+	keys := make([]interface{}, len(expired))
+	for i, kv := range expired {
+		if kv.Key == "a" && kv.Value != 1 || kv.Key == "b" && kv.Value != 2 || kv.Key == "c" {
+			t.Errorf("Collected unexpected item %v", kv)
+		}
+		keys[i] = kv.Key
+	}
+	tc.DeleteMany(keys)
+	if n := tc.ItemCount(); n != 1 {
+		t.Errorf("Item count is %d after DeleteMany, want 1", n)
+	}
+	if evicted != 2 {
+		t.Errorf("OnEvicted was called %d times, want 2", evicted)
+	}
+}
+
+func TestEffectiveDefaultExpiration(t *testing.T) {
+	tests := []struct {
+		de   time.Duration
+		want time.Duration
+	}{
+		{NoExpiration, NoExpiration},
+		{DefaultExpiration, NoExpiration},
+		{-time.Second, NoExpiration},
+		{5 * time.Minute, 5 * time.Minute},
+	}
+	for _, tt := range tests {
+		tc := New(tt.de, 0)
+		if got := tc.EffectiveDefaultExpiration(); got != tt.want {
+			t.Errorf("New(%v) has effective default expiration %v, want %v", tt.de, got, tt.want)
+		}
+	}
+}
+
+func TestGetWithExpiration(t *testing.T) {
+	tc := New(DefaultExpiration, 0)
+	tc.Set("a", 1, NoExpiration)
+	tc.Set("b", 2, time.Hour)
+	tc.Set("c", 3, time.Millisecond)
+	<-time.After(5 * time.Millisecond)
+
+	x, e, found := tc.GetWithExpiration("a")
+	if !found || x != 1 || !e.IsZero() {
+		t.Errorf("a is %v, %v, %v", x, e, found)
+	}
+	x, e, found = tc.GetWithExpiration("b")
+	if !found || x != 2 {
+		t.Errorf("b is %v, %v", x, found)
+	}
+	if d := time.Until(e); d <= 59*time.Minute || d > time.Hour {
+		t.Errorf("b expires in %v, want about an hour", d)
+	}
+	if _, _, found := tc.GetWithExpiration("c"); found {
+		t.Error("Found c when it should have expired")
+	}
+}
+
+func TestTTL(t *testing.T) {
+	tc := New(DefaultExpiration, 0)
+	tc.Set("a", 1, NoExpiration)
+	tc.Set("b", 2, time.Hour)
+
+	if d, found := tc.TTL("a"); !found || d != NoExpiration {
+		t.Errorf("TTL of a is %v, %v, want NoExpiration", d, found)
+	}
+	if d, found := tc.TTL("b"); !found || d <= 59*time.Minute || d > time.Hour {
+		t.Errorf("TTL of b is %v, %v, want about an hour", d, found)
+	}
+	if _, found := tc.TTL("c"); found {
+		t.Error("Found a TTL for a missing item")
+	}
+}
+
+func TestTouch(t *testing.T) {
+	tc := New(DefaultExpiration, 0)
+	tc.Set("a", 1, 20*time.Millisecond)
+	<-time.After(10 * time.Millisecond)
+	if !tc.Touch("a", 50*time.Millisecond) {
+		t.Error("Couldn't touch a")
+	}
+	<-time.After(20 * time.Millisecond)
+	if x, found := tc.Get("a"); !found || x != 1 {
+		t.Error("a expired although it was touched:", x)
+	}
+	if !tc.Touch("a", NoExpiration) {
+		t.Error("Couldn't touch a")
+	}
+	if d, _ := tc.TTL("a"); d != NoExpiration {
+		t.Error("a still expires after being touched with NoExpiration:", d)
+	}
+	if tc.Touch("b", time.Minute) {
+		t.Error("Touched a missing item")
+	}
+}
+
+func TestPersist(t *testing.T) {
+	tc := New(50*time.Millisecond, 0)
+	tc.Set("a", 1, DefaultExpiration)
+	if !tc.Persist("a") {
+		t.Error("Couldn't persist a")
+	}
+	if d, _ := tc.TTL("a"); d != NoExpiration {
+		t.Error("a still expires after Persist:", d)
+	}
+	if tc.Persist("b") {
+		t.Error("Persisted a missing item")
+	}
+}
@@ -0,0 +1,36 @@
+package cache
+
+import "expvar"
+
+// WithExpvar publishes the cache's Stats under name with the expvar package,
+// so they are served at /debug/vars along with the other exported variables.
+// The published value is an object with the item count, hit ratio and the
+// counters of Stats. As with expvar.Publish, name must be unique in the
+// process: creating a second cache with the same name panics. A published
+// cache is never garbage collected.
+func WithExpvar(name string) Option {
+	return func(c *cache) {
+		c.expvarName = name
+	}
+}
+
+// Publish the statistics returned by stats under name.
+func publishStats(name string, stats func() Stats) {
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		s := stats()
+		return map[string]interface{}{
+			"items":       s.Items,
+			"hitRatio":    s.HitRatio(),
+			"hits":        s.Hits,
+			"misses":      s.Misses,
+			"sets":        s.Sets,
+			"deletes":     s.Deletes,
+			"expirations": s.Expirations,
+			"evictions":   s.Evictions,
+			"rejections":  s.Rejections,
+			"loads":       s.Loads,
+			"loadErrors":  s.LoadErrors,
+			"loadSeconds": s.LoadTime.Seconds(),
+		}
+	}))
+}
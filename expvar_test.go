@@ -0,0 +1,52 @@
+package cache
+
+import (
+	"encoding/json"
+	"expvar"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+var expvarRuns int32
+
+// Returns a name to publish under that no earlier run of the test used, as
+// expvar.Publish panics on a duplicate name with go test -count.
+func expvarName(t *testing.T) string {
+	return t.Name() + strconv.Itoa(int(atomic.AddInt32(&expvarRuns, 1)))
+}
+
+func TestWithExpvar(t *testing.T) {
+	name := expvarName(t)
+	tc := New(DefaultExpiration, 0, WithExpvar(name))
+	tc.Set("a", 1, DefaultExpiration)
+	tc.Get("a")
+	tc.Get("b")
+
+	v := expvar.Get(name)
+	if v == nil {
+		t.Fatal("stats weren't published")
+	}
+	var got map[string]float64
+	if err := json.Unmarshal([]byte(v.String()), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got["items"] != 1 || got["hits"] != 1 || got["misses"] != 1 || got["hitRatio"] != 0.5 {
+		t.Error("published stats are", v.String())
+	}
+}
+
+func TestShardedWithExpvar(t *testing.T) {
+	name := expvarName(t)
+	sc := New(DefaultExpiration, 0, WithShards(4), WithExpvar(name))
+	for i := 0; i < 10; i++ {
+		sc.Set(i, i, DefaultExpiration)
+	}
+	var got map[string]float64
+	if err := json.Unmarshal([]byte(expvar.Get(name).String()), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got["items"] != 10 {
+		t.Error("published stats are", got)
+	}
+}
@@ -0,0 +1,212 @@
+package cache
+
+import "container/list"
+
+// WithFIFOEviction makes the cache evict the item whose key was stored first
+// when it is full, instead of the least recently used one. Lookups don't
+// change the order, so the policy does no work for them. Storing a new value
+// under a key already in the cache keeps its place. See WithMaxEntries and
+// WithMaxCost.
+func WithFIFOEviction() Option {
+	return func(c *cache) {
+		c.newPolicy = func(int) EvictionPolicy {
+			return newFIFOPolicy()
+		}
+	}
+}
+
+// NewFIFOPolicy returns the policy used by WithFIFOEviction, for use with
+// WithEvictionPolicy.
+func NewFIFOPolicy() EvictionPolicy {
+	return newFIFOPolicy()
+}
+
+// Keys in the order they were first stored, oldest last.
+type fifoPolicy struct {
+	keys    *list.List
+	entries map[interface{}]*list.Element
+}
+
+func newFIFOPolicy() *fifoPolicy {
+	return &fifoPolicy{
+		keys:    list.New(),
+		entries: map[interface{}]*list.Element{},
+	}
+}
+
+func (p *fifoPolicy) RecordInsert(k interface{}) {
+	if _, found := p.entries[k]; !found {
+		p.entries[k] = p.keys.PushFront(k)
+	}
+}
+
+func (p *fifoPolicy) RecordAccess(interface{}) {}
+
+func (p *fifoPolicy) Remove(k interface{}) {
+	if e, found := p.entries[k]; found {
+		p.keys.Remove(e)
+		delete(p.entries, k)
+	}
+}
+
+func (p *fifoPolicy) Victim() (interface{}, bool) {
+	if back := p.keys.Back(); back != nil {
+		return back.Value, true
+	}
+	return nil, false
+}
+
+// WithS3FIFOEviction makes the cache evict items as picked by S3-FIFO when it
+// is full, instead of the least recently used one. New keys enter a small FIFO
+// queue holding about a tenth of the items, and only those looked up again
+// before they reach its end move to the main FIFO queue; the others are
+// evicted, so keys used once leave quickly. Items at the end of the main queue
+// that were looked up go round again. Keys recently evicted from the small
+// queue are remembered, and go straight to the main queue if stored again. A
+// lookup only bumps a small counter, so the policy does almost no work for
+// it. The evicted keys remembered are bounded by the limit set with
+// WithMaxEntries, or by the number of items in the cache if there is none.
+// See WithMaxEntries and WithMaxCost.
+func WithS3FIFOEviction() Option {
+	return func(c *cache) {
+		c.newPolicy = func(maxEntries int) EvictionPolicy {
+			return newS3FIFOPolicy(maxEntries)
+		}
+	}
+}
+
+// NewS3FIFOPolicy returns the policy used by WithS3FIFOEviction, sized for
+// capacity items, or for the number of items in the cache if capacity is
+// zero. It is for use with WithEvictionPolicy.
+func NewS3FIFOPolicy(capacity int) EvictionPolicy {
+	return newS3FIFOPolicy(capacity)
+}
+
+// The queues of S3-FIFO: the small and main queues of keys in the cache, and
+// the ghost queue of keys recently evicted from the small queue. Each holds
+// its newest key first.
+const (
+	s3Small = iota
+	s3Main
+	s3Ghost
+)
+
+// How often a key in the cache was looked up is counted up to this.
+const s3MaxFreq = 3
+
+type s3FIFOPolicy struct {
+	// The number of items S3-FIFO is sized for, or 0 to follow the number
+	// of items in the cache.
+	capacity int
+	queues   [3]*list.List
+	entries  map[interface{}]*s3Entry
+}
+
+type s3Entry struct {
+	queue int
+	freq  int
+	elem  *list.Element
+}
+
+func newS3FIFOPolicy(capacity int) *s3FIFOPolicy {
+	if capacity < 0 {
+		capacity = 0
+	}
+	p := &s3FIFOPolicy{
+		capacity: capacity,
+		entries:  map[interface{}]*s3Entry{},
+	}
+	for i := range p.queues {
+		p.queues[i] = list.New()
+	}
+	return p
+}
+
+// Returns the number of items S3-FIFO is sized for.
+func (p *s3FIFOPolicy) size() int {
+	if p.capacity > 0 {
+		return p.capacity
+	}
+	if n := p.queues[s3Small].Len() + p.queues[s3Main].Len(); n > 0 {
+		return n
+	}
+	return 1
+}
+
+// Move k, which is tracked, to the front of queue q.
+func (p *s3FIFOPolicy) move(k interface{}, e *s3Entry, q int) {
+	p.queues[e.queue].Remove(e.elem)
+	e.queue = q
+	e.elem = p.queues[q].PushFront(k)
+}
+
+func (p *s3FIFOPolicy) RecordInsert(k interface{}) {
+	e, found := p.entries[k]
+	switch {
+	case !found:
+		p.entries[k] = &s3Entry{queue: s3Small, elem: p.queues[s3Small].PushFront(k)}
+	case e.queue == s3Ghost:
+		e.freq = 0
+		p.move(k, e, s3Main)
+	default:
+		p.RecordAccess(k)
+	}
+}
+
+func (p *s3FIFOPolicy) RecordAccess(k interface{}) {
+	if e, found := p.entries[k]; found && e.queue != s3Ghost && e.freq < s3MaxFreq {
+		e.freq++
+	}
+}
+
+// Remembers k as evicted if it is in the small queue, and forgets it
+// otherwise.
+func (p *s3FIFOPolicy) Remove(k interface{}) {
+	e, found := p.entries[k]
+	if !found {
+		return
+	}
+	switch e.queue {
+	case s3Small:
+		p.move(k, e, s3Ghost)
+		for p.queues[s3Ghost].Len() > p.size() {
+			back := p.queues[s3Ghost].Back()
+			p.queues[s3Ghost].Remove(back)
+			delete(p.entries, back.Value)
+		}
+	case s3Main:
+		p.queues[s3Main].Remove(e.elem)
+		delete(p.entries, k)
+	}
+}
+
+// Returns the end of the small queue, once the keys there that were looked up
+// are moved to the main queue, if the small queue holds its share of the
+// items. Otherwise returns the end of the main queue, once the keys there that
+// were looked up go round again with one use fewer.
+func (p *s3FIFOPolicy) Victim() (interface{}, bool) {
+	small, main := p.queues[s3Small], p.queues[s3Main]
+	target := p.size() / 10
+	if target < 1 {
+		target = 1
+	}
+	for small.Len() > 0 && (small.Len() >= target || main.Len() == 0) {
+		k := small.Back().Value
+		e := p.entries[k]
+		if e.freq == 0 {
+			return k, true
+		}
+		e.freq = 0
+		p.move(k, e, s3Main)
+	}
+	for main.Len() > 0 {
+		k := main.Back().Value
+		e := p.entries[k]
+		if e.freq == 0 {
+			return k, true
+		}
+		e.freq--
+		p.move(k, e, s3Main)
+	}
+	return nil, false
+}
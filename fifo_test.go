@@ -0,0 +1,113 @@
+package cache
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestFIFOPolicy(t *testing.T) {
+	p := newFIFOPolicy()
+	if _, found := p.Victim(); found {
+		t.Error("Empty policy returned a victim")
+	}
+	for _, k := range []string{"a", "b", "c"} {
+		p.RecordInsert(k)
+	}
+	p.RecordAccess("a")
+	p.RecordInsert("a")
+	for _, want := range []string{"a", "b", "c"} {
+		k, found := p.Victim()
+		if !found || k != want {
+			t.Fatalf("Victim is %v, want %s", k, want)
+		}
+		p.Remove(k)
+	}
+	if len(p.entries) != 0 || p.keys.Len() != 0 {
+		t.Errorf("Policy still tracks %d keys", len(p.entries))
+	}
+}
+
+func TestFIFOEviction(t *testing.T) {
+	tc := New(DefaultExpiration, 0, WithMaxEntries(2), WithFIFOEviction())
+	tc.Set("a", 1, DefaultExpiration)
+	tc.Set("b", 2, DefaultExpiration)
+	tc.Get("a")
+	tc.Set("c", 3, DefaultExpiration)
+	if _, found := tc.Get("a"); found {
+		t.Error("a, stored first, was not evicted")
+	}
+	for _, k := range []string{"b", "c"} {
+		if _, found := tc.Get(k); !found {
+			t.Errorf("%s was evicted", k)
+		}
+	}
+	if err := tc.ConsistencyCheck(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestS3FIFOPolicy(t *testing.T) {
+	p := newS3FIFOPolicy(10)
+	if _, found := p.Victim(); found {
+		t.Error("Empty policy returned a victim")
+	}
+	p.RecordInsert("a")
+	p.RecordInsert("b")
+	p.RecordAccess("a")
+	// a was looked up, so it moves to the main queue.
+	if k, _ := p.Victim(); k != "b" {
+		t.Errorf("Victim is %v, want b", k)
+	}
+	if p.entries["a"].queue != s3Main {
+		t.Error("a is not in the main queue")
+	}
+	p.Remove("b")
+	if p.entries["b"].queue != s3Ghost {
+		t.Error("Evicted key b is not remembered")
+	}
+	p.RecordInsert("b")
+	if p.entries["b"].queue != s3Main {
+		t.Error("b, stored again, is not in the main queue")
+	}
+
+	// Keys in the main queue that were looked up go round again.
+	p.RecordAccess("a")
+	if k, _ := p.Victim(); k != "b" {
+		t.Errorf("Victim is %v, want b", k)
+	}
+	p.Remove("b")
+	if _, found := p.entries["b"]; found {
+		t.Error("b, removed from the main queue, is still tracked")
+	}
+
+	// Ghosts are bounded.
+	for i := 0; i < 100; i++ {
+		k := fmt.Sprint(i)
+		p.RecordInsert(k)
+		p.Remove(k)
+	}
+	if n := p.queues[s3Ghost].Len(); n > 10 {
+		t.Errorf("Policy remembers %d evicted keys, want at most 10", n)
+	}
+}
+
+func TestS3FIFOEviction(t *testing.T) {
+	tc := New(DefaultExpiration, 0, WithMaxEntries(3), WithS3FIFOEviction())
+	tc.Set("a", 1, DefaultExpiration)
+	tc.Set("b", 2, DefaultExpiration)
+	tc.Get("a")
+	tc.Get("b")
+	// A scan of keys used once doesn't evict a or b.
+	for i := 0; i < 10; i++ {
+		tc.Set(fmt.Sprint("scan", i), i, DefaultExpiration)
+		tc.Get("a")
+	}
+	for _, k := range []string{"a", "b", "scan9"} {
+		if _, found := tc.Get(k); !found {
+			t.Errorf("%s was evicted", k)
+		}
+	}
+	if err := tc.ConsistencyCheck(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,15 @@
+module github.com/rumsrami/cache/groupcacheadapter
+
+go 1.25.0
+
+require (
+	github.com/golang/groupcache v0.0.0-20241129210726-2c02b8208cf8
+	github.com/rumsrami/cache v0.0.0-20261016120748-252007477c0e
+)
+
+require (
+	github.com/golang/protobuf v1.5.4 // indirect
+	google.golang.org/protobuf v1.36.11 // indirect
+)
+
+replace github.com/rumsrami/cache => ../
@@ -0,0 +1,77 @@
+// Package groupcacheadapter connects a cache with groupcache, in both
+// directions. Getter puts a cache in front of a groupcache.Getter, so that it
+// serves as a local hot cache in an existing groupcache deployment:
+//
+//	c := cache.New(time.Minute, 10*time.Minute)
+//	group := groupcache.NewGroup("users", 64<<20, groupcacheadapter.Getter(c, time.Minute, db))
+//
+// Loader goes the other way, loading the keys missing from a cache from a
+// groupcache.Group:
+//
+//	v, err := c.GetOrLoadContext(ctx, "alice", groupcacheadapter.Loader(group, time.Minute))
+//
+// Values are stored in the cache as []byte. groupcache's Sink interface can
+// only be implemented by groupcache itself, so the adapters go through its
+// AllocatingByteSliceSink.
+//
+// It lives in its own module so that the cache package doesn't depend on
+// groupcache.
+package groupcacheadapter
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/golang/groupcache"
+)
+
+// A Cache holds the values, such as a *cache.Cache.
+type Cache interface {
+	GetOrLoadContext(ctx context.Context, k interface{}, load func(ctx context.Context, k interface{}) (interface{}, time.Duration, error)) (interface{}, error)
+}
+
+// A Group is a source of values, such as a *groupcache.Group.
+type Group interface {
+	Get(ctx context.Context, key string, dest groupcache.Sink) error
+}
+
+// Getter returns a groupcache.Getter serving keys from c, which loads the
+// missing ones from next and stores them for d. Concurrent gets of the same
+// missing key share one call to next, and the options of c such as
+// WithLoadRetry apply.
+func Getter(c Cache, d time.Duration, next groupcache.Getter) groupcache.Getter {
+	return groupcache.GetterFunc(func(ctx context.Context, key string, dest groupcache.Sink) error {
+		x, err := c.GetOrLoadContext(ctx, key, get(next, d))
+		if err != nil {
+			return err
+		}
+		b, ok := x.([]byte)
+		if !ok {
+			return fmt.Errorf("groupcacheadapter: value of %q has type %T, not []byte", key, x)
+		}
+		return dest.SetBytes(b)
+	})
+}
+
+// Loader returns a loader for the GetOrLoadContext method of a cache that gets
+// the values of missing keys from g and stores them for d. Keys must be
+// strings.
+func Loader(g Group, d time.Duration) func(ctx context.Context, k interface{}) (interface{}, time.Duration, error) {
+	return get(g, d)
+}
+
+// Returns a loader getting values from g.
+func get(g Group, d time.Duration) func(ctx context.Context, k interface{}) (interface{}, time.Duration, error) {
+	return func(ctx context.Context, k interface{}) (interface{}, time.Duration, error) {
+		key, ok := k.(string)
+		if !ok {
+			return nil, 0, fmt.Errorf("groupcacheadapter: key %v has type %T, not string", k, k)
+		}
+		var b []byte
+		if err := g.Get(ctx, key, groupcache.AllocatingByteSliceSink(&b)); err != nil {
+			return nil, 0, err
+		}
+		return b, d, nil
+	}
+}
@@ -0,0 +1,76 @@
+package groupcacheadapter
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang/groupcache"
+	"github.com/rumsrami/cache"
+)
+
+func TestGetter(t *testing.T) {
+	ctx := context.Background()
+	var calls int32
+	db := groupcache.GetterFunc(func(ctx context.Context, key string, dest groupcache.Sink) error {
+		atomic.AddInt32(&calls, 1)
+		if key == "missing" {
+			return errors.New("not found")
+		}
+		return dest.SetString("value of " + key)
+	})
+	c := cache.New(cache.NoExpiration, 0)
+	g := Getter(c, time.Minute, db)
+
+	for i := 0; i < 2; i++ {
+		var s string
+		if err := g.Get(ctx, "a", groupcache.StringSink(&s)); err != nil {
+			t.Fatal(err)
+		}
+		if s != "value of a" {
+			t.Errorf("got %q, want value of a", s)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("got %d calls, want 1", calls)
+	}
+	if x, _ := c.Get("a"); string(x.([]byte)) != "value of a" {
+		t.Errorf("cache has %q", x)
+	}
+	if ttl, _ := c.TTL("a"); ttl <= 59*time.Second {
+		t.Errorf("got TTL %v, want a minute", ttl)
+	}
+	var s string
+	if err := g.Get(ctx, "missing", groupcache.StringSink(&s)); err == nil {
+		t.Error("got no error for a missing key")
+	}
+
+	// In a group.
+	group := groupcache.NewGroup("TestGetter", 1<<20, Getter(c, time.Minute, db))
+	if err := group.Get(ctx, "b", groupcache.StringSink(&s)); err != nil || s != "value of b" {
+		t.Errorf("got %q, %v, want value of b", s, err)
+	}
+}
+
+func TestLoader(t *testing.T) {
+	ctx := context.Background()
+	group := groupcache.NewGroup("TestLoader", 1<<20, groupcache.GetterFunc(func(ctx context.Context, key string, dest groupcache.Sink) error {
+		return dest.SetBytes([]byte("value of " + key))
+	}))
+	c := cache.New(cache.NoExpiration, 0)
+	x, err := c.GetOrLoadContext(ctx, "a", Loader(group, time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(x.([]byte)) != "value of a" {
+		t.Errorf("got %q, want value of a", x)
+	}
+	if ttl, _ := c.TTL("a"); ttl <= 59*time.Second {
+		t.Errorf("got TTL %v, want a minute", ttl)
+	}
+	if _, err := c.GetOrLoadContext(ctx, 1, Loader(group, time.Minute)); err == nil {
+		t.Error("got no error for a key that isn't a string")
+	}
+}
@@ -0,0 +1,249 @@
+// Package grpccache serves a cache over gRPC, so that several processes, such
+// as sidecars in the same pod, can share one in-memory cache. The service,
+// declared in cache.proto, stores byte values under string keys.
+//
+//	c := cache.New(5*time.Minute, 10*time.Minute)
+//	s := grpc.NewServer(grpccache.ServerOption())
+//	grpccache.NewServer(c, nil).Register(s)
+//
+// The package encodes its messages itself instead of using generated code. A
+// gRPC server serving it must be created with ServerOption, which leaves the
+// messages of other services to the standard codec; the Client sets its codec
+// on each call. Clients generated from cache.proto work as usual.
+//
+// It lives in its own module so that the cache package doesn't depend on
+// gRPC.
+package grpccache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rumsrami/cache"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/encoding/proto"
+	"google.golang.org/grpc/mem"
+	"google.golang.org/grpc/status"
+)
+
+// The full name of the service.
+const ServiceName = "rumsrami.cache.v1.Cache"
+
+// A Cache holds the items served, such as a *cache.Cache. Values are stored
+// as []byte.
+type Cache interface {
+	Get(k interface{}) (interface{}, bool)
+	Set(k interface{}, x interface{}, d time.Duration)
+	Delete(k interface{})
+	GetOrLoadContext(ctx context.Context, k interface{}, load func(ctx context.Context, k interface{}) (interface{}, time.Duration, error)) (interface{}, error)
+}
+
+// A Loader loads the value of a missing key for GetOrLoad, returning it with
+// the duration to store it for.
+type Loader func(ctx context.Context, key string) ([]byte, time.Duration, error)
+
+// Encodes the service's messages, and others with the standard codec.
+type codec struct{}
+
+func (codec) Name() string {
+	return proto.Name
+}
+
+func (codec) Marshal(v any) (mem.BufferSlice, error) {
+	if m, ok := v.(message); ok {
+		return mem.BufferSlice{mem.SliceBuffer(m.marshal())}, nil
+	}
+	return standardCodec().Marshal(v)
+}
+
+func (codec) Unmarshal(data mem.BufferSlice, v any) error {
+	if m, ok := v.(message); ok {
+		return m.unmarshal(data.Materialize())
+	}
+	return standardCodec().Unmarshal(data, v)
+}
+
+func standardCodec() encoding.CodecV2 {
+	return encoding.GetCodecV2(proto.Name)
+}
+
+// ServerOption returns the option a gRPC server serving the cache must be
+// created with.
+func ServerOption() grpc.ServerOption {
+	return grpc.ForceServerCodecV2(codec{})
+}
+
+// A Server implements the service for a cache.
+type Server struct {
+	c    Cache
+	load Loader
+}
+
+// NewServer returns a server for c. GetOrLoad loads missing keys with load; if
+// it is nil, GetOrLoad fails with codes.Unimplemented.
+func NewServer(c Cache, load Loader) *Server {
+	return &Server{c: c, load: load}
+}
+
+// Register the service with s, which must have been created with
+// ServerOption.
+func (s *Server) Register(r grpc.ServiceRegistrar) {
+	r.RegisterService(&serviceDesc, s)
+}
+
+func (s *Server) get(ctx context.Context, req *getRequest) (*getResponse, error) {
+	x, found := s.c.Get(req.key)
+	if !found {
+		return &getResponse{}, nil
+	}
+	v, ok := x.([]byte)
+	if !ok {
+		return nil, status.Errorf(codes.FailedPrecondition, "value of %q is a %T, not []byte", req.key, x)
+	}
+	return &getResponse{value: v, found: true}, nil
+}
+
+func (s *Server) set(ctx context.Context, req *setRequest) (*setResponse, error) {
+	d := cache.DefaultExpiration
+	switch {
+	case req.ttlMs < 0:
+		d = cache.NoExpiration
+	case req.ttlMs > 0:
+		d = time.Duration(req.ttlMs) * time.Millisecond
+	}
+	s.c.Set(req.key, req.value, d)
+	return &setResponse{}, nil
+}
+
+func (s *Server) delete(ctx context.Context, req *deleteRequest) (*deleteResponse, error) {
+	s.c.Delete(req.key)
+	return &deleteResponse{}, nil
+}
+
+func (s *Server) getOrLoad(ctx context.Context, req *getRequest) (*getResponse, error) {
+	if s.load == nil {
+		return nil, status.Error(codes.Unimplemented, "the server has no loader")
+	}
+	x, err := s.c.GetOrLoadContext(ctx, req.key, func(ctx context.Context, k interface{}) (interface{}, time.Duration, error) {
+		return s.load(ctx, k.(string))
+	})
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return nil, status.FromContextError(err).Err()
+		}
+		if _, ok := status.FromError(err); ok {
+			return nil, err
+		}
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	v, ok := x.([]byte)
+	if !ok {
+		return nil, status.Errorf(codes.FailedPrecondition, "value of %q is a %T, not []byte", req.key, x)
+	}
+	return &getResponse{value: v, found: true}, nil
+}
+
+// Returns a handler calling method with the decoded request.
+func handler[Req any, Resp any, PReq interface {
+	*Req
+	message
+}](name string, method func(*Server, context.Context, PReq) (Resp, error)) grpc.MethodDesc {
+	return grpc.MethodDesc{
+		MethodName: name,
+		Handler: func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+			req := PReq(new(Req))
+			if err := dec(req); err != nil {
+				return nil, err
+			}
+			s := srv.(*Server)
+			if interceptor == nil {
+				return method(s, ctx, req)
+			}
+			info := &grpc.UnaryServerInfo{Server: s, FullMethod: fmt.Sprintf("/%s/%s", ServiceName, name)}
+			return interceptor(ctx, req, info, func(ctx context.Context, req any) (any, error) {
+				return method(s, ctx, req.(PReq))
+			})
+		},
+	}
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*any)(nil),
+	Methods: []grpc.MethodDesc{
+		handler("Get", (*Server).get),
+		handler("Set", (*Server).set),
+		handler("Delete", (*Server).delete),
+		handler("GetOrLoad", (*Server).getOrLoad),
+	},
+	Metadata: "cache.proto",
+}
+
+// A Client calls the service.
+type Client struct {
+	cc grpc.ClientConnInterface
+}
+
+// NewClient returns a client calling the service over cc.
+func NewClient(cc grpc.ClientConnInterface) *Client {
+	return &Client{cc}
+}
+
+func (c *Client) invoke(ctx context.Context, method string, req, resp message) error {
+	return c.cc.Invoke(ctx, "/"+ServiceName+"/"+method, req, resp, grpc.ForceCodecV2(codec{}))
+}
+
+// Get returns the value stored under key, and whether it was found.
+func (c *Client) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	resp := &getResponse{}
+	if err := c.invoke(ctx, "Get", &getRequest{key}, resp); err != nil {
+		return nil, false, err
+	}
+	return resp.value, resp.found, nil
+}
+
+// Set stores value under key for d, which may be cache.DefaultExpiration or
+// cache.NoExpiration. Durations are sent in whole milliseconds.
+func (c *Client) Set(ctx context.Context, key string, value []byte, d time.Duration) error {
+	ttl := d.Milliseconds()
+	switch {
+	case d == cache.DefaultExpiration:
+		ttl = 0
+	case d < 0:
+		ttl = -1
+	case ttl == 0:
+		ttl = 1
+	}
+	return c.invoke(ctx, "Set", &setRequest{key, value, ttl}, &setResponse{})
+}
+
+// Delete deletes the item under key.
+func (c *Client) Delete(ctx context.Context, key string) error {
+	return c.invoke(ctx, "Delete", &deleteRequest{key}, &deleteResponse{})
+}
+
+// Unavailable reports whether err, returned by c, means that the server could
+// not be reached or did not answer in time, that is whether its code is
+// Unavailable or DeadlineExceeded. A cachepool.Pool uses it to tell failing
+// nodes.
+func (c *Client) Unavailable(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return true
+	}
+	return false
+}
+
+// GetOrLoad returns the value stored under key, which the server loads if it
+// is missing.
+func (c *Client) GetOrLoad(ctx context.Context, key string) ([]byte, error) {
+	resp := &getResponse{}
+	if err := c.invoke(ctx, "GetOrLoad", &getRequest{key}, resp); err != nil {
+		return nil, err
+	}
+	return resp.value, nil
+}
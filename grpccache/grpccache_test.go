@@ -0,0 +1,128 @@
+package grpccache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/rumsrami/cache"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// Returns a client of a server for c, both stopped when the test ends.
+func serve(t *testing.T, c Cache, load Loader) *Client {
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer(ServerOption())
+	NewServer(c, load).Register(s)
+	go s.Serve(lis)
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+		s.Stop()
+	})
+	return NewClient(conn)
+}
+
+func TestServer(t *testing.T) {
+	c := cache.New(cache.DefaultExpiration, 0)
+	client := serve(t, c, nil)
+	ctx := context.Background()
+
+	if _, found, err := client.Get(ctx, "a"); err != nil || found {
+		t.Fatalf("Get of a missing key returned %v, %v", found, err)
+	}
+	if err := client.Set(ctx, "a", []byte("hello"), time.Minute); err != nil {
+		t.Fatal("Set returned", err)
+	}
+	v, found, err := client.Get(ctx, "a")
+	if err != nil || !found || string(v) != "hello" {
+		t.Fatalf("Get returned %q, %v, %v", v, found, err)
+	}
+	if ttl, _ := c.TTL("a"); ttl <= 59*time.Second || ttl > time.Minute {
+		t.Errorf("TTL of a is %v, want 1m", ttl)
+	}
+	client.Set(ctx, "b", nil, cache.NoExpiration)
+	if ttl, _ := c.TTL("b"); ttl != cache.NoExpiration {
+		t.Errorf("TTL of b is %v, want NoExpiration", ttl)
+	}
+	if err := client.Delete(ctx, "a"); err != nil {
+		t.Fatal("Delete returned", err)
+	}
+	if _, found := c.Get("a"); found {
+		t.Error("Delete did not delete a")
+	}
+	if _, err := client.GetOrLoad(ctx, "a"); status.Code(err) != codes.Unimplemented {
+		t.Errorf("GetOrLoad without a loader returned %v", err)
+	}
+}
+
+func TestServerGetOrLoad(t *testing.T) {
+	c := cache.New(cache.DefaultExpiration, 0)
+	loads := 0
+	client := serve(t, c, func(ctx context.Context, key string) ([]byte, time.Duration, error) {
+		loads++
+		if key == "bad" {
+			return nil, 0, errors.New("no such key")
+		}
+		return []byte(key + "!"), cache.NoExpiration, nil
+	})
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		v, err := client.GetOrLoad(ctx, "a")
+		if err != nil || !bytes.Equal(v, []byte("a!")) {
+			t.Fatalf("GetOrLoad returned %q, %v", v, err)
+		}
+	}
+	if loads != 1 {
+		t.Errorf("Loaded %d times, want once", loads)
+	}
+	if _, err := client.GetOrLoad(ctx, "bad"); status.Code(err) != codes.Unavailable {
+		t.Errorf("GetOrLoad with a failing loader returned %v", err)
+	}
+}
+
+func TestClientUnavailable(t *testing.T) {
+	client := serve(t, cache.New(cache.DefaultExpiration, 0), nil)
+	_, err := client.GetOrLoad(context.Background(), "a")
+	if client.Unavailable(err) {
+		t.Errorf("Unavailable(%v) is true", err)
+	}
+	for _, code := range []codes.Code{codes.Unavailable, codes.DeadlineExceeded} {
+		if err := status.Error(code, "down"); !client.Unavailable(err) {
+			t.Errorf("Unavailable(%v) is false", err)
+		}
+	}
+}
+
+func TestMessages(t *testing.T) {
+	in := &setRequest{key: "k", value: []byte{0, 1, 2}, ttlMs: -1}
+	out := &setRequest{}
+	if err := out.unmarshal(in.marshal()); err != nil {
+		t.Fatal(err)
+	}
+	if out.key != in.key || !bytes.Equal(out.value, in.value) || out.ttlMs != in.ttlMs {
+		t.Errorf("Decoded %+v, want %+v", out, in)
+	}
+	// Unknown fields are skipped.
+	b := append(in.marshal(), 0x20, 0x05)
+	if err := (&getRequest{}).unmarshal(b); err != nil {
+		t.Error("Decoding unknown fields failed:", err)
+	}
+	if err := (&getRequest{}).unmarshal([]byte{0x0a, 0x05, 'a'}); err == nil {
+		t.Error("Decoded a truncated message")
+	}
+}
@@ -0,0 +1,172 @@
+package grpccache
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// The messages of the service, as declared in cache.proto. They encode
+// themselves, so that no generated code is needed.
+type message interface {
+	marshal() []byte
+	unmarshal(b []byte) error
+}
+
+type getRequest struct {
+	key string
+}
+
+type getResponse struct {
+	value []byte
+	found bool
+}
+
+type setRequest struct {
+	key   string
+	value []byte
+	ttlMs int64
+}
+
+type setResponse struct{}
+
+type deleteRequest struct {
+	key string
+}
+
+type deleteResponse struct{}
+
+func (m *getRequest) marshal() []byte {
+	return appendString(nil, 1, m.key)
+}
+
+func (m *getRequest) unmarshal(b []byte) error {
+	return parse(b, func(num protowire.Number, typ protowire.Type, b []byte) int {
+		if num == 1 && typ == protowire.BytesType {
+			v, n := protowire.ConsumeString(b)
+			m.key = v
+			return n
+		}
+		return -1
+	})
+}
+
+func (m *getResponse) marshal() []byte {
+	var b []byte
+	if len(m.value) > 0 {
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendBytes(b, m.value)
+	}
+	if m.found {
+		b = protowire.AppendTag(b, 2, protowire.VarintType)
+		b = protowire.AppendVarint(b, 1)
+	}
+	return b
+}
+
+func (m *getResponse) unmarshal(b []byte) error {
+	return parse(b, func(num protowire.Number, typ protowire.Type, b []byte) int {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			m.value = append([]byte(nil), v...)
+			return n
+		case num == 2 && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			m.found = v != 0
+			return n
+		}
+		return -1
+	})
+}
+
+func (m *setRequest) marshal() []byte {
+	b := appendString(nil, 1, m.key)
+	if len(m.value) > 0 {
+		b = protowire.AppendTag(b, 2, protowire.BytesType)
+		b = protowire.AppendBytes(b, m.value)
+	}
+	if m.ttlMs != 0 {
+		b = protowire.AppendTag(b, 3, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(m.ttlMs))
+	}
+	return b
+}
+
+func (m *setRequest) unmarshal(b []byte) error {
+	return parse(b, func(num protowire.Number, typ protowire.Type, b []byte) int {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			v, n := protowire.ConsumeString(b)
+			m.key = v
+			return n
+		case num == 2 && typ == protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			m.value = append([]byte(nil), v...)
+			return n
+		case num == 3 && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			m.ttlMs = int64(v)
+			return n
+		}
+		return -1
+	})
+}
+
+func (m *setResponse) marshal() []byte { return nil }
+
+func (m *setResponse) unmarshal(b []byte) error { return parse(b, nil) }
+
+func (m *deleteRequest) marshal() []byte {
+	return appendString(nil, 1, m.key)
+}
+
+func (m *deleteRequest) unmarshal(b []byte) error {
+	return parse(b, func(num protowire.Number, typ protowire.Type, b []byte) int {
+		if num == 1 && typ == protowire.BytesType {
+			v, n := protowire.ConsumeString(b)
+			m.key = v
+			return n
+		}
+		return -1
+	})
+}
+
+func (m *deleteResponse) marshal() []byte { return nil }
+
+func (m *deleteResponse) unmarshal(b []byte) error { return parse(b, nil) }
+
+// Appends the string field num, unless s is empty.
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+// Parse the fields of a message, passing each one's number, type and the
+// bytes following its tag to field, which returns the length of the value it
+// consumed, or -1 to skip an unknown field or report a malformed one. field
+// may be nil if the message has no fields.
+func parse(b []byte, field func(num protowire.Number, typ protowire.Type, b []byte) int) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return fmt.Errorf("grpccache: %v", protowire.ParseError(n))
+		}
+		b = b[n:]
+		n = -1
+		if field != nil {
+			n = field(num, typ, b)
+		}
+		if n == -1 {
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return fmt.Errorf("grpccache: %v", protowire.ParseError(n))
+		}
+		b = b[n:]
+	}
+	return nil
+}
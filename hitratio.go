@@ -0,0 +1,103 @@
+package cache
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Number of one-second buckets kept by default by WithHitRatioWindow.
+const defaultHitRatioBuckets = 60
+
+// WithHitRatioWindow enables WindowedHitRatio, keeping lookup counts in the
+// given number of one-second buckets. The largest window that can be queried
+// is therefore buckets seconds. A value less than one uses 60 buckets.
+func WithHitRatioWindow(buckets int) Option {
+	return func(c *cache) {
+		if buckets < 1 {
+			buckets = defaultHitRatioBuckets
+		}
+		c.hitWindow = &hitWindow{
+			now:     c.now,
+			buckets: make([]hitBucket, buckets),
+		}
+	}
+}
+
+type hitBucket struct {
+	second int64
+	hits   uint64
+	misses uint64
+}
+
+// A ring of per-second hit and miss counts.
+type hitWindow struct {
+	mu      sync.Mutex
+	now     func() time.Time
+	buckets []hitBucket
+}
+
+func (w *hitWindow) record(hit bool) {
+	sec := w.now().Unix()
+	w.mu.Lock()
+	b := &w.buckets[sec%int64(len(w.buckets))]
+	if b.second != sec {
+		*b = hitBucket{second: sec}
+	}
+	if hit {
+		b.hits++
+	} else {
+		b.misses++
+	}
+	w.mu.Unlock()
+}
+
+func (w *hitWindow) ratio(window time.Duration) float64 {
+	hits, total := w.counts(window)
+	if total == 0 {
+		return 0
+	}
+	return float64(hits) / float64(total)
+}
+
+// Returns the number of hits and lookups in the most recent window.
+func (w *hitWindow) counts(window time.Duration) (hits, total uint64) {
+	n := int64((window + time.Second - 1) / time.Second)
+	if n > int64(len(w.buckets)) {
+		n = int64(len(w.buckets))
+	}
+	sec := w.now().Unix()
+	w.mu.Lock()
+	for _, b := range w.buckets {
+		if b.second > sec-n && b.second <= sec {
+			hits += b.hits
+			total += b.hits + b.misses
+		}
+	}
+	w.mu.Unlock()
+	return hits, total
+}
+
+// Record the outcome of a lookup.
+func (c *cache) recordLookup(hit bool) {
+	if hit {
+		atomic.AddUint64(&c.stats.hits, 1)
+	} else {
+		atomic.AddUint64(&c.stats.misses, 1)
+	}
+	if c.hitWindow != nil {
+		c.hitWindow.record(hit)
+	}
+}
+
+// WindowedHitRatio returns the fraction of lookups (Get, GetAndExtend and the
+// GetOrLoad variants) that found an item during the most recent window,
+// rounded up to whole seconds and capped at the number of buckets given to
+// WithHitRatioWindow. Returns 0 if there were no lookups in the window or the
+// cache wasn't created with WithHitRatioWindow.
+func (c *cache) WindowedHitRatio(window time.Duration) float64 {
+	if c.hitWindow == nil {
+		return 0
+	}
+	return c.hitWindow.ratio(window)
+}
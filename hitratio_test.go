@@ -0,0 +1,40 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWindowedHitRatio(t *testing.T) {
+	tc := New(DefaultExpiration, 0, WithHitRatioWindow(10))
+	now := time.Unix(1000, 0)
+	tc.hitWindow.now = func() time.Time { return now }
+
+	tc.Set("a", 1, DefaultExpiration)
+	for i := 0; i < 30; i++ {
+		tc.Get("a")
+		now = now.Add(100 * time.Millisecond)
+	}
+	if r := tc.WindowedHitRatio(5 * time.Second); r != 1 {
+		t.Errorf("Hit ratio after hits only is %v, want 1", r)
+	}
+
+	for i := 0; i < 30; i++ {
+		tc.Get("b")
+		now = now.Add(100 * time.Millisecond)
+	}
+	if r := tc.WindowedHitRatio(2 * time.Second); r != 0 {
+		t.Errorf("Hit ratio over the last 2s is %v, want 0", r)
+	}
+	if r := tc.WindowedHitRatio(7 * time.Second); r != 0.5 {
+		t.Errorf("Hit ratio over the last 7s is %v, want 0.5", r)
+	}
+	if r := tc.WindowedHitRatio(time.Hour); r != 0.5 {
+		t.Errorf("Hit ratio over more than the kept buckets is %v, want 0.5", r)
+	}
+
+	now = now.Add(time.Minute)
+	if r := tc.WindowedHitRatio(10 * time.Second); r != 0 {
+		t.Errorf("Hit ratio after a minute without lookups is %v, want 0", r)
+	}
+}
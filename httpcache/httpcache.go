@@ -0,0 +1,259 @@
+// Package httpcache provides HTTP middleware that caches the responses of a
+// handler in a cache.
+//
+//	c := cache.New(time.Minute, 10*time.Minute)
+//	http.Handle("/api/", httpcache.Middleware(c, httpcache.WithTTL(30*time.Second))(api))
+//
+// Only responses to GET requests are cached. A response is stored for the
+// max-age or s-maxage of its Cache-Control header, or for the duration set
+// with WithTTL if it has neither; responses marked no-store or private, or
+// that set cookies, are never stored. As the cache is shared, requests with an
+// Authorization header are only served and stored responses marked public,
+// s-maxage or must-revalidate. Responses are keyed by host and request URI,
+// and by the values of the request headers named in their Vary header.
+package httpcache
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A Cache stores the responses, such as a *cache.Cache.
+// The middleware's keys have their own unexported type, so they don't collide
+// with other keys in the same cache.
+type Cache interface {
+	Get(k interface{}) (interface{}, bool)
+	Set(k interface{}, x interface{}, d time.Duration)
+}
+
+// An Option configures the middleware.
+type Option func(*config)
+
+type config struct {
+	ttl         time.Duration
+	maxBodySize int
+}
+
+// WithTTL stores responses without a max-age or s-maxage in their
+// Cache-Control header for d. By default they are not stored.
+func WithTTL(d time.Duration) Option {
+	return func(c *config) {
+		c.ttl = d
+	}
+}
+
+// WithMaxBodySize stores only responses whose bodies are at most n bytes long.
+// The default is 1 MiB; if n is less than one, there is no limit.
+func WithMaxBodySize(n int) Option {
+	return func(c *config) {
+		c.maxBodySize = n
+	}
+}
+
+// The key of the header names listed by the Vary header of the responses to
+// url.
+type varyKey struct {
+	url string
+}
+
+// The key of a response: the url it was requested from, and the values of the
+// request headers listed by its Vary header.
+type responseKey struct {
+	url  string
+	vary string
+}
+
+// A stored response.
+type response struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// Middleware returns middleware that serves the responses of the handler it
+// wraps from c while they are fresh, and stores them in c otherwise. Served
+// responses have an X-Cache header set to HIT; responses from the handler to
+// cacheable requests have it set to MISS.
+func Middleware(c Cache, opts ...Option) func(http.Handler) http.Handler {
+	conf := config{maxBodySize: 1 << 20}
+	for _, opt := range opts {
+		opt(&conf)
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet || hasDirective(r.Header, "no-store") {
+				next.ServeHTTP(w, r)
+				return
+			}
+			url := r.Host + r.URL.RequestURI()
+			auth := r.Header.Get("Authorization") != ""
+			if !hasDirective(r.Header, "no-cache") {
+				if resp, ok := lookup(c, url, r); ok && (!auth || sharedWithAuth(resp.header)) {
+					serve(w, resp)
+					return
+				}
+			}
+
+			rec := &recorder{ResponseWriter: w, maxBodySize: conf.maxBodySize}
+			w.Header().Set("X-Cache", "MISS")
+			next.ServeHTTP(rec, r)
+			if rec.status == 0 {
+				rec.WriteHeader(http.StatusOK)
+			}
+			d, ok := conf.ttlFor(rec.status, rec.header)
+			if !ok || rec.overflow || auth && !sharedWithAuth(rec.header) {
+				return
+			}
+			vary := varyHeaders(rec.header)
+			c.Set(varyKey{url}, vary, d)
+			c.Set(responseKey{url, varyValues(vary, r)}, response{rec.status, rec.header, rec.body.Bytes()}, d)
+		})
+	}
+}
+
+// Returns the stored response to r, if there is one.
+func lookup(c Cache, url string, r *http.Request) (response, bool) {
+	x, found := c.Get(varyKey{url})
+	if !found {
+		return response{}, false
+	}
+	vary, _ := x.([]string)
+	x, found = c.Get(responseKey{url, varyValues(vary, r)})
+	if !found {
+		return response{}, false
+	}
+	resp, ok := x.(response)
+	return resp, ok
+}
+
+func serve(w http.ResponseWriter, resp response) {
+	h := w.Header()
+	for k, v := range resp.header {
+		h[k] = append([]string(nil), v...)
+	}
+	h.Set("X-Cache", "HIT")
+	w.WriteHeader(resp.status)
+	w.Write(resp.body)
+}
+
+// Returns how long to store a response with the given status and header, and
+// whether to store it at all.
+func (c *config) ttlFor(status int, h http.Header) (time.Duration, bool) {
+	switch status {
+	case http.StatusOK, http.StatusNonAuthoritativeInfo, http.StatusNoContent,
+		http.StatusMultipleChoices, http.StatusMovedPermanently,
+		http.StatusNotFound, http.StatusGone:
+	default:
+		return 0, false
+	}
+	if hasDirective(h, "no-store") || hasDirective(h, "private") || hasDirective(h, "no-cache") ||
+		h.Get("Set-Cookie") != "" || h.Get("Vary") == "*" {
+		return 0, false
+	}
+	for _, name := range []string{"s-maxage", "max-age"} {
+		if v, ok := directive(h, name); ok {
+			seconds, err := strconv.Atoi(v)
+			if err != nil || seconds <= 0 {
+				return 0, false
+			}
+			return time.Duration(seconds) * time.Second, true
+		}
+	}
+	return c.ttl, c.ttl > 0
+}
+
+// Reports whether a response with header h may be stored for, and served to,
+// requests with an Authorization header, as in RFC 9111 section 3.5.
+func sharedWithAuth(h http.Header) bool {
+	return hasDirective(h, "public") || hasDirective(h, "s-maxage") || hasDirective(h, "must-revalidate")
+}
+
+// Returns the value of the Cache-Control directive name in h, and whether it
+// is present.
+func directive(h http.Header, name string) (string, bool) {
+	for _, line := range h.Values("Cache-Control") {
+		for _, d := range strings.Split(line, ",") {
+			d = strings.TrimSpace(d)
+			k, v, _ := strings.Cut(d, "=")
+			if strings.EqualFold(k, name) {
+				return strings.Trim(v, `"`), true
+			}
+		}
+	}
+	return "", false
+}
+
+func hasDirective(h http.Header, name string) bool {
+	_, ok := directive(h, name)
+	return ok
+}
+
+// Returns the canonical names of the headers listed by the Vary header of h.
+func varyHeaders(h http.Header) []string {
+	var names []string
+	for _, line := range h.Values("Vary") {
+		for _, name := range strings.Split(line, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	return names
+}
+
+// Returns the values of the headers of r named by vary, in one string.
+func varyValues(vary []string, r *http.Request) string {
+	var b strings.Builder
+	for _, name := range vary {
+		b.WriteString(name)
+		b.WriteByte(':')
+		b.WriteString(strings.Join(r.Header.Values(name), ","))
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// A recorder passes a response on to the client, keeping a copy of it.
+type recorder struct {
+	http.ResponseWriter
+	status      int
+	header      http.Header
+	body        bytes.Buffer
+	maxBodySize int
+	overflow    bool
+}
+
+func (r *recorder) WriteHeader(status int) {
+	if r.status != 0 {
+		return
+	}
+	r.status = status
+	r.header = r.ResponseWriter.Header().Clone()
+	r.header.Del("X-Cache")
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *recorder) Write(p []byte) (int, error) {
+	if r.status == 0 {
+		r.WriteHeader(http.StatusOK)
+	}
+	if !r.overflow {
+		if r.maxBodySize > 0 && r.body.Len()+len(p) > r.maxBodySize {
+			r.overflow = true
+			r.body = bytes.Buffer{}
+		} else {
+			r.body.Write(p)
+		}
+	}
+	return r.ResponseWriter.Write(p)
+}
+
+// Flush lets handlers that stream their responses flush them.
+func (r *recorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
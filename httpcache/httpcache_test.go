@@ -0,0 +1,153 @@
+package httpcache
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rumsrami/cache"
+)
+
+// Returns a handler that counts its calls in n and writes the count.
+func counter(n *int, header http.Header) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*n++
+		for k, v := range header {
+			w.Header()[k] = v
+		}
+		fmt.Fprintf(w, "%d %s", *n, r.Header.Get("Accept-Language"))
+	})
+}
+
+func get(h http.Handler, url string, header http.Header) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, url, nil)
+	for k, v := range header {
+		r.Header[k] = v
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestMiddleware(t *testing.T) {
+	c := cache.New(cache.DefaultExpiration, 0)
+	n := 0
+	h := Middleware(c, WithTTL(time.Minute))(counter(&n, http.Header{"Content-Type": {"text/plain"}}))
+
+	w := get(h, "/a", nil)
+	if w.Body.String() != "1 " || w.Header().Get("X-Cache") != "MISS" {
+		t.Fatalf("First response is %q with X-Cache %q", w.Body, w.Header().Get("X-Cache"))
+	}
+	w = get(h, "/a", nil)
+	if w.Body.String() != "1 " || w.Header().Get("X-Cache") != "HIT" || w.Header().Get("Content-Type") != "text/plain" {
+		t.Errorf("Second response is %q with headers %v", w.Body, w.Header())
+	}
+	if w := get(h, "/b", nil); w.Body.String() != "2 " {
+		t.Errorf("Response for another URL is %q", w.Body)
+	}
+	if w := get(h, "/a", http.Header{"Cache-Control": {"no-cache"}}); w.Body.String() != "3 " {
+		t.Errorf("Response to a no-cache request is %q", w.Body)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/a", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Body.String() != "4 " {
+		t.Errorf("Response to a POST is %q", w.Body)
+	}
+}
+
+func TestMiddlewareCacheControl(t *testing.T) {
+	tests := []struct {
+		header http.Header
+		ttl    time.Duration
+	}{
+		{http.Header{"Cache-Control": {"public, max-age=30"}}, 30 * time.Second},
+		{http.Header{"Cache-Control": {"max-age=30, s-maxage=60"}}, time.Minute},
+		{http.Header{"Cache-Control": {"no-store"}}, 0},
+		{http.Header{"Cache-Control": {"private, max-age=30"}}, 0},
+		{http.Header{"Set-Cookie": {"a=b"}}, 0},
+		{http.Header{}, 0},
+	}
+	for _, tt := range tests {
+		c := cache.New(cache.DefaultExpiration, 0)
+		n := 0
+		h := Middleware(c)(counter(&n, tt.header))
+		get(h, "/", nil)
+		get(h, "/", nil)
+		if cached := n == 1; cached != (tt.ttl > 0) {
+			t.Errorf("Response with %v was cached: %v", tt.header, cached)
+			continue
+		}
+		if tt.ttl == 0 {
+			continue
+		}
+		for _, k := range c.Keys() {
+			if k, ok := k.(responseKey); ok {
+				if ttl, _ := c.TTL(k); ttl <= tt.ttl-time.Second || ttl > tt.ttl {
+					t.Errorf("Response with %v was stored for %v, want %v", tt.header, ttl, tt.ttl)
+				}
+			}
+		}
+	}
+}
+
+func TestMiddlewareVary(t *testing.T) {
+	c := cache.New(cache.DefaultExpiration, 0)
+	n := 0
+	h := Middleware(c, WithTTL(time.Minute))(counter(&n, http.Header{"Vary": {"Accept-Language"}}))
+
+	en := http.Header{"Accept-Language": {"en"}}
+	fr := http.Header{"Accept-Language": {"fr"}}
+	get(h, "/", en)
+	if w := get(h, "/", fr); w.Body.String() != "2 fr" {
+		t.Errorf("Response for fr is %q", w.Body)
+	}
+	if w := get(h, "/", en); w.Body.String() != "1 en" {
+		t.Errorf("Response for en is %q", w.Body)
+	}
+}
+
+func TestMiddlewareMaxBodySize(t *testing.T) {
+	c := cache.New(cache.DefaultExpiration, 0)
+	n := 0
+	h := Middleware(c, WithTTL(time.Minute), WithMaxBodySize(1))(counter(&n, nil))
+	get(h, "/", nil)
+	if w := get(h, "/", nil); w.Body.String() != "2 " {
+		t.Errorf("Response larger than the limit was cached: %q", w.Body)
+	}
+}
+
+func TestMiddlewareAuthorization(t *testing.T) {
+	for _, cc := range []string{"max-age=60", "public, max-age=60"} {
+		c := cache.New(cache.DefaultExpiration, 0)
+		h := Middleware(c)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Cache-Control", cc)
+			fmt.Fprint(w, r.Header.Get("Authorization"))
+		}))
+		first := get(h, "/first", http.Header{"Authorization": {"alice"}}).Body.String()
+		second := get(h, "/first", http.Header{"Authorization": {"bob"}}).Body.String()
+		anonymous := get(h, "/me", nil).Body.String()
+		alice := get(h, "/me", http.Header{"Authorization": {"alice"}}).Body.String()
+		bob := get(h, "/me", http.Header{"Authorization": {"bob"}}).Body.String()
+		want := "bob"
+		if cc != "max-age=60" {
+			want = "alice"
+		}
+		if first != "alice" || second != want {
+			t.Errorf("%s: responses are %q and %q, want alice and %s", cc, first, second, want)
+		}
+		if cc == "max-age=60" {
+			if anonymous != "" || alice != "alice" || bob != "bob" {
+				t.Errorf("%s: responses are %q, %q and %q, want each user's own", cc, anonymous, alice, bob)
+			}
+			if n := c.ItemCount(); n != 2 {
+				t.Errorf("%s: cache holds %d items, want only the anonymous response", cc, n)
+			}
+		} else if alice != "" || bob != "" {
+			t.Errorf("%s: responses are %q and %q, want the stored public one", cc, alice, bob)
+		}
+	}
+}
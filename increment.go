@@ -0,0 +1,57 @@
+package cache
+
+// Increment an item of type int, int8, int16, int32, int64, uintptr, uint,
+// uint8, uint16, uint32, uint64, float32 or float64 by n. Returns a *KeyError
+// wrapping ErrNotNumeric if the item's value is not a number, or ErrNotFound if
+// it was not found. If there is no error, the incremented item keeps its type
+// and expiration.
+func (c *cache) Increment(k interface{}, n int64) error {
+	c = c.shard(k)
+	k = c.key(k)
+	c.Lock()
+	defer c.unlock()
+
+	item, found := c.get(k)
+	if !found {
+		return &KeyError{k, ErrNotFound}
+	}
+	v, ok := addInt64(item.Object, n)
+	if !ok {
+		return &KeyError{k, ErrNotNumeric}
+	}
+	c.update(k, item, v)
+	return nil
+}
+
+// Decrement an item of a numeric type by n. Returns an error if the item's
+// value is not a number, or if it was not found. See Increment.
+func (c *cache) Decrement(k interface{}, n int64) error {
+	return c.Increment(k, -n)
+}
+
+// Increment an item of type float32 or float64 by n. Returns a *KeyError
+// wrapping ErrNotFloat if the item's value is not floating point, or
+// ErrNotFound if it was not found. If there is no error, the incremented item
+// keeps its type and expiration.
+func (c *cache) IncrementFloat(k interface{}, n float64) error {
+	c = c.shard(k)
+	k = c.key(k)
+	c.Lock()
+	defer c.unlock()
+
+	item, found := c.get(k)
+	if !found {
+		return &KeyError{k, ErrNotFound}
+	}
+	var v interface{}
+	switch x := item.Object.(type) {
+	case float32:
+		v = x + float32(n)
+	case float64:
+		v = x + n
+	default:
+		return &KeyError{k, ErrNotFloat}
+	}
+	c.update(k, item, v)
+	return nil
+}
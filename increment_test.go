@@ -0,0 +1,106 @@
+package cache
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestIncrement(t *testing.T) {
+	tc := New(DefaultExpiration, 0)
+	tc.Set("int", 1, DefaultExpiration)
+	tc.Set("uint8", uint8(1), DefaultExpiration)
+	tc.Set("float64", 1.5, time.Hour)
+	tc.Set("string", "1", DefaultExpiration)
+
+	if err := tc.Increment("int", 2); err != nil {
+		t.Error("Error incrementing:", err)
+	}
+	if x, _ := tc.Get("int"); x != 3 {
+		t.Error("int is not 3:", x)
+	}
+	if err := tc.Decrement("uint8", 2); err != nil {
+		t.Error("Error decrementing:", err)
+	}
+	if x, _ := tc.Get("uint8"); x != uint8(255) {
+		t.Error("uint8 is not 255:", x)
+	}
+
+	before, _ := tc.Inspect("float64")
+	if err := tc.IncrementFloat("float64", 0.25); err != nil {
+		t.Error("Error incrementing float:", err)
+	}
+	after, _ := tc.Inspect("float64")
+	if after.Value != 1.75 {
+		t.Error("float64 is not 1.75:", after.Value)
+	}
+	if !after.Expiration.Equal(before.Expiration) {
+		t.Error("IncrementFloat changed the expiration")
+	}
+
+	if err := tc.Increment("string", 1); err == nil {
+		t.Error("Incremented a string")
+	}
+	if err := tc.IncrementFloat("int", 1); err == nil {
+		t.Error("IncrementFloat incremented an int")
+	}
+	if err := tc.Increment("missing", 1); err == nil {
+		t.Error("Incremented a missing item")
+	}
+}
+
+func TestIncrementConcurrent(t *testing.T) {
+	tc := New(DefaultExpiration, 0)
+	tc.Set("n", int64(0), DefaultExpiration)
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				tc.Increment("n", 1)
+			}
+		}()
+	}
+	wg.Wait()
+	if x, _ := tc.Get("n"); x != int64(5000) {
+		t.Error("n is not 5000:", x)
+	}
+}
+
+func TestIncrementMany(t *testing.T) {
+	tc := New(DefaultExpiration, 0)
+	tc.Set("hits", int64(10), DefaultExpiration)
+	tc.Set("name", "not a number", DefaultExpiration)
+
+	err := tc.IncrementMany(map[interface{}]int64{"hits": 1, "name": 1}, DefaultExpiration)
+	if err == nil {
+		t.Error("Incremented a string value")
+	}
+	if x, _ := tc.Get("hits"); x.(int64) != 10 {
+		t.Errorf("hits is %v after a failed batch, want 10", x)
+	}
+
+	const workers, batches = 8, 100
+	wg := new(sync.WaitGroup)
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for j := 0; j < batches; j++ {
+				err := tc.IncrementMany(map[interface{}]int64{"hits": 1, "misses": 2}, DefaultExpiration)
+				if err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if x, _ := tc.Get("hits"); x.(int64) != 10+workers*batches {
+		t.Errorf("hits is %v, want %d", x, 10+workers*batches)
+	}
+	if x, _ := tc.Get("misses"); x.(int64) != 2*workers*batches {
+		t.Errorf("misses is %v, want %d", x, 2*workers*batches)
+	}
+}
@@ -0,0 +1,57 @@
+package cache
+
+import "time"
+
+// An InspectResult describes an item in the cache.
+type InspectResult struct {
+	// The item's value.
+	Value interface{}
+	// When the item expires, or the zero time if it never does.
+	Expiration time.Time
+	// How long until the item expires, or NoExpiration if it never does.
+	TTL time.Duration
+	// When the item's value was stored.
+	Stored time.Time
+	// How long ago the item's value was stored.
+	Age time.Duration
+	// How many lookups have returned the item since its value was stored,
+	// or zero if the cache doesn't record lookups (see WithAccessTracking).
+	Accesses uint64
+	// The item's cost as given to SetWithCost, or zero.
+	Cost int64
+	// The item's priority as given to SetWithPriority, or PriorityNormal.
+	Priority Priority
+}
+
+// Inspect returns the value of an unexpired item together with its expiration,
+// remaining lifetime, when it was stored, its age, how often it has been
+// looked up, its cost and its priority, and a bool indicating whether the key
+// was found. Inspecting an item does not count as an access.
+func (c *cache) Inspect(k interface{}) (InspectResult, bool) {
+	c = c.shard(k)
+	k = c.key(k)
+	c.RLock()
+	defer c.RUnlock()
+
+	item, found := c.get(k)
+	if !found {
+		return InspectResult{}, false
+	}
+	now := c.now()
+	r := InspectResult{
+		Value: item.Object,
+		TTL:   NoExpiration,
+		Cost:  c.costs[k],
+	}
+	if item.Expiration > 0 {
+		r.Expiration = time.Unix(0, item.Expiration)
+		r.TTL = r.Expiration.Sub(now)
+	}
+	if e, found := c.access.entry(k); found {
+		r.Stored = time.Unix(0, e.stored)
+		r.Age = now.Sub(r.Stored)
+		r.Accesses = e.accesses
+		r.Priority = e.priority
+	}
+	return r, true
+}
@@ -0,0 +1,60 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInspect(t *testing.T) {
+	tc := New(DefaultExpiration, 0, WithAccessTracking())
+	before := time.Now()
+	tc.Set("a", 1, time.Hour)
+	tc.Set("forever", 2, NoExpiration)
+	<-time.After(5 * time.Millisecond)
+	tc.Get("a")
+	tc.Get("a")
+	tc.GetAndExtend("a", DefaultExpiration)
+
+	r, found := tc.Inspect("a")
+	if !found {
+		t.Fatal("Did not find a")
+	}
+	if r.Value != 1 {
+		t.Errorf("Value is %v, want 1", r.Value)
+	}
+	if r.Expiration.Before(before.Add(time.Hour)) || r.Expiration.After(time.Now().Add(time.Hour)) {
+		t.Errorf("Expiration is %v, want about an hour from %v", r.Expiration, before)
+	}
+	if r.TTL <= 59*time.Minute || r.TTL > time.Hour {
+		t.Errorf("TTL is %v, want just under an hour", r.TTL)
+	}
+	if r.Stored.Before(before) || r.Stored.After(before.Add(5*time.Millisecond)) {
+		t.Errorf("Stored is %v, want shortly after %v", r.Stored, before)
+	}
+	if r.Age < 5*time.Millisecond {
+		t.Errorf("Age is %v, want at least 5ms", r.Age)
+	}
+	if r.Accesses != 3 {
+		t.Errorf("Accesses is %d, want 3", r.Accesses)
+	}
+
+	r, found = tc.Inspect("forever")
+	if !found || !r.Expiration.IsZero() || r.TTL != NoExpiration || r.Accesses != 0 {
+		t.Errorf("Inspecting forever returned %+v, %v", r, found)
+	}
+	if _, found := tc.Inspect("missing"); found {
+		t.Error("Inspected a missing item")
+	}
+}
+
+func TestInspectCost(t *testing.T) {
+	tc := New(DefaultExpiration, 0)
+	tc.SetWithCost("a", 1, 5, DefaultExpiration)
+	tc.Set("b", 2, DefaultExpiration)
+	if r, _ := tc.Inspect("a"); r.Cost != 5 {
+		t.Errorf("Cost of a is %d, want 5", r.Cost)
+	}
+	if r, _ := tc.Inspect("b"); r.Cost != 0 {
+		t.Errorf("Cost of b is %d, want 0", r.Cost)
+	}
+}
@@ -0,0 +1,247 @@
+package cache
+
+import (
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestSortedItems(t *testing.T) {
+	tc := New(DefaultExpiration, 0)
+	tc.Set("c", 3, DefaultExpiration)
+	tc.Set("a", 1, DefaultExpiration)
+	tc.Set("d", 4, time.Millisecond)
+	tc.Set("b", 2, DefaultExpiration)
+	tc.Set(10, 10, DefaultExpiration)
+	tc.Set(9, 9, DefaultExpiration)
+	<-time.After(5 * time.Millisecond)
+
+	items := tc.SortedItems(nil)
+	want := []interface{}{"a", "b", "c", 10, 9}
+	if len(items) != len(want) {
+		t.Fatalf("Got %d items, want %d: %v", len(items), len(want), items)
+	}
+	for i, k := range want {
+		if items[i].Key != k {
+			t.Errorf("Item %d has key %v, want %v", i, items[i].Key, k)
+		}
+	}
+
+	tc.Delete(9)
+	tc.Delete(10)
+	items = tc.SortedItems(func(a, b interface{}) bool {
+		return a.(string) > b.(string)
+	})
+	for i, k := range []string{"c", "b", "a"} {
+		if items[i].Key != k {
+			t.Errorf("Item %d has key %v, want %v", i, items[i].Key, k)
+		}
+	}
+}
+
+func TestGetWithGeneration(t *testing.T) {
+	tc := New(DefaultExpiration, 0)
+	tc.Set("a", 1, DefaultExpiration)
+	x, gen, found := tc.GetWithGeneration("a")
+	if !found || x.(int) != 1 || gen != 0 {
+		t.Errorf("Got %v, %d, %v; want 1, 0, true", x, gen, found)
+	}
+
+	tc.Flush()
+	_, gen, found = tc.GetWithGeneration("a")
+	if found || gen != 1 {
+		t.Errorf("Got generation %d, found %v after Flush; want 1, false", gen, found)
+	}
+
+	tc.Set("a", 2, DefaultExpiration)
+	tc.Flush()
+	tc.Set("a", 3, DefaultExpiration)
+	x, gen, found = tc.GetWithGeneration("a")
+	if !found || x.(int) != 3 || gen != 2 {
+		t.Errorf("Got %v, %d, %v; want 3, 2, true", x, gen, found)
+	}
+}
+
+func TestCountAndSample(t *testing.T) {
+	tc := New(DefaultExpiration, 0)
+	for i := 0; i < 20; i++ {
+		tc.Set(i, i, DefaultExpiration)
+	}
+	for i := 20; i < 25; i++ {
+		tc.Set(i, i, time.Millisecond)
+	}
+	<-time.After(5 * time.Millisecond)
+
+	count, sample := tc.CountAndSample(5)
+	if count != 20 {
+		t.Errorf("Count is %d, want 20", count)
+	}
+	if len(sample) != 5 {
+		t.Errorf("Sample has %d items, want 5", len(sample))
+	}
+	seen := map[interface{}]bool{}
+	for _, kv := range sample {
+		if kv.Key.(int) >= 20 || kv.Value != kv.Key || seen[kv.Key] {
+			t.Errorf("Unexpected sampled item %v", kv)
+		}
+		seen[kv.Key] = true
+	}
+
+	count, sample = tc.CountAndSample(100)
+	if count != 20 || len(sample) != 20 {
+		t.Errorf("Got count %d and %d sampled items, want 20 and 20", count, len(sample))
+	}
+}
+
+func TestTakeN(t *testing.T) {
+	tc := New(DefaultExpiration, 0)
+	const jobs = 1000
+	for i := 0; i < jobs; i++ {
+		tc.Set(i, i%2 == 0, DefaultExpiration)
+	}
+	ready := func(k, v interface{}) bool {
+		return v.(bool)
+	}
+
+	const consumers = 8
+	taken := make(chan []KeyAndValue, consumers)
+	for i := 0; i < consumers; i++ {
+		go func() {
+			var mine []KeyAndValue
+			for {
+				batch := tc.TakeN(7, ready)
+				if len(batch) == 0 {
+					break
+				}
+				if len(batch) > 7 {
+					t.Errorf("Took %d items, want at most 7", len(batch))
+				}
+				mine = append(mine, batch...)
+			}
+			taken <- mine
+		}()
+	}
+
+	seen := map[interface{}]bool{}
+	for i := 0; i < consumers; i++ {
+		for _, kv := range <-taken {
+			if seen[kv.Key] {
+				t.Errorf("Item %v was taken twice", kv.Key)
+			}
+			if !kv.Value.(bool) {
+				t.Errorf("Item %v was taken before it was ready", kv.Key)
+			}
+			seen[kv.Key] = true
+		}
+	}
+	if len(seen) != jobs/2 {
+		t.Errorf("Took %d items, want %d", len(seen), jobs/2)
+	}
+	if n := tc.ItemCount(); n != jobs/2 {
+		t.Errorf("Item count is %d, want %d", n, jobs/2)
+	}
+}
+
+func TestKeys(t *testing.T) {
+	tc := New(DefaultExpiration, 0)
+	tc.Set("a", 1, DefaultExpiration)
+	tc.Set(2, 2, DefaultExpiration)
+	tc.Set("c", 3, time.Millisecond)
+	<-time.After(5 * time.Millisecond)
+
+	keys := tc.Keys()
+	sort.Slice(keys, func(i, j int) bool {
+		return naturalKeyLess(keys[i], keys[j])
+	})
+	if len(keys) != 2 || keys[0] != "a" || keys[1] != 2 {
+		t.Error("Keys returned", keys)
+	}
+}
+
+func TestRange(t *testing.T) {
+	tc := New(DefaultExpiration, 0)
+	for i := 0; i < 10; i++ {
+		tc.Set(i, i*i, DefaultExpiration)
+	}
+	tc.Set("expired", 0, time.Millisecond)
+	<-time.After(5 * time.Millisecond)
+
+	seen := map[interface{}]interface{}{}
+	tc.Range(func(k, v interface{}) bool {
+		seen[k] = v
+		tc.Delete(k)
+		return true
+	})
+	if len(seen) != 10 || seen[3] != 9 {
+		t.Error("Range saw", seen)
+	}
+	if n := tc.ItemCount(); n != 1 {
+		t.Errorf("ItemCount is %d after deleting from Range, want 1", n)
+	}
+
+	for i := 0; i < 10; i++ {
+		tc.Set(i, i, DefaultExpiration)
+	}
+	calls := 0
+	tc.Range(func(k, v interface{}) bool {
+		calls++
+		return calls < 3
+	})
+	if calls != 3 {
+		t.Errorf("Range called f %d times after it returned false, want 3", calls)
+	}
+}
+
+func TestItems(t *testing.T) {
+	tc := New(DefaultExpiration, 0)
+	tc.Set("a", 1, DefaultExpiration)
+	tc.Set("b", 2, time.Hour)
+	tc.Set("c", 3, time.Millisecond)
+	<-time.After(5 * time.Millisecond)
+
+	items := tc.Items()
+	if len(items) != 2 || items["a"].Object != 1 || items["b"].Object != 2 {
+		t.Error("Items returned", items)
+	}
+	if items["b"].Expiration == 0 {
+		t.Error("Items dropped the expiration of b")
+	}
+	delete(items, "a")
+	if _, found := tc.Get("a"); !found {
+		t.Error("Changing the map returned by Items changed the cache")
+	}
+
+	oc := NewFrom(DefaultExpiration, 0, tc.Items())
+	if x, found := oc.Get("b"); !found || x != 2 {
+		t.Error("b wasn't copied with NewFrom:", x)
+	}
+}
+
+func TestDeleteWhere(t *testing.T) {
+	type tenantValue struct {
+		Tenant string
+	}
+	tc := New(DefaultExpiration, 0)
+	var evicted int
+	tc.OnEvicted(func(k, v interface{}) {
+		evicted++
+	})
+	tc.Set("a", tenantValue{"x"}, DefaultExpiration)
+	tc.Set("b", tenantValue{"y"}, DefaultExpiration)
+	tc.Set("c", tenantValue{"x"}, DefaultExpiration)
+	tc.Set("d", "not a tenant value", DefaultExpiration)
+
+	n := tc.DeleteWhere(func(k, v interface{}) bool {
+		tv, ok := v.(tenantValue)
+		return ok && tv.Tenant == "x"
+	})
+	if n != 2 {
+		t.Errorf("DeleteWhere deleted %d items, want 2", n)
+	}
+	if evicted != 2 {
+		t.Errorf("OnEvicted was called %d times, want 2", evicted)
+	}
+	if n := tc.ItemCount(); n != 2 {
+		t.Errorf("ItemCount is %d, want 2", n)
+	}
+}
@@ -0,0 +1,64 @@
+package cache
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestJanitorValidator(t *testing.T) {
+	revoked := map[interface{}]bool{"b": true}
+	var mu sync.Mutex
+	valid := func(k, v interface{}) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return !revoked[k]
+	}
+	tc := New(DefaultExpiration, 5*time.Millisecond, WithJanitorValidator(valid))
+	tc.Set("a", 1, DefaultExpiration)
+	tc.Set("b", 2, DefaultExpiration)
+	tc.Set("c", 3, DefaultExpiration)
+
+	<-time.After(20 * time.Millisecond)
+	if _, found := tc.Get("b"); found {
+		t.Error("Found b after the janitor rejected it")
+	}
+	if _, found := tc.Get("a"); !found {
+		t.Error("Did not find valid item a")
+	}
+
+	mu.Lock()
+	revoked["c"] = true
+	mu.Unlock()
+	<-time.After(20 * time.Millisecond)
+	if _, found := tc.Get("c"); found {
+		t.Error("Found c after the janitor rejected it")
+	}
+	if n := tc.ItemCount(); n != 1 {
+		t.Errorf("Item count is %d, want 1", n)
+	}
+}
+
+func TestStopJanitor(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	tc := New(time.Minute, time.Minute, WithClock(clock))
+	tc.Set("a", 1, DefaultExpiration)
+
+	tc.StopJanitor()
+	tc.StopJanitor()
+	clock.Advance(time.Hour)
+	if n := tc.ItemCount(); n != 1 {
+		t.Errorf("Item count after stopping the janitor is %d, want 1", n)
+	}
+	if _, found := tc.Get("a"); found {
+		t.Error("Found an expired item after stopping the janitor")
+	}
+
+	tc.Close()
+	tc.Close()
+	tc.Set("b", 2, NoExpiration)
+	if _, found := tc.Get("b"); !found {
+		t.Error("Set after Close was not stored")
+	}
+	New(DefaultExpiration, 0).StopJanitor()
+}
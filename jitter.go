@@ -0,0 +1,39 @@
+package cache
+
+import (
+	"math/rand"
+	"time"
+)
+
+// WithTTLJitter randomizes every expiration the cache sets by up to fraction of
+// its duration either way: with a fraction of 0.1, an item stored for an hour
+// expires between 54 and 66 minutes later. This spreads out the expirations
+// of items stored at the same time, e.g. when warming the cache at startup,
+// so that they don't all have to be reloaded at once. It applies to Set and
+// the other methods that store items, and to GetAndExtend, Touch and sliding
+// expiration, but not to expirations given as times, e.g. by ImportJSON or
+// Merge. fraction is limited to between 0 and 1.
+func WithTTLJitter(fraction float64) Option {
+	return func(c *cache) {
+		switch {
+		case fraction < 0:
+			fraction = 0
+		case fraction > 1:
+			fraction = 1
+		}
+		c.ttlJitter = fraction
+	}
+}
+
+// Returns d randomized by the cache's TTL jitter. The result is at least one
+// nanosecond, so an item stored with a duration always expires.
+func (c *cache) jitter(d time.Duration) time.Duration {
+	if c.ttlJitter == 0 {
+		return d
+	}
+	d += time.Duration(float64(d) * c.ttlJitter * (2*rand.Float64() - 1))
+	if d < 1 {
+		d = 1
+	}
+	return d
+}
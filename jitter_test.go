@@ -0,0 +1,48 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTTLJitter(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	tc := New(time.Hour, 0, WithClock(clock), WithTTLJitter(0.1))
+	distinct := map[time.Duration]bool{}
+	for i := 0; i < 100; i++ {
+		tc.Set(i, i, DefaultExpiration)
+		ttl, _ := tc.TTL(i)
+		if ttl < 54*time.Minute || ttl > 66*time.Minute {
+			t.Fatalf("TTL %v is not within 10%% of an hour", ttl)
+		}
+		distinct[ttl] = true
+	}
+	if len(distinct) < 2 {
+		t.Error("All items were given the same TTL")
+	}
+
+	tc.Set("a", 1, NoExpiration)
+	if ttl, _ := tc.TTL("a"); ttl != NoExpiration {
+		t.Errorf("TTL of an item stored with NoExpiration is %v", ttl)
+	}
+}
+
+func TestTTLJitterLimits(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	tc := New(time.Hour, 0, WithClock(clock), WithTTLJitter(-1))
+	tc.Set("a", 1, DefaultExpiration)
+	if ttl, _ := tc.TTL("a"); ttl != time.Hour {
+		t.Errorf("TTL with a negative jitter is %v, want 1h", ttl)
+	}
+
+	tc = New(time.Hour, 0, WithClock(clock), WithTTLJitter(5))
+	for i := 0; i < 100; i++ {
+		tc.Set(i, i, time.Nanosecond)
+		if _, found := tc.Inspect(i); !found {
+			t.Fatal("An item stored with a duration was stored without an expiration")
+		}
+		if ttl, _ := tc.TTL(i); ttl < 0 || ttl > 2 {
+			t.Fatalf("TTL %v is not within 100%% of 1ns", ttl)
+		}
+	}
+}
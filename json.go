@@ -0,0 +1,17 @@
+package cache
+
+import "io"
+
+// ExportJSON writes the cache's unexpired items to w with JSONCodec, whatever
+// the cache's codec is.
+func (c *cache) ExportJSON(w io.Writer) error {
+	return JSONCodec{}.Encode(w, c.Items())
+}
+
+// ImportJSON adds the items written by ExportJSON from r, like Load: items with
+// keys that already exist in the cache and items that have expired are
+// skipped. Items without an expiration never expire. See JSONCodec for how
+// keys and values are decoded.
+func (c *cache) ImportJSON(r io.Reader) error {
+	return c.load(r, JSONCodec{})
+}
@@ -0,0 +1,65 @@
+package cache
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportImportJSON(t *testing.T) {
+	tc := New(DefaultExpiration, 0)
+	tc.Set("a", "a", DefaultExpiration)
+	tc.Set("b", 2, time.Hour)
+	tc.Set("c", "c", time.Millisecond)
+	<-time.After(5 * time.Millisecond)
+
+	buf := &bytes.Buffer{}
+	if err := tc.ExportJSON(buf); err != nil {
+		t.Fatal("Couldn't export cache:", err)
+	}
+	if strings.Contains(buf.String(), `"c"`) {
+		t.Error("expired item c was exported:", buf.String())
+	}
+
+	oc := New(DefaultExpiration, 0)
+	if err := oc.ImportJSON(buf); err != nil {
+		t.Fatal("Couldn't import cache:", err)
+	}
+	if x, found := oc.Get("a"); !found || x != "a" {
+		t.Error("a wasn't imported:", x)
+	}
+	got, found := oc.Inspect("b")
+	if !found || got.Value != float64(2) {
+		t.Error("b wasn't imported:", got.Value)
+	}
+	want, _ := tc.Inspect("b")
+	if !got.Expiration.Equal(want.Expiration) {
+		t.Errorf("b expires at %v, want %v", got.Expiration, want.Expiration)
+	}
+	if r, _ := oc.Inspect("a"); r.TTL != NoExpiration {
+		t.Error("a was imported with an expiration:", r.Expiration)
+	}
+}
+
+func TestImportJSONFixture(t *testing.T) {
+	tc := New(DefaultExpiration, 0)
+	err := tc.ImportJSON(strings.NewReader(`[
+		{"key": "user", "value": {"name": "gopher"}},
+		{"key": "old", "value": 1, "expiration": "2006-01-02T15:04:05Z"},
+		{"key": "new", "value": 2, "expiration": "2200-01-02T15:04:05+02:00"}
+	]`))
+	if err != nil {
+		t.Fatal("Couldn't import fixture:", err)
+	}
+	x, _ := tc.Get("user")
+	if m, ok := x.(map[string]interface{}); !ok || m["name"] != "gopher" {
+		t.Error("user is", x)
+	}
+	if _, found := tc.Get("old"); found {
+		t.Error("expired fixture was imported")
+	}
+	if _, found := tc.Get("new"); !found {
+		t.Error("new wasn't imported")
+	}
+}
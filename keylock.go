@@ -0,0 +1,43 @@
+package cache
+
+import (
+	"hash/maphash"
+	"sync"
+)
+
+// The number of mutexes shared by the keys passed to LockKey.
+const keyLockStripes = 256
+
+// Mutexes for LockKey; each key hashes to one of them.
+type keyLocks struct {
+	seed    maphash.Seed
+	stripes [keyLockStripes]sync.Mutex
+}
+
+// LockKey locks k for the caller and returns the function that unlocks it, so
+// that compound operations on one key, such as reading an item, calling
+// another service and writing the result back, can be serialized without
+// locking the whole cache:
+//
+//	unlock := c.LockKey(k)
+//	defer unlock()
+//
+// The lock is advisory: it only excludes other callers of LockKey for the same
+// key, not the cache's other methods, which stay usable while it is held. Keys
+// share a fixed set of mutexes, so locking an unrelated key may also wait, and
+// a goroutine must not lock a second key while it holds one, or it may
+// deadlock. The returned function must be called exactly once.
+func (c *cache) LockKey(k interface{}) (unlock func()) {
+	c = c.shard(k)
+	c.keyLocksOnce.Do(func() {
+		c.keyLocks = &keyLocks{seed: maphash.MakeSeed()}
+	})
+	return c.keyLocks.lock(c.key(k))
+}
+
+// Lock the mutex of k and return the function that unlocks it.
+func (l *keyLocks) lock(k interface{}) (unlock func()) {
+	mu := &l.stripes[hashKey(l.seed, k)%keyLockStripes]
+	mu.Lock()
+	return mu.Unlock
+}
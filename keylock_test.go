@@ -0,0 +1,46 @@
+package cache
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLockKey(t *testing.T) {
+	tc := New(DefaultExpiration, 0)
+	tc.Set("n", 0, DefaultExpiration)
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			unlock := tc.LockKey("n")
+			defer unlock()
+			x, _ := tc.Get("n")
+			tc.Set("n", x.(int)+1, DefaultExpiration)
+		}()
+	}
+	wg.Wait()
+	if x, _ := tc.Get("n"); x != 50 {
+		t.Errorf("n is %v after 50 locked increments, want 50", x)
+	}
+}
+
+func TestLockKeyExcludes(t *testing.T) {
+	tc := NewTyped[string, int](DefaultExpiration, 0)
+	unlock := tc.LockKey("a")
+	locked := make(chan struct{})
+	go func() {
+		unlock := tc.LockKey("a")
+		close(locked)
+		unlock()
+	}()
+	select {
+	case <-locked:
+		t.Fatal("Locked a key that was already locked")
+	case <-time.After(10 * time.Millisecond):
+	}
+	tc.Set("a", 1, DefaultExpiration)
+	unlock()
+	<-locked
+}
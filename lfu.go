@@ -0,0 +1,103 @@
+package cache
+
+import "container/list"
+
+// WithLFUEviction makes the cache evict the least frequently used item when
+// it is full, instead of the least recently used one: the item whose value
+// has been returned by the fewest lookups since it was stored, and of those
+// the least recently used. Unlike LRU, a scan over many keys that are used
+// once doesn't push out the items that are used often. Storing a new value
+// under a key counts as one use. See WithMaxEntries and WithMaxCost.
+func WithLFUEviction() Option {
+	return func(c *cache) {
+		c.newPolicy = func(int) EvictionPolicy {
+			return newLFUPolicy()
+		}
+	}
+}
+
+// NewLFUPolicy returns the policy used by WithLFUEviction, for use with
+// WithEvictionPolicy.
+func NewLFUPolicy() EvictionPolicy {
+	return newLFUPolicy()
+}
+
+// An LFU policy running in constant time: keys are kept in buckets of equal
+// frequency, in a list of buckets sorted by frequency, and within each bucket
+// in the order they were last used.
+type lfuPolicy struct {
+	buckets *list.List
+	entries map[interface{}]*lfuEntry
+}
+
+type lfuBucket struct {
+	freq uint64
+	// Keys, most recently used first.
+	keys *list.List
+}
+
+type lfuEntry struct {
+	bucket *list.Element
+	elem   *list.Element
+}
+
+func newLFUPolicy() *lfuPolicy {
+	return &lfuPolicy{
+		buckets: list.New(),
+		entries: map[interface{}]*lfuEntry{},
+	}
+}
+
+func (p *lfuPolicy) RecordInsert(k interface{}) {
+	if _, found := p.entries[k]; found {
+		p.RecordAccess(k)
+		return
+	}
+	front := p.buckets.Front()
+	if front == nil || front.Value.(*lfuBucket).freq != 1 {
+		front = p.buckets.PushFront(&lfuBucket{freq: 1, keys: list.New()})
+	}
+	p.entries[k] = &lfuEntry{
+		bucket: front,
+		elem:   front.Value.(*lfuBucket).keys.PushFront(k),
+	}
+}
+
+func (p *lfuPolicy) RecordAccess(k interface{}) {
+	e, found := p.entries[k]
+	if !found {
+		return
+	}
+	b := e.bucket.Value.(*lfuBucket)
+	next := e.bucket.Next()
+	if next == nil || next.Value.(*lfuBucket).freq != b.freq+1 {
+		next = p.buckets.InsertAfter(&lfuBucket{freq: b.freq + 1, keys: list.New()}, e.bucket)
+	}
+	p.unlink(e)
+	e.bucket = next
+	e.elem = next.Value.(*lfuBucket).keys.PushFront(k)
+}
+
+func (p *lfuPolicy) Remove(k interface{}) {
+	if e, found := p.entries[k]; found {
+		p.unlink(e)
+		delete(p.entries, k)
+	}
+}
+
+// Take e out of its bucket, dropping the bucket if it is left empty.
+func (p *lfuPolicy) unlink(e *lfuEntry) {
+	b := e.bucket.Value.(*lfuBucket)
+	b.keys.Remove(e.elem)
+	if b.keys.Len() == 0 {
+		p.buckets.Remove(e.bucket)
+	}
+}
+
+func (p *lfuPolicy) Victim() (interface{}, bool) {
+	front := p.buckets.Front()
+	if front == nil {
+		return nil, false
+	}
+	return front.Value.(*lfuBucket).keys.Back().Value, true
+}
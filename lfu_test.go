@@ -0,0 +1,76 @@
+package cache
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestLFUPolicy(t *testing.T) {
+	p := newLFUPolicy()
+	if _, found := p.Victim(); found {
+		t.Error("Empty policy returned a victim")
+	}
+	for _, k := range []string{"a", "b", "c"} {
+		p.RecordInsert(k)
+	}
+	p.RecordAccess("a")
+	p.RecordAccess("a")
+	p.RecordAccess("b")
+	p.RecordAccess("missing")
+	for _, want := range []string{"c", "b", "a"} {
+		k, found := p.Victim()
+		if !found || k != want {
+			t.Fatalf("Victim is %v, want %s", k, want)
+		}
+		p.Remove(k)
+	}
+	if len(p.entries) != 0 || p.buckets.Len() != 0 {
+		t.Errorf("Policy still tracks %d keys in %d buckets", len(p.entries), p.buckets.Len())
+	}
+
+	// Ties go to the least recently used key.
+	p.RecordInsert("x")
+	p.RecordInsert("y")
+	p.RecordAccess("x")
+	p.RecordAccess("y")
+	if k, _ := p.Victim(); k != "x" {
+		t.Errorf("Victim is %v, want x", k)
+	}
+}
+
+func TestLFUEviction(t *testing.T) {
+	tc := New(DefaultExpiration, 0, WithMaxEntries(3), WithLFUEviction())
+	tc.Set("a", 1, DefaultExpiration)
+	tc.Set("b", 2, DefaultExpiration)
+	for i := 0; i < 3; i++ {
+		tc.Get("a")
+		tc.Get("b")
+	}
+	// A scan of keys used once doesn't evict a or b.
+	for i := 0; i < 10; i++ {
+		tc.Set(fmt.Sprint("scan", i), i, DefaultExpiration)
+	}
+	for _, k := range []string{"a", "b", "scan9"} {
+		if _, found := tc.Get(k); !found {
+			t.Errorf("%s was evicted", k)
+		}
+	}
+	if err := tc.ConsistencyCheck(); err != nil {
+		t.Error(err)
+	}
+
+	tc.Flush()
+	tc.Set("c", 3, DefaultExpiration)
+	if n := tc.TriggerMemoryPressure(); n != 0 {
+		t.Errorf("Evicted %d items", n)
+	}
+	if got := tc.access.policy.(*lfuPolicy).entries; len(got) != 1 {
+		t.Errorf("Policy tracks %d keys after Flush, want 1", len(got))
+	}
+
+	// Partitions keep the policy.
+	p := tc.Partition(func(k, v interface{}) bool { return true })
+	if _, ok := p.access.policy.(*lfuPolicy); !ok {
+		t.Errorf("Partition has policy %T", p.access.policy)
+	}
+}
@@ -0,0 +1,137 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"runtime/debug"
+	"time"
+)
+
+// A load in progress. Callers that miss on a key while it is being loaded wait
+// on done and share val and err instead of running their own loader.
+type loadCall struct {
+	done chan struct{}
+	val  interface{}
+	err  error
+}
+
+// Load key with load and store the result, unless another goroutine is already
+// loading it, in which case wait for that load and return its result, or
+// ctx.Err() if ctx is done first. If the other load fails because its context
+// is done, the load is tried again with load. Must be called with c locked;
+// the lock is released before load runs, so other keys stay readable and
+// writable while it does.
+func (c *cache) loadShared(ctx context.Context, key interface{}, load func() (interface{}, time.Duration, error)) (interface{}, error) {
+	if err, failed := c.failedLoad(key); failed {
+		c.unlock()
+		return nil, err
+	}
+	if call, ok := c.loads[key]; ok {
+		c.unlock()
+		select {
+		case <-call.done:
+			if isContextError(call.err) && ctx.Err() == nil {
+				c.Lock()
+				return c.loadShared(ctx, key, load)
+			}
+			return call.val, call.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if c.breaker != nil && !c.breaker.allow(c.now().UnixNano()) {
+		c.unlock()
+		return nil, &KeyError{key, ErrCircuitOpen}
+	}
+	call := c.startLoad(key)
+	c.unlock()
+	return c.finishLoad(ctx, key, call, load)
+}
+
+// Register a load of key. Must be called with c locked.
+func (c *cache) startLoad(key interface{}) *loadCall {
+	call := &loadCall{done: make(chan struct{})}
+	if c.loads == nil {
+		c.loads = map[interface{}]*loadCall{}
+	}
+	c.loads[key] = call
+	return call
+}
+
+// Run the load registered as call, retrying it as set by WithLoadRetry until
+// ctx is done, store its result and wake up the callers waiting for it. Must
+// be called with c unlocked.
+func (c *cache) finishLoad(ctx context.Context, key interface{}, call *loadCall, load func() (interface{}, time.Duration, error)) (interface{}, error) {
+	start := time.Now()
+	object, d, err := c.runLoad(ctx, key, load)
+	c.stats.loaded(time.Since(start), err)
+
+	c.Lock()
+	delete(c.loads, key)
+	if c.breaker != nil {
+		c.breaker.record(err, c.now().UnixNano())
+	}
+	if err == nil {
+		c.rewrite(key, object, d)
+	} else if !isContextError(err) {
+		// A load given up by its caller says nothing about the key.
+		c.recordFailure(key, err)
+	}
+	c.unlock()
+
+	call.val, call.err = object, err
+	close(call.done)
+	return object, err
+}
+
+// Reports whether err comes from a context being canceled or timing out.
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// Call load as set by WithLoadRetry, turning a panic into an error.
+func (c *cache) runLoad(ctx context.Context, key interface{}, load func() (interface{}, time.Duration, error)) (object interface{}, d time.Duration, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			object, d, err = nil, 0, &KeyError{key, &PanicError{r, debug.Stack()}}
+		}
+	}()
+	return c.retry.run(ctx, load)
+}
+
+// GetOrLoadContext works like GetOrLoad, but passes ctx to load(). If another
+// goroutine is already loading the key, GetOrLoadContext waits for it only
+// until ctx is done and then returns ctx.Err(); the other load carries on and
+// still stores its result.
+func (c *cache) GetOrLoadContext(ctx context.Context, k interface{}, load func(ctx context.Context, k interface{}) (interface{}, time.Duration, error)) (interface{}, error) {
+	c = c.shard(k)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	key := c.key(k)
+	c.Lock()
+
+	item, found := c.get(key)
+	if !found {
+		if x, ok := c.serveStale(key, func() (interface{}, time.Duration, error) {
+			return load(context.Background(), k)
+		}); ok {
+			c.unlock()
+			return x, nil
+		}
+	}
+	c.recordLookup(found)
+	if !found {
+		c.expireStale(key)
+		return c.loadShared(ctx, key, func() (interface{}, time.Duration, error) {
+			return load(ctx, k)
+		})
+	}
+
+	c.access.used(key)
+	if c.dueForRefresh(key, *item) {
+		c.refresh(key)
+	}
+	c.unlock()
+	return item.Object, nil
+}
@@ -0,0 +1,250 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGetOrLoadSingleflight(t *testing.T) {
+	tc := New(DefaultExpiration, 0)
+
+	var calls int32
+	release := make(chan struct{})
+	load := func(k interface{}) (interface{}, time.Duration, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return "value", DefaultExpiration, nil
+	}
+
+	var wg sync.WaitGroup
+	results := make([]interface{}, 10)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			v, err := tc.GetOrLoad("a", load)
+			if err != nil {
+				t.Error(err)
+			}
+			results[i] = v
+		}(i)
+	}
+	<-time.After(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("loader ran %d times, want 1", n)
+	}
+	for i, v := range results {
+		if v != "value" {
+			t.Errorf("caller %d got %v", i, v)
+		}
+	}
+	if x, found := tc.Get("a"); !found || x != "value" {
+		t.Errorf("loaded value wasn't stored: %v %v", x, found)
+	}
+}
+
+func TestLoadDoesNotBlockOtherKeys(t *testing.T) {
+	tc := New(DefaultExpiration, 0)
+	tc.Set("b", 2, DefaultExpiration)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	load := func(k interface{}) (interface{}, time.Duration, error) {
+		close(started)
+		<-release
+		return 1, DefaultExpiration, nil
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		tc.GetAndExtendOrLoad("a", time.Minute, load)
+	}()
+	<-started
+
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		if x, found := tc.Get("b"); !found || x != 2 {
+			t.Errorf("b is %v %v", x, found)
+		}
+		tc.Set("c", 3, DefaultExpiration)
+		tc.GetOrLoadWithFallbackValue("d", func(k interface{}) (interface{}, time.Duration, error) {
+			return 4, DefaultExpiration, nil
+		}, nil)
+	}()
+	select {
+	case <-finished:
+	case <-time.After(time.Second):
+		t.Fatal("other keys were blocked while a loader was running")
+	}
+	close(release)
+	<-done
+
+	if x, found := tc.Get("a"); !found || x != 1 {
+		t.Errorf("a is %v %v", x, found)
+	}
+}
+
+func TestGetOrLoadContextCanceledWhileWaiting(t *testing.T) {
+	tc := New(DefaultExpiration, 0)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		tc.GetOrLoadContext(context.Background(), "a", func(ctx context.Context, k interface{}) (interface{}, time.Duration, error) {
+			close(started)
+			<-release
+			return 1, DefaultExpiration, nil
+		})
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := tc.GetOrLoadContext(ctx, "a", func(ctx context.Context, k interface{}) (interface{}, time.Duration, error) {
+		t.Error("second loader ran while the first was in flight")
+		return 2, DefaultExpiration, nil
+	})
+	if err != context.DeadlineExceeded {
+		t.Errorf("err is %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	close(release)
+	<-done
+	if x, found := tc.Get("a"); !found || x != 1 {
+		t.Errorf("a is %v %v", x, found)
+	}
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := tc.GetOrLoadContext(canceled, "b", nil); err != context.Canceled {
+		t.Errorf("err is %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestGetOrLoadPanic(t *testing.T) {
+	tc := New(DefaultExpiration, 0, WithShards(1))
+	started := make(chan struct{})
+	release := make(chan struct{})
+	waited := make(chan error)
+	go func() {
+		<-started
+		_, err := tc.GetOrLoad("a", func(k interface{}) (interface{}, time.Duration, error) {
+			return 2, DefaultExpiration, nil
+		})
+		waited <- err
+	}()
+	go func() {
+		// Let the waiter join the load before it panics.
+		for {
+			tc.Lock()
+			n := len(tc.loads)
+			tc.Unlock()
+			if n == 1 {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+		close(started)
+		time.Sleep(10 * time.Millisecond)
+		close(release)
+	}()
+
+	_, err := tc.GetAndExtendOrLoad("a", time.Minute, func(k interface{}) (interface{}, time.Duration, error) {
+		<-release
+		panic("boom")
+	})
+	var perr *PanicError
+	if !errors.As(err, &perr) || perr.Value != "boom" || len(perr.Stack) == 0 {
+		t.Fatalf("GetAndExtendOrLoad returned %v, want a PanicError", err)
+	}
+	if err := <-waited; err != nil && !errors.As(err, &perr) {
+		t.Errorf("The waiting GetOrLoad returned %v", err)
+	}
+
+	x, err := tc.GetOrLoad("a", func(k interface{}) (interface{}, time.Duration, error) {
+		return 1, DefaultExpiration, nil
+	})
+	if err != nil || x == nil {
+		t.Errorf("GetOrLoad after a panic returned %v, %v", x, err)
+	}
+}
+
+func TestGetOrLoadWithFallbackValue(t *testing.T) {
+	tc := New(DefaultExpiration, 0)
+	errBackend := errors.New("backend down")
+	load := func(k interface{}) (interface{}, time.Duration, error) {
+		if k == "ok" {
+			return "loaded", DefaultExpiration, nil
+		}
+		return nil, DefaultExpiration, errBackend
+	}
+	fallbackCalls := 0
+	fallback := func(k interface{}, err error) (interface{}, time.Duration, bool) {
+		fallbackCalls++
+		if err != errBackend {
+			t.Errorf("Fallback got error %v", err)
+		}
+		if k == "declined" {
+			return nil, DefaultExpiration, false
+		}
+		return "default", DefaultExpiration, true
+	}
+
+	x, err := tc.GetOrLoadWithFallbackValue("ok", load, fallback)
+	if err != nil || x != "loaded" || fallbackCalls != 0 {
+		t.Errorf("Got %v, %v after %d fallback calls; want loaded", x, err, fallbackCalls)
+	}
+
+	x, err = tc.GetOrLoadWithFallbackValue("fails", load, fallback)
+	if err != nil || x != "default" {
+		t.Errorf("Got %v, %v; want the fallback value", x, err)
+	}
+	if x, found := tc.Get("fails"); !found || x != "default" {
+		t.Errorf("Fallback value was not stored: %v, %v", x, found)
+	}
+
+	x, err = tc.GetOrLoadWithFallbackValue("declined", load, fallback)
+	if err != errBackend || x != nil {
+		t.Errorf("Got %v, %v; want the loader's error", x, err)
+	}
+	if _, found := tc.Get("declined"); found {
+		t.Error("Stored a value for a declined fallback")
+	}
+	if fallbackCalls != 2 {
+		t.Errorf("Fallback was called %d times, want 2", fallbackCalls)
+	}
+}
+
+func TestGetAndExtendOrLoadNoExpiration(t *testing.T) {
+	forever := func(k interface{}) (interface{}, time.Duration, error) {
+		return "v", NoExpiration, nil
+	}
+
+	tc := New(DefaultExpiration, 0)
+	if _, err := tc.GetAndExtendOrLoad("k", time.Millisecond, forever); err != nil {
+		t.Fatal(err)
+	}
+	<-time.After(5 * time.Millisecond)
+	if _, found := tc.Get("k"); !found {
+		t.Error("Loaded item with NoExpiration expired, want it honored")
+	}
+
+	tc = New(DefaultExpiration, 0, WithClampLoadedExpiration())
+	if _, err := tc.GetAndExtendOrLoad("k", time.Millisecond, forever); err != nil {
+		t.Fatal(err)
+	}
+	<-time.After(5 * time.Millisecond)
+	if _, found := tc.Get("k"); found {
+		t.Error("Loaded item with NoExpiration did not expire after d, want it clamped")
+	}
+}
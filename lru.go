@@ -0,0 +1,292 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// WithAccessTracking records every lookup in caches that don't do so anyway.
+// Caches with a limit set with WithMaxEntries or WithMaxCost, or with an
+// eviction policy, record lookups to pick the items to evict. Others don't by
+// default, as recording a lookup takes a mutex that concurrent lookups, which
+// otherwise only share the read lock, would wait on. Without it, EvictLRU and
+// TriggerMemoryPressure evict items in the order they were stored, and
+// Inspect reports no accesses.
+func WithAccessTracking() Option {
+	return func(c *cache) {
+		c.access.track = true
+	}
+}
+
+// Keys in the order they were last used, most recent first. It has its own
+// mutex so lookups holding only the read lock can record accesses. It also
+// keeps the eviction policy, if any, informed under that mutex.
+type accessOrder struct {
+	mu    sync.Mutex
+	list  *list.List
+	elems map[interface{}]*list.Element
+	// Whether lookups are recorded. If not, keys are in the order they
+	// were stored.
+	track bool
+	// The cache's clock, telling when values are stored.
+	now func() time.Time
+	// Picks the items to evict instead of the least recently used ones.
+	// Once a key is given a priority other than PriorityNormal, it only
+	// tracks the keys of normal priority.
+	policy EvictionPolicy
+	// Makes the policies of other priorities, if not the least recently used.
+	newPolicy func() EvictionPolicy
+	// The policies tracking keys of priorities other than PriorityNormal.
+	levels map[Priority]EvictionPolicy
+	// Counts key uses to decide which new keys to admit, if set.
+	filter *tinyLFU
+	// Keys exempt from eviction, which no policy tracks.
+	pinned map[interface{}]bool
+	// Counts the uses of keys in all shards of a cache, to tell which of
+	// the keys of different shards was used least recently. Nil if the
+	// cache isn't split.
+	uses *uint64
+}
+
+// What is known about the use of a key since its value was stored.
+type accessEntry struct {
+	key      interface{}
+	stored   int64
+	accesses uint64
+	priority Priority
+	// The count of uses when the key was last used, if counted.
+	use uint64
+}
+
+// Returns the policy tracking k, or nil if there is none. Must be called with
+// the mutex held.
+func (a *accessOrder) policyFor(k interface{}) EvictionPolicy {
+	if a.pinned[k] {
+		return nil
+	}
+	if e, found := a.elems[k]; found {
+		if p := e.Value.(*accessEntry).priority; p != PriorityNormal {
+			return a.levels[p]
+		}
+	}
+	return a.policy
+}
+
+// Record that a value was stored under k.
+func (a *accessOrder) touch(k interface{}) {
+	now := a.now().UnixNano()
+	a.mu.Lock()
+	if e, found := a.elems[k]; found {
+		a.list.MoveToFront(e)
+		if p := a.policyFor(k); p != nil && p != a.policy {
+			// Storing a new value resets the priority.
+			p.Remove(k)
+		}
+		*e.Value.(*accessEntry) = accessEntry{key: k, stored: now, use: a.use()}
+	} else {
+		if a.list == nil {
+			a.list = list.New()
+			a.elems = map[interface{}]*list.Element{}
+		}
+		a.elems[k] = a.list.PushFront(&accessEntry{key: k, stored: now, use: a.use()})
+	}
+	if p := a.policyFor(k); p != nil {
+		p.RecordInsert(k)
+	}
+	a.mu.Unlock()
+}
+
+// Record that k was used, if it is tracked and lookups are recorded.
+func (a *accessOrder) used(k interface{}) {
+	if !a.track {
+		return
+	}
+	a.mu.Lock()
+	if e, found := a.elems[k]; found {
+		a.list.MoveToFront(e)
+		entry := e.Value.(*accessEntry)
+		entry.accesses++
+		entry.use = a.use()
+		if a.filter != nil {
+			a.filter.add(k)
+		}
+		if p := a.policyFor(k); p != nil {
+			p.RecordAccess(k)
+		}
+	}
+	a.mu.Unlock()
+}
+
+// Count a use of a key, returning the new count, or zero if uses aren't
+// counted.
+func (a *accessOrder) use() uint64 {
+	if a.uses == nil {
+		return 0
+	}
+	return atomic.AddUint64(a.uses, 1)
+}
+
+// Returns what is known about the use of k.
+func (a *accessOrder) entry(k interface{}) (accessEntry, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	e, found := a.elems[k]
+	if !found {
+		return accessEntry{}, false
+	}
+	return *e.Value.(*accessEntry), true
+}
+
+func (a *accessOrder) remove(k interface{}) {
+	a.mu.Lock()
+	if e, found := a.elems[k]; found {
+		if p := a.policyFor(k); p != nil {
+			p.Remove(k)
+		}
+		a.list.Remove(e)
+		delete(a.elems, k)
+		delete(a.pinned, k)
+	}
+	a.mu.Unlock()
+}
+
+// Returns the least recently used key that isn't pinned.
+func (a *accessOrder) oldest() (interface{}, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.lru()
+}
+
+// Returns the least recently used key that isn't pinned. Must be called with
+// the mutex held.
+func (a *accessOrder) lru() (interface{}, bool) {
+	if a.list == nil {
+		return nil, false
+	}
+	for e := a.list.Back(); e != nil; e = e.Prev() {
+		if k := e.Value.(*accessEntry).key; !a.pinned[k] {
+			return k, true
+		}
+	}
+	return nil, false
+}
+
+// Returns the key to evict next: the one picked by the policy, or else the
+// least recently used one.
+func (a *accessOrder) victim() (interface{}, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.levels == nil {
+		if a.policy != nil {
+			return a.policy.Victim()
+		}
+		return a.lru()
+	}
+	for _, p := range []EvictionPolicy{a.levels[PriorityLow], a.policy, a.levels[PriorityHigh]} {
+		if p == nil {
+			continue
+		}
+		if k, found := p.Victim(); found {
+			return k, true
+		}
+	}
+	return nil, false
+}
+
+// Exempt k, which must be tracked, from eviction.
+func (a *accessOrder) pin(k interface{}) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.pinned[k] {
+		return
+	}
+	if a.pinned == nil {
+		a.pinned = map[interface{}]bool{}
+	}
+	if p := a.policyFor(k); p != nil {
+		p.Remove(k)
+	}
+	a.pinned[k] = true
+}
+
+// Make k, if pinned, subject to eviction again.
+func (a *accessOrder) unpin(k interface{}) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if !a.pinned[k] {
+		return
+	}
+	delete(a.pinned, k)
+	if p := a.policyFor(k); p != nil {
+		p.RecordInsert(k)
+	}
+}
+
+// Reports whether k is pinned.
+func (a *accessOrder) isPinned(k interface{}) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.pinned[k]
+}
+
+func (a *accessOrder) reset() {
+	a.mu.Lock()
+	for k := range a.elems {
+		if p := a.policyFor(k); p != nil {
+			p.Remove(k)
+		}
+	}
+	a.list = nil
+	a.elems = nil
+	a.pinned = nil
+	a.mu.Unlock()
+}
+
+func (a *accessOrder) len() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.list == nil {
+		return 0
+	}
+	return a.list.Len()
+}
+
+// EvictLRU evicts up to n of the least recently used unexpired items that
+// aren't pinned, calling OnEvicted for each, and returns the number evicted.
+// Items are ordered by the last time they were stored or returned by a
+// lookup, or only by when they were stored in caches that don't record
+// lookups (see WithAccessTracking). Expired items found along the way are
+// deleted without being counted. This works whether or not the cache has a
+// limit set with WithMaxEntries, and across the shards of a split cache.
+func (c *cache) EvictLRU(n int) int {
+	unlock := c.lockAll()
+	defer unlock()
+
+	oldest := func(s *cache) (interface{}, bool) {
+		return s.access.oldest()
+	}
+	evicted := 0
+	now := c.now().UnixNano()
+	for evicted < n {
+		s, k, found := c.nextVictim(oldest, false)
+		if !found {
+			break
+		}
+		if v := s.items[k]; v.Expiration > 0 && now > v.Expiration {
+			s.evict(k, EventExpire)
+			continue
+		}
+		s.evict(k, EventEvict)
+		evicted++
+	}
+	return evicted
+}
@@ -0,0 +1,75 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEvictLRU(t *testing.T) {
+	tc := New(DefaultExpiration, 0, WithAccessTracking())
+	var evicted []interface{}
+	tc.OnEvicted(func(k interface{}, v interface{}) {
+		evicted = append(evicted, k)
+	})
+	for _, k := range []string{"a", "b", "c", "d", "e"} {
+		tc.Set(k, k, DefaultExpiration)
+	}
+	tc.Set("x", "x", time.Millisecond)
+	tc.Get("a")
+	tc.GetAndExtend("b", DefaultExpiration)
+	tc.Set("c", "c2", DefaultExpiration)
+	<-time.After(5 * time.Millisecond)
+
+	if n := tc.EvictLRU(2); n != 2 {
+		t.Errorf("Evicted %d items, want 2", n)
+	}
+	want := []interface{}{"d", "e"}
+	if len(evicted) != len(want) {
+		t.Fatalf("Evicted %v, want %v", evicted, want)
+	}
+	for i, k := range want {
+		if evicted[i] != k {
+			t.Errorf("Eviction %d was %v, want %v", i, evicted[i], k)
+		}
+	}
+	for _, k := range []string{"a", "b", "c"} {
+		if _, found := tc.Get(k); !found {
+			t.Errorf("Did not find recently used %s", k)
+		}
+	}
+
+	if n := tc.EvictLRU(10); n != 3 {
+		t.Errorf("Evicted %d items, want the remaining 3", n)
+	}
+	if n := tc.ItemCount(); n != 0 {
+		t.Errorf("Item count is %d, want the expired x deleted too", n)
+	}
+	if err := tc.ConsistencyCheck(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLookupsNotRecordedWithoutLimit(t *testing.T) {
+	tc := New(DefaultExpiration, 0)
+	tc.Set("a", 1, DefaultExpiration)
+	tc.Set("b", 2, DefaultExpiration)
+	tc.Get("a")
+	if r, _ := tc.Inspect("a"); r.Accesses != 0 {
+		t.Errorf("Accesses is %d without a limit, want 0", r.Accesses)
+	}
+	var evicted []interface{}
+	tc.OnEvicted(func(k interface{}, v interface{}) {
+		evicted = append(evicted, k)
+	})
+	tc.EvictLRU(1)
+	if len(evicted) != 1 || evicted[0] != "a" {
+		t.Errorf("Evicted %v, want the first stored a", evicted)
+	}
+
+	tc = New(DefaultExpiration, 0, WithMaxEntries(10))
+	tc.Set("a", 1, DefaultExpiration)
+	tc.Get("a")
+	if r, _ := tc.Inspect("a"); r.Accesses != 1 {
+		t.Errorf("Accesses is %d with a limit, want 1", r.Accesses)
+	}
+}
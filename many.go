@@ -0,0 +1,187 @@
+package cache
+
+import (
+	"context"
+	"runtime/debug"
+	"time"
+)
+
+// GetMany gets several items from the cache in a single read lock. The
+// returned map holds the value of every key that was found, under the key as
+// it was passed.
+func (c *cache) GetMany(keys []interface{}) map[interface{}]interface{} {
+	found := make(map[interface{}]interface{}, len(keys))
+	if c.shards != nil {
+		for s, keys := range c.byShard(keys) {
+			for k, v := range s.GetMany(keys) {
+				found[k] = v
+			}
+		}
+		return found
+	}
+	c.RLock()
+	defer c.RUnlock()
+
+	for _, k := range keys {
+		key := c.key(k)
+		item, ok := c.get(key)
+		c.recordLookup(ok)
+		if !ok {
+			continue
+		}
+		c.access.used(key)
+		found[k] = item.Object
+	}
+	return found
+}
+
+// SetMany adds several items to the cache in a single write lock, replacing
+// any existing items, all with the expiration d. See Set.
+func (c *cache) SetMany(items map[interface{}]interface{}, d time.Duration) {
+	if c.shards != nil {
+		byShard := map[*cache]map[interface{}]interface{}{}
+		for k, x := range items {
+			s := c.shard(k)
+			if byShard[s] == nil {
+				byShard[s] = map[interface{}]interface{}{}
+			}
+			byShard[s][k] = x
+		}
+		for s, items := range byShard {
+			s.SetMany(items, d)
+		}
+		return
+	}
+	c.Lock()
+	defer c.unlock()
+
+	for k, x := range items {
+		c.set(c.key(k), x, d)
+	}
+}
+
+// A ValueTTL is a value returned by the loader of GetOrLoadMany, with the
+// duration to store it for.
+type ValueTTL struct {
+	Value interface{}
+	TTL   time.Duration
+}
+
+// GetOrLoadMany gets several items from the cache, loading all the missing
+// ones with a single call to load and storing them. load is called with the
+// missing keys as they were passed, and returns the values it found under
+// the same keys; keys it leaves out are not stored. The returned map holds
+// the value of every key that was found or loaded. If load returns an error,
+// nothing is stored and the error is returned along with the items that were
+// found.
+//
+// Keys that another goroutine is already loading are not passed to load;
+// GetOrLoadMany waits for those loads instead. Likewise, GetOrLoad calls for
+// the keys being loaded wait for load, and get a *KeyError wrapping
+// ErrNotFound if it leaves their key out. Loads are retried as set by
+// WithLoadRetry, count towards WithLoadCircuitBreaker, which returns a
+// *KeyError wrapping ErrCircuitOpen for the first missing key without calling
+// load, and a panic in load is returned as a *PanicError. Errors are not
+// remembered by WithNegativeCacheTTL.
+func (c *cache) GetOrLoadMany(keys []interface{}, load func(missing []interface{}) (map[interface{}]ValueTTL, error)) (map[interface{}]interface{}, error) {
+	found := make(map[interface{}]interface{}, len(keys))
+	waiting := map[interface{}]*loadCall{}
+	var missing []interface{}
+	unlock := c.lockAll()
+	for _, k := range keys {
+		key := c.key(k)
+		s := c.keyShard(key)
+		item, ok := s.get(key)
+		s.recordLookup(ok)
+		if ok {
+			s.access.used(key)
+			found[k] = item.Object
+			continue
+		}
+		s.expireStale(key)
+		if call, loading := s.loads[key]; loading {
+			waiting[k] = call
+		} else {
+			missing = append(missing, k)
+		}
+	}
+
+	var err error
+	if len(missing) > 0 && c.breaker != nil && !c.breaker.allow(c.now().UnixNano()) {
+		missing, err = nil, &KeyError{c.key(missing[0]), ErrCircuitOpen}
+	}
+	// Start a load of every missing key; keys passed twice, or that are
+	// equal once converted by the key function, are loaded once.
+	calls := map[interface{}]*loadCall{}
+	toLoad := missing[:0]
+	for _, k := range missing {
+		key := c.key(k)
+		s := c.keyShard(key)
+		if call, loading := s.loads[key]; loading {
+			if calls[k] == nil {
+				waiting[k] = call
+			}
+			continue
+		}
+		calls[k] = s.startLoad(key)
+		toLoad = append(toLoad, k)
+	}
+	missing = toLoad
+	unlock()
+
+	if len(calls) > 0 {
+		var loaded map[interface{}]ValueTTL
+		start := time.Now()
+		loaded, err = c.runLoadMany(missing, load)
+		c.stats.loaded(time.Since(start), err)
+
+		unlock := c.lockAll()
+		if c.breaker != nil {
+			c.breaker.record(err, c.now().UnixNano())
+		}
+		for k, call := range calls {
+			key := c.key(k)
+			s := c.keyShard(key)
+			delete(s.loads, key)
+			v, ok := loaded[k]
+			switch {
+			case err != nil:
+				call.err = &KeyError{key, err}
+			case !ok:
+				call.err = &KeyError{key, ErrNotFound}
+			default:
+				s.set(key, v.Value, v.TTL)
+				call.val = v.Value
+				found[k] = v.Value
+			}
+		}
+		unlock()
+		for _, call := range calls {
+			close(call.done)
+		}
+	}
+
+	for k, call := range waiting {
+		<-call.done
+		if call.err == nil {
+			found[k] = call.val
+		}
+	}
+	return found, err
+}
+
+// Call load with the missing keys as set by WithLoadRetry, turning a panic
+// into an error.
+func (c *cache) runLoadMany(missing []interface{}, load func(missing []interface{}) (map[interface{}]ValueTTL, error)) (loaded map[interface{}]ValueTTL, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			loaded, err = nil, &PanicError{r, debug.Stack()}
+		}
+	}()
+	x, _, err := c.retry.run(context.Background(), func() (interface{}, time.Duration, error) {
+		m, err := load(missing)
+		return m, 0, err
+	})
+	loaded, _ = x.(map[interface{}]ValueTTL)
+	return loaded, err
+}
@@ -0,0 +1,182 @@
+package cache
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestGetManySetMany(t *testing.T) {
+	tc := New(DefaultExpiration, 0)
+	tc.SetMany(map[interface{}]interface{}{"a": 1, "b": 2, 3: "c"}, DefaultExpiration)
+	tc.Set("d", 4, time.Millisecond)
+	<-time.After(5 * time.Millisecond)
+
+	got := tc.GetMany([]interface{}{"a", 3, "d", "missing"})
+	if len(got) != 2 || got["a"] != 1 || got[3] != "c" {
+		t.Error("GetMany returned", got)
+	}
+	if s := tc.Stats(); s.Hits != 2 || s.Misses != 2 {
+		t.Errorf("GetMany counted %d hits and %d misses, want 2 and 2", s.Hits, s.Misses)
+	}
+}
+
+func TestShardedGetManySetMany(t *testing.T) {
+	sc := New(DefaultExpiration, 0, WithShards(4))
+	items := map[interface{}]interface{}{}
+	var keys []interface{}
+	for i := 0; i < 50; i++ {
+		k := "k" + strconv.Itoa(i)
+		items[k] = i
+		keys = append(keys, k)
+	}
+	sc.SetMany(items, DefaultExpiration)
+
+	got := sc.GetMany(append(keys, "missing"))
+	if len(got) != 50 {
+		t.Errorf("GetMany returned %d items, want 50", len(got))
+	}
+	for k, v := range items {
+		if got[k] != v {
+			t.Errorf("%v is %v, want %v", k, got[k], v)
+		}
+	}
+}
+
+func TestGetOrLoadMany(t *testing.T) {
+	tc := New(DefaultExpiration, 0)
+	tc.Set("a", 1, DefaultExpiration)
+	var calls [][]interface{}
+	load := func(missing []interface{}) (map[interface{}]ValueTTL, error) {
+		calls = append(calls, missing)
+		loaded := map[interface{}]ValueTTL{}
+		for _, k := range missing {
+			if k != "none" {
+				loaded[k] = ValueTTL{k.(string) + "!", NoExpiration}
+			}
+		}
+		return loaded, nil
+	}
+
+	got, err := tc.GetOrLoadMany([]interface{}{"a", "b", "c", "b", "none"}, load)
+	if err != nil {
+		t.Fatal("GetOrLoadMany returned", err)
+	}
+	if len(got) != 3 || got["a"] != 1 || got["b"] != "b!" || got["c"] != "c!" {
+		t.Error("GetOrLoadMany returned", got)
+	}
+	if len(calls) != 1 || len(calls[0]) != 3 {
+		t.Errorf("Loader was called with %v, want one call with b, c and none", calls)
+	}
+	if x, found := tc.Get("c"); !found || x != "c!" {
+		t.Errorf("Loaded item c was not stored: %v", x)
+	}
+	if _, found := tc.Get("none"); found {
+		t.Error("Stored a key the loader left out")
+	}
+
+	calls = nil
+	tc.GetOrLoadMany([]interface{}{"a", "b"}, load)
+	if len(calls) != 0 {
+		t.Errorf("Loader was called with %v when nothing was missing", calls)
+	}
+}
+
+func TestGetOrLoadManyError(t *testing.T) {
+	tc := New(DefaultExpiration, 0)
+	tc.Set("a", 1, DefaultExpiration)
+	errDown := errors.New("down")
+	got, err := tc.GetOrLoadMany([]interface{}{"a", "b"}, func(missing []interface{}) (map[interface{}]ValueTTL, error) {
+		return map[interface{}]ValueTTL{"b": {2, NoExpiration}}, errDown
+	})
+	if err != errDown || len(got) != 1 || got["a"] != 1 {
+		t.Errorf("GetOrLoadMany returned %v, %v; want the found items and %v", got, err, errDown)
+	}
+	if _, found := tc.Get("b"); found {
+		t.Error("Stored an item from a failed load")
+	}
+
+	_, err = tc.GetOrLoadMany([]interface{}{"c"}, func(missing []interface{}) (map[interface{}]ValueTTL, error) {
+		panic("boom")
+	})
+	var perr *PanicError
+	if !errors.As(err, &perr) {
+		t.Errorf("GetOrLoadMany returned %v after a panic, want a PanicError", err)
+	}
+	x, err := tc.GetOrLoad("c", func(k interface{}) (interface{}, time.Duration, error) {
+		return 3, DefaultExpiration, nil
+	})
+	if err != nil || x != 3 {
+		t.Errorf("GetOrLoad after a panic returned %v, %v", x, err)
+	}
+}
+
+func TestShardedGetOrLoadMany(t *testing.T) {
+	sc := New(DefaultExpiration, 0, WithShards(4))
+	keys := make([]interface{}, 20)
+	for i := range keys {
+		keys[i] = i
+	}
+	calls := 0
+	got, err := sc.GetOrLoadMany(keys, func(missing []interface{}) (map[interface{}]ValueTTL, error) {
+		calls++
+		loaded := map[interface{}]ValueTTL{}
+		for _, k := range missing {
+			loaded[k] = ValueTTL{k.(int) * 2, DefaultExpiration}
+		}
+		return loaded, nil
+	})
+	if err != nil || len(got) != 20 || got[7] != 14 {
+		t.Errorf("GetOrLoadMany returned %v, %v", got, err)
+	}
+	if calls != 1 {
+		t.Errorf("GetOrLoadMany called the loader %d times, want 1", calls)
+	}
+	if n := sc.ItemCount(); n != 20 {
+		t.Errorf("Item count is %d, want 20", n)
+	}
+}
+
+func TestUpdateMany(t *testing.T) {
+	tc := New(DefaultExpiration, 0)
+	tc.Set("total", 0, DefaultExpiration)
+	tc.Set("tmp", "x", DefaultExpiration)
+
+	const workers, updates = 8, 100
+	wg := new(sync.WaitGroup)
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func(i int) {
+			defer wg.Done()
+			key := "item" + strconv.Itoa(i)
+			for j := 0; j < updates; j++ {
+				tc.UpdateMany([]interface{}{key, "total"}, func(cur map[interface{}]interface{}) map[interface{}]interface{} {
+					n, _ := cur[key].(int)
+					return map[interface{}]interface{}{
+						key:     n + 1,
+						"total": cur["total"].(int) + 1,
+					}
+				}, DefaultExpiration)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	sum := 0
+	for i := 0; i < workers; i++ {
+		x, _ := tc.Get("item" + strconv.Itoa(i))
+		sum += x.(int)
+	}
+	if total, _ := tc.Get("total"); total.(int) != sum || sum != workers*updates {
+		t.Errorf("total is %v and items sum to %d, want %d", total, sum, workers*updates)
+	}
+
+	tc.UpdateMany([]interface{}{"tmp"}, func(cur map[interface{}]interface{}) map[interface{}]interface{} {
+		return nil
+	}, DefaultExpiration)
+	if _, found := tc.Get("tmp"); found {
+		t.Error("Found tmp after it was omitted from the update")
+	}
+}
@@ -0,0 +1,209 @@
+package memcached
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A Client talks to one memcached server, such as a Server. It holds one
+// connection, which its methods use in turn, and dials again after an error.
+type Client struct {
+	// The largest value in bytes that the client reads from the server, or
+	// zero for DefaultMaxItemSize. Set it before use.
+	MaxItemSize int
+
+	addr string
+
+	mu   sync.Mutex
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// NewClient returns a client for the server at addr. It connects on first
+// use.
+func NewClient(addr string) *Client {
+	return &Client{addr: addr}
+}
+
+// A ServerError is an error reply from the server.
+type ServerError struct {
+	Reply string
+}
+
+func (e *ServerError) Error() string {
+	return "memcached: " + e.Reply
+}
+
+// ErrInvalidKey is returned for keys that can't be sent: empty ones, those
+// longer than 250 bytes and those with spaces or line breaks.
+var ErrInvalidKey = errors.New("memcached: invalid key")
+
+// Unavailable reports whether err, returned by c, means that the server could
+// not be reached or did not answer, rather than that the request was refused
+// with an error reply or an invalid key. A cachepool.Pool uses it to tell
+// failing nodes.
+func (c *Client) Unavailable(err error) bool {
+	var se *ServerError
+	return err != nil && !errors.As(err, &se) && !errors.Is(err, ErrInvalidKey)
+}
+
+// Get returns the value stored under key, and whether it was found.
+func (c *Client) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	if err := checkKey(key); err != nil {
+		return nil, false, err
+	}
+	var value []byte
+	found := false
+	err := c.do(ctx, "get "+key+"\r\n", nil, func(r *bufio.Reader) error {
+		for {
+			line, err := readLine(r)
+			if err != nil {
+				return err
+			}
+			if line == "END" {
+				return nil
+			}
+			fields := strings.Fields(line)
+			if len(fields) == 0 || fields[0] != "VALUE" {
+				return &ServerError{line}
+			}
+			n, err := strconv.Atoi(fields[len(fields)-1])
+			if len(fields) != 4 || err != nil || n < 0 {
+				return fmt.Errorf("memcached: malformed reply %q", line)
+			}
+			if n > maxItemSize(c.MaxItemSize) {
+				return fmt.Errorf("memcached: value of %d bytes is too large", n)
+			}
+			data := make([]byte, n+2)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return err
+			}
+			value, found = data[:n], true
+		}
+	})
+	return value, found, err
+}
+
+// Set stores value under key for ttl, or without expiration if ttl is not
+// greater than zero. The TTL is sent in whole seconds, rounded up.
+func (c *Client) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	exptime := int64(0)
+	if ttl > 0 {
+		exptime = int64((ttl + time.Second - 1) / time.Second)
+	}
+	if exptime > maxRelativeExptime {
+		exptime += time.Now().Unix()
+	}
+	if value == nil {
+		// The data block is sent even if empty.
+		value = []byte{}
+	}
+	cmd := fmt.Sprintf("set %s 0 %d %d\r\n", key, exptime, len(value))
+	return c.do(ctx, cmd, value, expect("STORED"))
+}
+
+// Delete deletes key. Deleting a missing key is not an error.
+func (c *Client) Delete(ctx context.Context, key string) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	return c.do(ctx, "delete "+key+"\r\n", nil, expect("DELETED", "NOT_FOUND"))
+}
+
+// Close closes the connection, if any. The client dials again if it is used
+// afterwards.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+// Returns a reply reader accepting the given lines.
+func expect(replies ...string) func(*bufio.Reader) error {
+	return func(r *bufio.Reader) error {
+		line, err := readLine(r)
+		if err != nil {
+			return err
+		}
+		for _, reply := range replies {
+			if line == reply {
+				return nil
+			}
+		}
+		return &ServerError{line}
+	}
+}
+
+// Returns an error if key can't be sent.
+func checkKey(key string) error {
+	if key == "" || len(key) > maxKeyLength || strings.ContainsAny(key, " \t\r\n") {
+		return fmt.Errorf("%w %q", ErrInvalidKey, key)
+	}
+	return nil
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// Send cmd followed by data, if not nil, and read the reply with read. The
+// connection is closed if anything fails other than the server replying with
+// an error.
+func (c *Client) do(ctx context.Context, cmd string, data []byte, read func(*bufio.Reader) error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", c.addr)
+		if err != nil {
+			return err
+		}
+		c.conn, c.r = conn, bufio.NewReader(conn)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		c.conn.SetDeadline(deadline)
+	} else {
+		c.conn.SetDeadline(time.Time{})
+	}
+	err := c.send(cmd, data)
+	if err == nil {
+		err = read(c.r)
+	}
+	var serr *ServerError
+	if err != nil && !errors.As(err, &serr) {
+		c.conn.Close()
+		c.conn = nil
+	}
+	return err
+}
+
+func (c *Client) send(cmd string, data []byte) error {
+	w := bufio.NewWriter(c.conn)
+	w.WriteString(cmd)
+	if data != nil {
+		w.Write(data)
+		w.WriteString("\r\n")
+	}
+	return w.Flush()
+}
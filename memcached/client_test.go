@@ -0,0 +1,92 @@
+package memcached
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/rumsrami/cache"
+)
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc := cache.New(cache.NoExpiration, 0)
+	s := NewServer(tc)
+	go s.Serve(l)
+	defer s.Close()
+
+	c := NewClient(l.Addr().String())
+	defer c.Close()
+	if _, found, err := c.Get(ctx, "a"); err != nil || found {
+		t.Fatalf("got %v, %v, want a miss", found, err)
+	}
+	if err := c.Set(ctx, "a", []byte("hello"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if v, found, err := c.Get(ctx, "a"); err != nil || !found || string(v) != "hello" {
+		t.Errorf("got %q, %v, %v, want hello", v, found, err)
+	}
+	if ttl, _ := tc.TTL("a"); ttl <= 59*time.Second || ttl > time.Minute {
+		t.Errorf("got TTL %v, want a minute", ttl)
+	}
+	if err := c.Set(ctx, "b", nil, 0); err != nil {
+		t.Fatal(err)
+	}
+	if ttl, _ := tc.TTL("b"); ttl != cache.NoExpiration {
+		t.Errorf("got TTL %v, want no expiration", ttl)
+	}
+	if err := c.Delete(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Delete(ctx, "a"); err != nil {
+		t.Errorf("deleting a missing key: %v", err)
+	}
+	if _, _, err := c.Get(ctx, "bad key"); err == nil || c.Unavailable(err) {
+		t.Errorf("got %v for a key with a space, want ErrInvalidKey", err)
+	}
+
+	// The client dials again after the connection breaks.
+	s.Close()
+	if _, _, err := c.Get(ctx, "b"); err == nil || !c.Unavailable(err) {
+		t.Errorf("got %v from a closed server, want an error that it is unavailable", err)
+	}
+	l, err = net.Listen("tcp", l.Addr().String())
+	if err != nil {
+		t.Skip("can't listen on the same address again:", err)
+	}
+	s = NewServer(tc)
+	go s.Serve(l)
+	defer s.Close()
+	if _, found, err := c.Get(ctx, "b"); err != nil || !found {
+		t.Errorf("got %v, %v after reconnecting, want b", found, err)
+	}
+
+}
+
+func TestClientMaxItemSize(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Read(make([]byte, 100))
+		conn.Write([]byte("VALUE a 0 9223372036854775807\r\n"))
+	}()
+
+	c := NewClient(l.Addr().String())
+	defer c.Close()
+	if _, _, err := c.Get(context.Background(), "a"); err == nil {
+		t.Error("got no error for a value larger than the limit")
+	}
+}
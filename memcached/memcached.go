@@ -0,0 +1,467 @@
+// Package memcached serves a cache over the memcached text protocol, so that
+// memcached clients, e.g. in other languages, can use a Go process's cache.
+//
+//	c := cache.New(cache.NoExpiration, 10*time.Minute)
+//	l, _ := net.Listen("tcp", ":11211")
+//	go memcached.NewServer(c).Serve(l)
+//
+// It supports the get, gets, set, add, replace, append, prepend, delete, incr,
+// decr, touch, flush_all, version and quit commands. Values stored by clients
+// are kept as Items. Values stored by Go code are served with zero flags if they
+// are []byte or strings, and are missing otherwise; as their expiration is not
+// known, incr, decr, append and prepend make them never expire, and leave other
+// values alone. CAS is not supported: gets returns a CAS value of 0. A Client
+// talks to such a server.
+package memcached
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rumsrami/cache"
+)
+
+// A Cache holds the items served, such as a *cache.Cache. Keys are strings.
+type Cache interface {
+	Get(k interface{}) (interface{}, bool)
+	Set(k interface{}, x interface{}, d time.Duration)
+	Add(k interface{}, x interface{}, d time.Duration) error
+	Replace(k interface{}, x interface{}, d time.Duration) error
+	Update(k interface{}, fn func(old interface{}, exists bool) (new interface{}, d time.Duration, keep bool))
+	Flush()
+}
+
+// An Item is a value stored by a client.
+type Item struct {
+	// Opaque flags stored with the value.
+	Flags uint32
+	Value []byte
+	// When the item expires, or the zero time if it never does. The cache
+	// expires the item by itself; this is kept so that incr, decr, append
+	// and prepend can keep the expiration.
+	Expiration time.Time
+}
+
+// The longest exptime in seconds that is relative to now; longer ones are
+// Unix times.
+const maxRelativeExptime = 30 * 24 * 60 * 60
+
+// The longest key memcached accepts.
+const maxKeyLength = 250
+
+// DefaultMaxItemSize is the largest value in bytes that a Server accepts and
+// a Client reads, unless their MaxItemSize says otherwise. It is memcached's
+// default.
+const DefaultMaxItemSize = 1 << 20
+
+// Version is reported by the version command.
+const Version = "1.6.0-cache"
+
+// A Server serves the memcached protocol for a cache.
+type Server struct {
+	// The largest value in bytes that clients can store, or zero for
+	// DefaultMaxItemSize. Set it before serving.
+	MaxItemSize int
+
+	c Cache
+	// The time, for tests.
+	now func() time.Time
+
+	mu        sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]struct{}
+	closed    bool
+}
+
+// NewServer returns a server for c.
+func NewServer(c Cache) *Server {
+	return &Server{c: c, now: time.Now}
+}
+
+// ErrServerClosed is returned by Serve after Close.
+var ErrServerClosed = errors.New("memcached: server closed")
+
+// Serve accepts connections on l and serves each one in its own goroutine,
+// until l fails or the server is closed.
+func (s *Server) Serve(l net.Listener) error {
+	if !s.track(l, nil) {
+		return ErrServerClosed
+	}
+	defer s.untrack(l, nil)
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return ErrServerClosed
+			}
+			return err
+		}
+		if !s.track(nil, conn) {
+			conn.Close()
+			return ErrServerClosed
+		}
+		go func() {
+			defer s.untrack(nil, conn)
+			defer conn.Close()
+			s.ServeConn(conn)
+		}()
+	}
+}
+
+// Close closes the listeners and connections of the server.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	for l := range s.listeners {
+		l.Close()
+	}
+	for c := range s.conns {
+		c.Close()
+	}
+	return nil
+}
+
+// Record l or c as open. Returns false if the server is closed.
+func (s *Server) track(l net.Listener, c net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	if l != nil {
+		if s.listeners == nil {
+			s.listeners = map[net.Listener]struct{}{}
+		}
+		s.listeners[l] = struct{}{}
+	}
+	if c != nil {
+		if s.conns == nil {
+			s.conns = map[net.Conn]struct{}{}
+		}
+		s.conns[c] = struct{}{}
+	}
+	return true
+}
+
+func (s *Server) untrack(l net.Listener, c net.Conn) {
+	s.mu.Lock()
+	delete(s.listeners, l)
+	delete(s.conns, c)
+	s.mu.Unlock()
+}
+
+// ServeConn serves the commands read from conn until it is closed, a quit
+// command is read or writing fails.
+func (s *Server) ServeConn(conn io.ReadWriter) error {
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			fmt.Fprint(w, "ERROR\r\n")
+		} else if fields[0] == "quit" {
+			return w.Flush()
+		} else if err := s.command(r, w, fields); err != nil {
+			return err
+		}
+		if r.Buffered() == 0 {
+			if err := w.Flush(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// A client error, reported to the client.
+type clientError string
+
+// Run the command in fields, reading its data block from r if it has one and
+// writing the reply to w. Returns an error only if the connection must be
+// closed.
+func (s *Server) command(r *bufio.Reader, w *bufio.Writer, fields []string) error {
+	name, args := fields[0], fields[1:]
+	noreply := len(args) > 0 && args[len(args)-1] == "noreply"
+	if noreply {
+		args = args[:len(args)-1]
+	}
+	var reply string
+	var err error
+	switch name {
+	case "get", "gets":
+		return s.get(w, args, name == "gets")
+	case "set", "add", "replace", "append", "prepend":
+		reply, err = s.store(r, name, args)
+	case "delete":
+		reply, err = s.delete(args)
+	case "incr", "decr":
+		reply, err = s.incr(args, name == "decr")
+	case "touch":
+		reply, err = s.touch(args)
+	case "flush_all":
+		s.c.Flush()
+		reply = "OK"
+	case "version":
+		reply = "VERSION " + Version
+	default:
+		reply = "ERROR"
+	}
+	if cerr, ok := err.(clientError); ok {
+		// Client errors are reported even with noreply.
+		_, err = fmt.Fprintf(w, "CLIENT_ERROR %s\r\n", string(cerr))
+		return err
+	}
+	if err != nil || noreply {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\r\n", reply)
+	return err
+}
+
+func (e clientError) Error() string {
+	return string(e)
+}
+
+// Returns the limit on value sizes set to max.
+func maxItemSize(max int) int {
+	if max <= 0 {
+		return DefaultMaxItemSize
+	}
+	return max
+}
+
+// Returns the value of x and its flags, if it can be served.
+func value(x interface{}) (Item, bool) {
+	switch v := x.(type) {
+	case Item:
+		return v, true
+	case []byte:
+		return Item{Value: v}, true
+	case string:
+		return Item{Value: []byte(v)}, true
+	}
+	return Item{}, false
+}
+
+func (s *Server) get(w *bufio.Writer, keys []string, cas bool) error {
+	if len(keys) == 0 {
+		_, err := fmt.Fprint(w, "ERROR\r\n")
+		return err
+	}
+	for _, k := range keys {
+		x, found := s.c.Get(k)
+		if !found {
+			continue
+		}
+		item, ok := value(x)
+		if !ok {
+			continue
+		}
+		if cas {
+			fmt.Fprintf(w, "VALUE %s %d %d 0\r\n", k, item.Flags, len(item.Value))
+		} else {
+			fmt.Fprintf(w, "VALUE %s %d %d\r\n", k, item.Flags, len(item.Value))
+		}
+		w.Write(item.Value)
+		w.WriteString("\r\n")
+	}
+	_, err := fmt.Fprint(w, "END\r\n")
+	return err
+}
+
+// Returns the expiration of an item stored with exptime and the duration to
+// store it for. Items that expire right away are stored for a nanosecond.
+func (s *Server) expiration(exptime int64) (time.Time, time.Duration) {
+	now := s.now()
+	var e time.Time
+	switch {
+	case exptime == 0:
+		return time.Time{}, -1
+	case exptime < 0:
+		return now, time.Nanosecond
+	case exptime <= maxRelativeExptime:
+		e = now.Add(time.Duration(exptime) * time.Second)
+	default:
+		e = time.Unix(exptime, 0)
+	}
+	if d := e.Sub(now); d > 0 {
+		return e, d
+	}
+	return e, time.Nanosecond
+}
+
+// Returns the duration to keep storing item for.
+func (s *Server) remaining(item Item) time.Duration {
+	if item.Expiration.IsZero() {
+		return -1
+	}
+	if d := item.Expiration.Sub(s.now()); d > 0 {
+		return d
+	}
+	return time.Nanosecond
+}
+
+func (s *Server) store(r *bufio.Reader, cmd string, args []string) (string, error) {
+	if len(args) != 4 {
+		return "ERROR", nil
+	}
+	k := args[0]
+	flags, err1 := strconv.ParseUint(args[1], 10, 32)
+	exptime, err2 := strconv.ParseInt(args[2], 10, 64)
+	n, err3 := strconv.Atoi(args[3])
+	if err1 != nil || err2 != nil || err3 != nil || n < 0 {
+		return "", clientError("bad command line format")
+	}
+	if n > maxItemSize(s.MaxItemSize) {
+		// Skip the data block and its line end without reading them
+		// into memory.
+		if _, err := io.CopyN(io.Discard, r, int64(n)); err != nil {
+			return "", err
+		}
+		if _, err := io.CopyN(io.Discard, r, 2); err != nil {
+			return "", err
+		}
+		return "", clientError("object too large for cache")
+	}
+	data := make([]byte, n+2)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return "", err
+	}
+	if string(data[n:]) != "\r\n" {
+		// Skip the rest of the line, not to run it as a command.
+		if data[n+1] != '\n' {
+			if _, err := r.ReadString('\n'); err != nil {
+				return "", err
+			}
+		}
+		return "", clientError("bad data chunk")
+	}
+	if len(k) > maxKeyLength {
+		return "", clientError("key too long")
+	}
+	e, d := s.expiration(exptime)
+	item := Item{uint32(flags), data[:n], e}
+
+	switch cmd {
+	case "set":
+		s.c.Set(k, item, d)
+	case "add":
+		if s.c.Add(k, item, d) != nil {
+			return "NOT_STORED", nil
+		}
+	case "replace":
+		if s.c.Replace(k, item, d) != nil {
+			return "NOT_STORED", nil
+		}
+	default:
+		stored := false
+		s.c.Update(k, func(old interface{}, exists bool) (interface{}, time.Duration, bool) {
+			prev, ok := value(old)
+			if !exists || !ok {
+				return cache.Unchanged, 0, false
+			}
+			stored = true
+			if cmd == "append" {
+				prev.Value = append(append([]byte(nil), prev.Value...), item.Value...)
+			} else {
+				prev.Value = append(append([]byte(nil), item.Value...), prev.Value...)
+			}
+			return prev, s.remaining(prev), true
+		})
+		if !stored {
+			return "NOT_STORED", nil
+		}
+	}
+	return "STORED", nil
+}
+
+func (s *Server) delete(args []string) (string, error) {
+	if len(args) != 1 {
+		return "ERROR", nil
+	}
+	deleted := false
+	s.c.Update(args[0], func(old interface{}, exists bool) (interface{}, time.Duration, bool) {
+		deleted = exists
+		return nil, 0, false
+	})
+	if !deleted {
+		return "NOT_FOUND", nil
+	}
+	return "DELETED", nil
+}
+
+func (s *Server) incr(args []string, decr bool) (string, error) {
+	if len(args) != 2 {
+		return "ERROR", nil
+	}
+	delta, err := strconv.ParseUint(args[1], 10, 64)
+	if err != nil {
+		return "", clientError("invalid numeric delta argument")
+	}
+	var reply string
+	var cerr error
+	s.c.Update(args[0], func(old interface{}, exists bool) (interface{}, time.Duration, bool) {
+		prev, ok := value(old)
+		if !exists || !ok {
+			reply = "NOT_FOUND"
+			return cache.Unchanged, 0, false
+		}
+		n, err := strconv.ParseUint(string(prev.Value), 10, 64)
+		if err != nil {
+			cerr = clientError("cannot increment or decrement non-numeric value")
+			return cache.Unchanged, 0, false
+		}
+		switch {
+		case !decr:
+			n += delta
+		case delta > n:
+			n = 0
+		default:
+			n -= delta
+		}
+		reply = strconv.FormatUint(n, 10)
+		return Item{prev.Flags, []byte(reply), prev.Expiration}, s.remaining(prev), true
+	})
+	return reply, cerr
+}
+
+func (s *Server) touch(args []string) (string, error) {
+	if len(args) != 2 {
+		return "ERROR", nil
+	}
+	exptime, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return "", clientError("invalid exptime argument")
+	}
+	e, d := s.expiration(exptime)
+	touched := false
+	s.c.Update(args[0], func(old interface{}, exists bool) (interface{}, time.Duration, bool) {
+		touched = exists
+		if item, ok := old.(Item); ok {
+			item.Expiration = e
+			return item, d, exists
+		}
+		return old, d, exists
+	})
+	if !touched {
+		return "NOT_FOUND", nil
+	}
+	return "TOUCHED", nil
+}
@@ -0,0 +1,220 @@
+package memcached
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rumsrami/cache"
+)
+
+// A client connection to a server for tests.
+type conn struct {
+	t *testing.T
+	net.Conn
+	r *bufio.Reader
+}
+
+func dial(t *testing.T, c Cache) *conn {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(c)
+	go s.Serve(l)
+	t.Cleanup(func() { s.Close() })
+	nc, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &conn{t, nc, bufio.NewReader(nc)}
+}
+
+// Send req and check that the reply is want.
+func (c *conn) do(req, want string) {
+	c.t.Helper()
+	if _, err := fmt.Fprint(c, req); err != nil {
+		c.t.Fatal(err)
+	}
+	var got strings.Builder
+	for got.Len() < len(want) {
+		line, err := c.r.ReadString('\n')
+		got.WriteString(line)
+		if err != nil {
+			break
+		}
+	}
+	if got.String() != want {
+		c.t.Errorf("%q: got %q, want %q", req, got.String(), want)
+	}
+}
+
+func TestStorage(t *testing.T) {
+	tc := cache.New(cache.NoExpiration, 0)
+	c := dial(t, tc)
+
+	c.do("get a\r\n", "END\r\n")
+	c.do("set a 5 0 5\r\nhello\r\n", "STORED\r\n")
+	c.do("get a b\r\n", "VALUE a 5 5\r\nhello\r\nEND\r\n")
+	c.do("gets a\r\n", "VALUE a 5 5 0\r\nhello\r\nEND\r\n")
+	c.do("add a 0 0 1\r\nx\r\n", "NOT_STORED\r\n")
+	c.do("add b 0 0 1\r\nx\r\n", "STORED\r\n")
+	c.do("replace c 0 0 1\r\nx\r\n", "NOT_STORED\r\n")
+	c.do("replace b 0 0 1\r\ny\r\n", "STORED\r\n")
+	c.do("append a 0 0 1\r\n!\r\n", "STORED\r\n")
+	c.do("prepend a 0 0 2\r\n> \r\n", "STORED\r\n")
+	c.do("prepend c 0 0 2\r\n> \r\n", "NOT_STORED\r\n")
+	c.do("get a b\r\n", "VALUE a 5 8\r\n> hello!\r\nVALUE b 0 1\r\ny\r\nEND\r\n")
+	x, _ := tc.Get("b")
+	if item, ok := x.(Item); !ok || string(item.Value) != "y" {
+		t.Errorf("got %#v, want an Item holding y", x)
+	}
+	c.do("delete a\r\n", "DELETED\r\n")
+	c.do("delete a\r\n", "NOT_FOUND\r\n")
+	c.do("set a 0 0 1 noreply\r\nz\r\nget a\r\n", "VALUE a 0 1\r\nz\r\nEND\r\n")
+	c.do("set a 0 0 1\r\ntoo long\r\n", "CLIENT_ERROR bad data chunk\r\n")
+	c.do("flush_all\r\n", "OK\r\n")
+	c.do("get a b\r\n", "END\r\n")
+	c.do("bogus\r\n", "ERROR\r\n")
+	c.do("version\r\n", "VERSION "+Version+"\r\n")
+
+	// Values stored by Go code.
+	tc.Set("s", "str", cache.DefaultExpiration)
+	tc.Set("i", 1, cache.DefaultExpiration)
+	c.do("get s i\r\n", "VALUE s 0 3\r\nstr\r\nEND\r\n")
+}
+
+func TestIncr(t *testing.T) {
+	c := dial(t, cache.New(cache.NoExpiration, 0))
+
+	c.do("incr n 1\r\n", "NOT_FOUND\r\n")
+	c.do("set n 3 0 2\r\n10\r\n", "STORED\r\n")
+	c.do("incr n 5\r\n", "15\r\n")
+	c.do("decr n 6\r\n", "9\r\n")
+	c.do("decr n 100\r\n", "0\r\n")
+	c.do("incr n 18446744073709551615\r\n", "18446744073709551615\r\n")
+	c.do("incr n 2\r\n", "1\r\n")
+	c.do("get n\r\n", "VALUE n 3 1\r\n1\r\nEND\r\n")
+	c.do("incr n x\r\n", "CLIENT_ERROR invalid numeric delta argument\r\n")
+	c.do("set s 0 0 1\r\na\r\n", "STORED\r\n")
+	c.do("incr s 1\r\n", "CLIENT_ERROR cannot increment or decrement non-numeric value\r\n")
+}
+
+func TestExpiration(t *testing.T) {
+	tc := cache.New(cache.NoExpiration, 0)
+	c := dial(t, tc)
+
+	c.do("set a 0 -1 1\r\na\r\n", "STORED\r\n")
+	time.Sleep(time.Millisecond)
+	c.do("get a\r\n", "END\r\n")
+
+	c.do("set a 0 100 1\r\na\r\n", "STORED\r\n")
+	if _, e, _ := tc.GetWithExpiration("a"); time.Until(e) <= 99*time.Second {
+		t.Errorf("got expiration %v, want 100s from now", e)
+	}
+	c.do("incr a 1\r\n", "CLIENT_ERROR cannot increment or decrement non-numeric value\r\n")
+	c.do("append a 0 0 1\r\nb\r\n", "STORED\r\n")
+	if _, e, _ := tc.GetWithExpiration("a"); time.Until(e) <= 99*time.Second {
+		t.Errorf("append: got expiration %v, want 100s from now", e)
+	}
+
+	c.do("touch b 0\r\n", "NOT_FOUND\r\n")
+	c.do("touch a 0\r\n", "TOUCHED\r\n")
+	if _, e, _ := tc.GetWithExpiration("a"); !e.IsZero() {
+		t.Errorf("touch 0: got expiration %v, want none", e)
+	}
+	c.do(fmt.Sprintf("touch a %d\r\n", time.Now().Add(time.Hour).Unix()), "TOUCHED\r\n")
+	if _, e, _ := tc.GetWithExpiration("a"); time.Until(e) <= 59*time.Minute {
+		t.Errorf("touch to a Unix time: got expiration %v, want an hour from now", e)
+	}
+	c.do("touch a -1\r\n", "TOUCHED\r\n")
+	time.Sleep(time.Millisecond)
+	c.do("get a\r\n", "END\r\n")
+}
+
+func TestUnsupportedValue(t *testing.T) {
+	tc := cache.New(cache.NoExpiration, 0)
+	c := dial(t, tc)
+
+	tc.Set("i", 1, time.Hour)
+	events, cancel := tc.Watch("i")
+	defer cancel()
+	c.do("append i 0 0 1\r\n2\r\n", "NOT_STORED\r\n")
+	c.do("prepend i 0 0 1\r\n2\r\n", "NOT_STORED\r\n")
+	c.do("incr i 1\r\n", "NOT_FOUND\r\n")
+	c.do("decr i 1\r\n", "NOT_FOUND\r\n")
+	x, e, found := tc.GetWithExpiration("i")
+	if !found || x != 1 || time.Until(e) <= 59*time.Minute {
+		t.Errorf("got %v, %v, %t; want 1 expiring in an hour", x, e, found)
+	}
+	if n := len(events); n != 0 {
+		t.Errorf("got %d events, want none", n)
+	}
+}
+
+func TestSharded(t *testing.T) {
+	c := dial(t, cache.New(cache.NoExpiration, 0, cache.WithShards(4)))
+	c.do("set a 0 0 1\r\n1\r\n", "STORED\r\n")
+	c.do("incr a 1\r\n", "2\r\n")
+	c.do("delete a\r\n", "DELETED\r\n")
+}
+
+func TestQuitAndClose(t *testing.T) {
+	c := dial(t, cache.New(cache.NoExpiration, 0))
+	fmt.Fprint(c, "quit\r\n")
+	if _, err := c.r.ReadByte(); err == nil {
+		t.Error("connection still open after quit")
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(cache.New(cache.NoExpiration, 0))
+	done := make(chan error)
+	go func() { done <- s.Serve(l) }()
+	nc, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprint(nc, "version\r\n")
+	bufio.NewReader(nc).ReadString('\n')
+	s.Close()
+	if err := <-done; err != ErrServerClosed {
+		t.Errorf("Serve returned %v, want ErrServerClosed", err)
+	}
+	if _, err := nc.Read(make([]byte, 1)); err == nil {
+		t.Error("connection still open after Close")
+	}
+}
+
+func TestMaxItemSize(t *testing.T) {
+	tc := cache.New(cache.NoExpiration, 0)
+	c := dial(t, tc)
+
+	big := strings.Repeat("x", DefaultMaxItemSize+1)
+	c.do(fmt.Sprintf("set a 0 0 %d\r\n%s\r\n", len(big), big), "CLIENT_ERROR object too large for cache\r\n")
+	c.do("get a\r\n", "END\r\n")
+
+	// A huge size is rejected without allocating it.
+	s := NewServer(tc)
+	s.MaxItemSize = 4
+	var out strings.Builder
+	rw := struct {
+		io.Reader
+		io.Writer
+	}{io.MultiReader(
+		strings.NewReader("set b 0 0 5\r\nhello\r\nget b\r\n"),
+		strings.NewReader("set c 0 0 9223372036854775807\r\nxx"),
+	), &out}
+	if err := s.ServeConn(rw); err == nil {
+		t.Error("got no error for a truncated data block")
+	}
+	if want := "CLIENT_ERROR object too large for cache\r\nEND\r\n"; out.String() != want {
+		t.Errorf("got %q, want %q", out.String(), want)
+	}
+}
@@ -0,0 +1,29 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// Memoize returns a function that calls f only for keys that aren't in c,
+// storing its results in c for d, and returns the cached result otherwise. It
+// is built on GetOrLoadContext: concurrent calls for the same missing key share
+// one call to f, and the cache's options such as WithNegativeCacheTTL,
+// WithLoadRetry and WithLoadCircuitBreaker apply. Errors from f are returned
+// as they are and nothing is stored for them. If f shares c with other
+// functions or with other uses, their keys must not collide; a Namespace
+// doesn't help here since it only takes string keys, so use keys of a
+// distinct type.
+func Memoize[K comparable, V any](c *Cache, d time.Duration, f func(ctx context.Context, k K) (V, error)) func(ctx context.Context, k K) (V, error) {
+	return func(ctx context.Context, k K) (V, error) {
+		x, err := c.GetOrLoadContext(ctx, k, func(ctx context.Context, k interface{}) (interface{}, time.Duration, error) {
+			v, err := f(ctx, k.(K))
+			return v, d, err
+		})
+		if err != nil {
+			var zero V
+			return zero, err
+		}
+		return value[V](x), nil
+	}
+}
@@ -0,0 +1,43 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestMemoize(t *testing.T) {
+	tc := New(DefaultExpiration, 0)
+	calls := 0
+	errOdd := errors.New("odd")
+	itoa := Memoize(tc, time.Minute, func(ctx context.Context, n int) (string, error) {
+		calls++
+		if n%2 == 1 {
+			return "", errOdd
+		}
+		return strconv.Itoa(n), nil
+	})
+
+	for i := 0; i < 3; i++ {
+		if s, err := itoa(context.Background(), 42); err != nil || s != "42" {
+			t.Fatalf("Memoized function returned %q, %v", s, err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("Called the function %d times, want once", calls)
+	}
+	if ttl, _ := tc.TTL(42); ttl <= 0 || ttl > time.Minute {
+		t.Errorf("TTL of the memoized result is %v, want up to 1m", ttl)
+	}
+
+	for i := 0; i < 2; i++ {
+		if s, err := itoa(context.Background(), 7); err != errOdd || s != "" {
+			t.Errorf("Memoized function returned %q, %v; want %v", s, err, errOdd)
+		}
+	}
+	if calls != 3 {
+		t.Errorf("Called the function %d times, want 3: errors must not be cached", calls)
+	}
+}
@@ -0,0 +1,115 @@
+package cache
+
+import "time"
+
+// Separates a namespace's name from the keys in it.
+const namespaceSeparator = ":"
+
+// A Namespace is a view of a cache in which every key is prefixed with the
+// namespace's name and a colon, so that several subsystems can share one cache
+// without their keys colliding. The items are stored in the underlying cache:
+// the key "42" in the "sessions" namespace is the key "sessions:42" there.
+type Namespace struct {
+	c                 *cache
+	prefix            string
+	defaultExpiration time.Duration
+}
+
+// Namespace returns a view of the cache whose keys are prefixed with name.
+// Items stored through it with DefaultExpiration use the cache's default
+// expiration, unless the view was created with WithDefaultExpiration.
+func (c *cache) Namespace(name string) *Namespace {
+	return &Namespace{c: c, prefix: name + namespaceSeparator}
+}
+
+// Namespace returns a view of the keys in ns that are prefixed with name.
+func (ns *Namespace) Namespace(name string) *Namespace {
+	return &Namespace{
+		c:                 ns.c,
+		prefix:            ns.prefix + name + namespaceSeparator,
+		defaultExpiration: ns.defaultExpiration,
+	}
+}
+
+// WithDefaultExpiration returns a copy of ns that stores items set with
+// DefaultExpiration with the expiration d instead. If d is NoExpiration, they
+// never expire.
+func (ns *Namespace) WithDefaultExpiration(d time.Duration) *Namespace {
+	if d == DefaultExpiration {
+		d = NoExpiration
+	}
+	n := *ns
+	n.defaultExpiration = d
+	return &n
+}
+
+func (ns *Namespace) key(k string) string {
+	return ns.prefix + k
+}
+
+// Returns the expiration to store an item with when it was set with d.
+func (ns *Namespace) expiration(d time.Duration) time.Duration {
+	if d == DefaultExpiration && ns.defaultExpiration != 0 {
+		return ns.defaultExpiration
+	}
+	return d
+}
+
+// Add an item to the namespace, replacing any existing item. See Cache.Set.
+func (ns *Namespace) Set(k string, x interface{}, d time.Duration) {
+	ns.c.Set(ns.key(k), x, ns.expiration(d))
+}
+
+// Add an item to the namespace only if it doesn't already exist, or if the
+// existing item has expired. Returns an error otherwise.
+func (ns *Namespace) Add(k string, x interface{}, d time.Duration) error {
+	return ns.c.Add(ns.key(k), x, ns.expiration(d))
+}
+
+// Set a new value for the key only if it already exists in the namespace, and
+// the existing item hasn't expired. Returns an error otherwise.
+func (ns *Namespace) Replace(k string, x interface{}, d time.Duration) error {
+	return ns.c.Replace(ns.key(k), x, ns.expiration(d))
+}
+
+// Get an item from the namespace. Returns the item or nil, and a bool
+// indicating whether the key was found.
+func (ns *Namespace) Get(k string) (interface{}, bool) {
+	return ns.c.Get(ns.key(k))
+}
+
+// GetOrLoad an item from the namespace, loading and storing it with load() if
+// it isn't present. load is called with the key without the namespace's
+// prefix. See Cache.GetOrLoad.
+func (ns *Namespace) GetOrLoad(k string, load func(k string) (interface{}, time.Duration, error)) (interface{}, error) {
+	return ns.c.GetOrLoad(ns.key(k), func(interface{}) (interface{}, time.Duration, error) {
+		x, d, err := load(k)
+		return x, ns.expiration(d), err
+	})
+}
+
+// Delete an item from the namespace. Does nothing if the key is not in it.
+func (ns *Namespace) Delete(k string) {
+	ns.c.Delete(ns.key(k))
+}
+
+// Keys returns the sorted keys of the unexpired items in the namespace,
+// without the namespace's prefix.
+func (ns *Namespace) Keys() []string {
+	keys := ns.c.KeysWithPrefix(ns.prefix)
+	for i, k := range keys {
+		keys[i] = k[len(ns.prefix):]
+	}
+	return keys
+}
+
+// Returns the number of unexpired items in the namespace.
+func (ns *Namespace) ItemCount() int {
+	return len(ns.c.KeysWithPrefix(ns.prefix))
+}
+
+// Delete all items in the namespace, calling OnEvicted for them. Items in the
+// rest of the cache are kept.
+func (ns *Namespace) Flush() {
+	ns.c.DeleteByPrefix(ns.prefix)
+}
@@ -0,0 +1,53 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNamespace(t *testing.T) {
+	tc := New(DefaultExpiration, 0)
+	sessions := tc.Namespace("sessions").WithDefaultExpiration(time.Millisecond)
+	users := tc.Namespace("users")
+
+	sessions.Set("1", "s", DefaultExpiration)
+	users.Set("1", "u", DefaultExpiration)
+	users.Namespace("admins").Set("2", "a", DefaultExpiration)
+
+	if x, found := tc.Get("sessions:1"); !found || x != "s" {
+		t.Errorf("sessions:1 is %v, %v", x, found)
+	}
+	if x, found := users.Get("1"); !found || x != "u" {
+		t.Errorf("users 1 is %v, %v", x, found)
+	}
+	if x, found := tc.Get("users:admins:2"); !found || x != "a" {
+		t.Errorf("users:admins:2 is %v, %v", x, found)
+	}
+	if keys := users.Keys(); len(keys) != 2 || keys[0] != "1" || keys[1] != "admins:2" {
+		t.Error("users.Keys returned", keys)
+	}
+
+	<-time.After(5 * time.Millisecond)
+	if _, found := sessions.Get("1"); found {
+		t.Error("session didn't expire with the namespace's default expiration")
+	}
+	if _, found := users.Get("1"); !found {
+		t.Error("user expired with the session namespace's default expiration")
+	}
+
+	x, err := users.GetOrLoad("3", func(k string) (interface{}, time.Duration, error) {
+		return "loaded " + k, DefaultExpiration, nil
+	})
+	if err != nil || x != "loaded 3" {
+		t.Errorf("GetOrLoad returned %v, %v", x, err)
+	}
+
+	tc.Set("other", 1, DefaultExpiration)
+	users.Flush()
+	if n := users.ItemCount(); n != 0 {
+		t.Errorf("users has %d items after Flush", n)
+	}
+	if _, found := tc.Get("other"); !found {
+		t.Error("Flush of a namespace deleted an item outside of it")
+	}
+}
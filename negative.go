@@ -0,0 +1,58 @@
+package cache
+
+import "time"
+
+// WithNegativeCacheTTL makes GetOrLoad and the other loading methods remember
+// a loader's error for ttl: until then, loading the same key returns the same
+// error again without calling the loader, so that repeated lookups of a key
+// the backing store doesn't have, or can't serve, don't all reach it. Storing
+// or deleting the key, or flushing the cache, forgets the error. Errors from a
+// canceled or timed out context, such as the caller's, are not remembered.
+// Remembered errors don't count as items and are deleted along with expired
+// items.
+func WithNegativeCacheTTL(ttl time.Duration) Option {
+	return func(c *cache) {
+		c.negativeTTL = ttl
+	}
+}
+
+// A loader error remembered until expiration.
+type failedLoad struct {
+	err        error
+	expiration int64
+}
+
+// Returns the remembered error of the last load of k, if it hasn't expired.
+// Must be called with c locked.
+func (c *cache) failedLoad(k interface{}) (error, bool) {
+	f, found := c.failures[k]
+	if !found {
+		return nil, false
+	}
+	if c.now().UnixNano() > f.expiration {
+		delete(c.failures, k)
+		return nil, false
+	}
+	return f.err, true
+}
+
+// Remember that loading k failed with err. Must be called with c locked.
+func (c *cache) recordFailure(k interface{}, err error) {
+	if c.negativeTTL <= 0 {
+		return
+	}
+	if c.failures == nil {
+		c.failures = map[interface{}]failedLoad{}
+	}
+	c.failures[k] = failedLoad{err, c.now().Add(c.negativeTTL).UnixNano()}
+}
+
+// Delete the remembered errors that have expired at now. Must be called with
+// c locked.
+func (c *cache) deleteExpiredFailures(now int64) {
+	for k, f := range c.failures {
+		if now > f.expiration {
+			delete(c.failures, k)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNegativeCacheTTL(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	tc := New(DefaultExpiration, 0, WithShards(1), WithClock(clock), WithNegativeCacheTTL(5*time.Second))
+	errMissing := errors.New("missing")
+	loads := 0
+	load := func(k interface{}) (interface{}, time.Duration, error) {
+		loads++
+		return nil, 0, errMissing
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := tc.GetOrLoad("a", load); err != errMissing {
+			t.Fatalf("GetOrLoad returned %v, want %v", err, errMissing)
+		}
+	}
+	if loads != 1 {
+		t.Errorf("Loaded %d times within the negative TTL, want once", loads)
+	}
+	if n := tc.ItemCount(); n != 0 {
+		t.Errorf("Item count is %d, want 0", n)
+	}
+
+	clock.Advance(6 * time.Second)
+	tc.GetOrLoad("a", load)
+	if loads != 2 {
+		t.Errorf("Loaded %d times after the negative TTL, want twice", loads)
+	}
+
+	tc.Set("a", 1, DefaultExpiration)
+	tc.Delete("a")
+	tc.GetOrLoad("a", load)
+	if loads != 3 {
+		t.Errorf("Loaded %d times after storing the key, want 3", loads)
+	}
+
+	clock.Advance(6 * time.Second)
+	tc.DeleteExpired()
+	if n := len(tc.failures); n != 0 {
+		t.Errorf("%d expired errors remain after DeleteExpired", n)
+	}
+}
+
+func TestNegativeCacheTTLDisabled(t *testing.T) {
+	tc := New(DefaultExpiration, 0)
+	loads := 0
+	for i := 0; i < 3; i++ {
+		tc.GetOrLoad("a", func(k interface{}) (interface{}, time.Duration, error) {
+			loads++
+			return nil, 0, errors.New("missing")
+		})
+	}
+	if loads != 3 {
+		t.Errorf("Loaded %d times without negative caching, want 3", loads)
+	}
+}
+
+func TestNegativeCacheTTLContextError(t *testing.T) {
+	tc := New(DefaultExpiration, 0, WithShards(1), WithNegativeCacheTTL(time.Minute))
+	started := make(chan struct{})
+	load := func(ctx context.Context, k interface{}) (interface{}, time.Duration, error) {
+		if ctx.Value(started) != nil {
+			close(started)
+			<-ctx.Done()
+			return nil, 0, ctx.Err()
+		}
+		return "loaded", DefaultExpiration, nil
+	}
+
+	// The first caller gives up while another waits for its load.
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), started, true))
+	errc := make(chan error)
+	go func() {
+		_, err := tc.GetOrLoadContext(ctx, "a", load)
+		errc <- err
+	}()
+	<-started
+	waiter := make(chan interface{})
+	go func() {
+		x, err := tc.GetOrLoadContext(context.Background(), "a", load)
+		if err != nil {
+			t.Error(err)
+		}
+		waiter <- x
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	if err := <-errc; !errors.Is(err, context.Canceled) {
+		t.Errorf("Canceled caller got %v", err)
+	}
+	if x := <-waiter; x != "loaded" {
+		t.Errorf("Waiting caller got %v, want its own load", x)
+	}
+	if n := len(tc.failures); n != 0 {
+		t.Errorf("%d context errors were remembered", n)
+	}
+}
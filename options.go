@@ -0,0 +1,78 @@
+package cache
+
+import "time"
+
+// An Option configures optional behavior of a cache when it is created with
+// NewWithOptions, New or NewFrom.
+type Option func(*cache)
+
+// WithDefaultExpiration sets the expiration of items stored with
+// DefaultExpiration. If d is less than one (or NoExpiration), they never
+// expire. It overrides the default expiration passed to New.
+func WithDefaultExpiration(d time.Duration) Option {
+	return func(c *cache) {
+		if d < 1 {
+			d = NoExpiration
+		}
+		c.defaultExpiration = d
+	}
+}
+
+// WithCleanupInterval makes the janitor delete expired items every interval.
+// If interval is less than one, there is no janitor. It overrides the cleanup
+// interval passed to New.
+func WithCleanupInterval(interval time.Duration) Option {
+	return func(c *cache) {
+		c.cleanupInterval = interval
+	}
+}
+
+// WithOnEvicted sets the function called when an item is evicted, as with
+// OnEvicted.
+func WithOnEvicted(f func(interface{}, interface{})) Option {
+	return func(c *cache) {
+		c.onEvicted = f
+	}
+}
+
+// WithKeyFunc converts every key to a canonical string with f before it is
+// used, so keys that are equal by value (e.g. structs containing pointers or
+// slices rendered by f) resolve to the same item. Keys passed to OnEvicted and
+// reported in events are the converted strings.
+func WithKeyFunc(f func(interface{}) string) Option {
+	return func(c *cache) {
+		c.keyFunc = f
+	}
+}
+
+// WithClampLoadedExpiration makes GetAndExtendOrLoad store an item whose loader
+// returned NoExpiration with the duration passed to GetAndExtendOrLoad instead,
+// so that loaded items can't outlive the extension the caller asked for. By
+// default the loader's NoExpiration is honored.
+func WithClampLoadedExpiration() Option {
+	return func(c *cache) {
+		c.clampLoadedExpiration = true
+	}
+}
+
+// WithJanitorValidator makes every janitor run also delete the unexpired items
+// for which valid returns false, calling OnEvicted for them. valid is called
+// without holding the cache's lock, so it may be slow or access the cache; an
+// item that is changed while it is being validated is kept. It has no effect
+// if the cache has no cleanup interval.
+func WithJanitorValidator(valid func(key, value interface{}) bool) Option {
+	return func(c *cache) {
+		c.validator = valid
+	}
+}
+
+// WithJanitorBatchSize makes the janitor delete expired items n at a time,
+// releasing the cache's lock between batches, so that lookups and writes
+// aren't stalled while it cleans up a large cache. The expired items are found
+// holding only the read lock. By default, and if n is less than one, the
+// janitor holds the lock for the whole of its run, like DeleteExpired.
+func WithJanitorBatchSize(n int) Option {
+	return func(c *cache) {
+		c.janitorBatchSize = n
+	}
+}
@@ -0,0 +1,121 @@
+package cache
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNewWithOptions(t *testing.T) {
+	var mu sync.Mutex
+	var evicted []interface{}
+	tc := NewWithOptions(
+		WithDefaultExpiration(time.Millisecond),
+		WithCleanupInterval(2*time.Millisecond),
+		WithMaxEntries(2),
+		WithOnEvicted(func(k, v interface{}) {
+			mu.Lock()
+			evicted = append(evicted, k)
+			mu.Unlock()
+		}),
+	)
+	defer tc.Close()
+
+	tc.Set("a", 1, DefaultExpiration)
+	tc.Set("b", 2, NoExpiration)
+	<-time.After(20 * time.Millisecond)
+	if n := tc.ItemCount(); n != 1 {
+		t.Errorf("ItemCount is %d, want 1: the janitor didn't delete a", n)
+	}
+	tc.Set("c", 3, NoExpiration)
+	tc.Set("d", 4, NoExpiration)
+
+	mu.Lock()
+	got := append([]interface{}(nil), evicted...)
+	mu.Unlock()
+	if len(got) != 2 || got[0] != "a" || got[1] != "b" {
+		t.Error("OnEvicted was called for", got)
+	}
+}
+
+func TestNewWithoutOptions(t *testing.T) {
+	tc := NewWithOptions()
+	if tc.janitor != nil {
+		t.Error("a janitor was started without WithCleanupInterval")
+	}
+	if d := tc.EffectiveDefaultExpiration(); d != NoExpiration {
+		t.Errorf("default expiration is %v, want NoExpiration", d)
+	}
+}
+
+func TestOptionsOverrideNew(t *testing.T) {
+	tc := New(time.Hour, 0, WithDefaultExpiration(time.Minute))
+	if d := tc.EffectiveDefaultExpiration(); d != time.Minute {
+		t.Errorf("default expiration is %v, want 1m", d)
+	}
+}
+
+func TestWithJanitorBatchSize(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	tc := New(time.Minute, 0, WithShards(1), WithClock(clock), WithJanitorBatchSize(3))
+	for i := 0; i < 10; i++ {
+		tc.Set(i, i, DefaultExpiration)
+	}
+	for i := 10; i < 15; i++ {
+		tc.Set(i, i, NoExpiration)
+	}
+	clock.Advance(2 * time.Minute)
+
+	// Callbacks run between batches, so the items renewed by the first
+	// one must be kept by the later batches.
+	evicted := 0
+	tc.OnEvicted(func(k interface{}, v interface{}) {
+		evicted++
+		if evicted == 1 {
+			for i := 0; i < 10; i++ {
+				tc.Set(i, i, NoExpiration)
+			}
+		}
+	})
+	tc.janitorDeleteExpired()
+	if evicted != 3 {
+		t.Errorf("Evicted %d items, want the 3 of the first batch", evicted)
+	}
+	if n := tc.ItemCount(); n != 15 {
+		t.Errorf("Item count is %d, want 15", n)
+	}
+}
+
+type compositeKey struct {
+	Tenant *string
+	Path   []string
+}
+
+func TestKeyFunc(t *testing.T) {
+	keyFunc := func(k interface{}) string {
+		ck := k.(compositeKey)
+		return *ck.Tenant + "/" + strings.Join(ck.Path, "/")
+	}
+	tc := New(DefaultExpiration, 0, WithKeyFunc(keyFunc))
+
+	tenantA, tenantB := "acme", "acme"
+	a := compositeKey{Tenant: &tenantA, Path: []string{"users", "1"}}
+	b := compositeKey{Tenant: &tenantB, Path: []string{"users", "1"}}
+
+	tc.Set(a, "alice", DefaultExpiration)
+	x, found := tc.Get(b)
+	if !found {
+		t.Fatal("Did not find item using an equal key")
+	}
+	if x.(string) != "alice" {
+		t.Errorf("x is %v, want alice", x)
+	}
+	if err := tc.Add(b, "bob", DefaultExpiration); err == nil {
+		t.Error("Added an item under a key equal to an existing one")
+	}
+	tc.Delete(b)
+	if _, found := tc.Get(a); found {
+		t.Error("Found item after deleting it using an equal key")
+	}
+}
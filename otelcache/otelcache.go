@@ -0,0 +1,132 @@
+// Package otelcache instruments cache loads with OpenTelemetry: every call made
+// through an Instrumentation is traced, and the latency and errors of loader
+// calls are recorded as metrics.
+//
+//	inst, err := otelcache.New("users")
+//	...
+//	u, err := inst.GetOrLoad(ctx, c, id, loadUser)
+//
+// It lives in its own module so that the cache package doesn't depend on
+// OpenTelemetry.
+package otelcache
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/rumsrami/cache/otelcache"
+
+// A Loader is a cache that can load missing items with a context, such as a
+// *cache.Cache.
+type Loader interface {
+	GetOrLoadContext(ctx context.Context, k interface{}, load func(ctx context.Context, k interface{}) (interface{}, time.Duration, error)) (interface{}, error)
+}
+
+// An Option configures an Instrumentation.
+type Option func(*config)
+
+type config struct {
+	tp trace.TracerProvider
+	mp metric.MeterProvider
+}
+
+// WithTracerProvider sets the TracerProvider used to create spans. The global
+// provider is used by default.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(c *config) {
+		c.tp = tp
+	}
+}
+
+// WithMeterProvider sets the MeterProvider used to create instruments. The
+// global provider is used by default.
+func WithMeterProvider(mp metric.MeterProvider) Option {
+	return func(c *config) {
+		c.mp = mp
+	}
+}
+
+// Instrumentation traces and measures the loads of one cache.
+type Instrumentation struct {
+	name     string
+	attrs    metric.MeasurementOption
+	tracer   trace.Tracer
+	duration metric.Float64Histogram
+	errors   metric.Int64Counter
+}
+
+// New returns an Instrumentation for the cache with the given name, which is
+// recorded as the cache.name attribute of all spans and measurements.
+func New(name string, opts ...Option) (*Instrumentation, error) {
+	cfg := config{
+		tp: otel.GetTracerProvider(),
+		mp: otel.GetMeterProvider(),
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	meter := cfg.mp.Meter(instrumentationName)
+	duration, err := meter.Float64Histogram("cache.load.duration",
+		metric.WithDescription("Time spent in loader calls."),
+		metric.WithUnit("s"))
+	if err != nil {
+		return nil, err
+	}
+	errors, err := meter.Int64Counter("cache.load.errors",
+		metric.WithDescription("Loader calls that returned an error."))
+	if err != nil {
+		return nil, err
+	}
+	return &Instrumentation{
+		name:     name,
+		attrs:    metric.WithAttributes(attribute.String("cache.name", name)),
+		tracer:   cfg.tp.Tracer(instrumentationName),
+		duration: duration,
+		errors:   errors,
+	}, nil
+}
+
+// GetOrLoad calls c.GetOrLoadContext within a "cache.GetOrLoad" span with the
+// cache.name and cache.key attributes, and a cache.hit attribute that is false
+// if load was called. A call to load gets a child "cache.load" span, its
+// duration is recorded in the cache.load.duration histogram, and an error it
+// returns is recorded on the span and counted in cache.load.errors. Callers
+// that wait for another goroutine's load don't call load, and so count as hits.
+func (i *Instrumentation) GetOrLoad(ctx context.Context, c Loader, k interface{}, load func(ctx context.Context, k interface{}) (interface{}, time.Duration, error)) (interface{}, error) {
+	ctx, span := i.tracer.Start(ctx, "cache.GetOrLoad", trace.WithAttributes(
+		attribute.String("cache.name", i.name),
+		attribute.String("cache.key", fmt.Sprint(k)),
+	))
+	defer span.End()
+
+	loaded := false
+	x, err := c.GetOrLoadContext(ctx, k, func(ctx context.Context, k interface{}) (interface{}, time.Duration, error) {
+		loaded = true
+		ctx, span := i.tracer.Start(ctx, "cache.load")
+		defer span.End()
+
+		start := time.Now()
+		x, d, err := load(ctx, k)
+		i.duration.Record(ctx, time.Since(start).Seconds(), i.attrs)
+		if err != nil {
+			i.errors.Add(ctx, 1, i.attrs)
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		return x, d, err
+	})
+	span.SetAttributes(attribute.Bool("cache.hit", !loaded))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return x, err
+}
@@ -0,0 +1,85 @@
+package otelcache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rumsrami/cache"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestGetOrLoad(t *testing.T) {
+	spans := tracetest.NewSpanRecorder()
+	reader := sdkmetric.NewManualReader()
+	inst, err := New("test",
+		WithTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))),
+		WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := cache.New(cache.DefaultExpiration, 0)
+	ctx := context.Background()
+	load := func(ctx context.Context, k interface{}) (interface{}, time.Duration, error) {
+		return "value", cache.DefaultExpiration, nil
+	}
+	fail := func(ctx context.Context, k interface{}) (interface{}, time.Duration, error) {
+		return nil, 0, errors.New("failed")
+	}
+	if x, err := inst.GetOrLoad(ctx, c, "a", load); err != nil || x != "value" {
+		t.Errorf("got %v, %v", x, err)
+	}
+	inst.GetOrLoad(ctx, c, "a", load)
+	if _, err := inst.GetOrLoad(ctx, c, "b", fail); err == nil {
+		t.Error("the loader's error wasn't returned")
+	}
+
+	ended := spans.Ended()
+	var names []string
+	hits := map[bool]int{}
+	for _, s := range ended {
+		names = append(names, s.Name())
+		for _, a := range s.Attributes() {
+			if a.Key == "cache.hit" {
+				hits[a.Value.AsBool()]++
+			}
+		}
+	}
+	if len(ended) != 5 {
+		t.Fatalf("got spans %v, want 3 GetOrLoad and 2 load spans", names)
+	}
+	if hits[true] != 1 || hits[false] != 2 {
+		t.Errorf("got %d hits and %d misses, want 1 and 2", hits[true], hits[false])
+	}
+	if load := ended[3]; load.Name() != "cache.load" || load.Status().Code != codes.Error {
+		t.Errorf("failed load span is %s with status %v", load.Name(), load.Status())
+	}
+	if load := ended[3]; load.Parent().SpanID() != ended[4].SpanContext().SpanID() {
+		t.Error("load span isn't a child of the GetOrLoad span")
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(ctx, &rm); err != nil {
+		t.Fatal(err)
+	}
+	want := attribute.NewSet(attribute.String("cache.name", "test"))
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		switch data := m.Data.(type) {
+		case metricdata.Histogram[float64]:
+			if p := data.DataPoints[0]; p.Count != 2 || !p.Attributes.Equals(&want) {
+				t.Errorf("%s has %d loads with %v", m.Name, p.Count, p.Attributes)
+			}
+		case metricdata.Sum[int64]:
+			if p := data.DataPoints[0]; p.Value != 1 || !p.Attributes.Equals(&want) {
+				t.Errorf("%s is %d with %v", m.Name, p.Value, p.Attributes)
+			}
+		}
+	}
+}
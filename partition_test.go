@@ -0,0 +1,81 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+type tenantValue struct {
+	Tenant string
+	Data   int
+}
+
+func TestPartition(t *testing.T) {
+	tc := New(DefaultExpiration, 0, WithShards(1))
+	tc.Set("a1", tenantValue{"a", 1}, time.Hour)
+	tc.Set("a2", tenantValue{"a", 2}, NoExpiration)
+	tc.Set("a3", tenantValue{"a", 3}, time.Millisecond)
+	tc.Set("b1", tenantValue{"b", 1}, time.Hour)
+	<-time.After(5 * time.Millisecond)
+
+	pc := tc.Partition(func(k, v interface{}) bool {
+		return v.(tenantValue).Tenant == "a"
+	})
+	if n := pc.ItemCount(); n != 2 {
+		t.Errorf("Partition holds %d items, want 2", n)
+	}
+	for _, k := range []string{"a1", "a2"} {
+		if pc.items[k] != tc.items[k] {
+			t.Errorf("%s is %v in the partition, want %v", k, pc.items[k], tc.items[k])
+		}
+	}
+	if _, found := pc.Get("b1"); found {
+		t.Error("Found b1 in the partition")
+	}
+	if n := tc.ItemCount(); n != 4 {
+		t.Errorf("Original cache holds %d items, want 4", n)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	mine := New(DefaultExpiration, 0, WithShards(1))
+	theirs := New(DefaultExpiration, 0, WithShards(1))
+	mine.Set("both-keep", 1, NoExpiration)
+	mine.Set("both-newer", 1, time.Hour)
+	mine.Set("mine", 1, NoExpiration)
+	theirs.Set("both-keep", 2, NoExpiration)
+	theirs.Set("both-newer", 2, 2*time.Hour)
+	theirs.Set("theirs", 2, time.Hour)
+	theirs.Set("expired", 2, time.Millisecond)
+	<-time.After(5 * time.Millisecond)
+
+	var resolved []interface{}
+	n := mine.Merge(theirs, func(k, m, th interface{}, mExp, thExp time.Time) (interface{}, time.Time) {
+		resolved = append(resolved, k)
+		if thExp.After(mExp) && !mExp.IsZero() {
+			return th, thExp
+		}
+		return m, mExp
+	})
+	if n != 2 {
+		t.Errorf("Merge changed %d items, want 2", n)
+	}
+	if len(resolved) != 2 {
+		t.Errorf("Resolver was called for %v, want only the shared keys", resolved)
+	}
+	want := map[string]int{"both-keep": 1, "both-newer": 2, "mine": 1, "theirs": 2}
+	for k, v := range want {
+		if x, found := mine.Get(k); !found || x.(int) != v {
+			t.Errorf("%s is %v, %v; want %d", k, x, found, v)
+		}
+	}
+	if _, found := mine.Get("expired"); found {
+		t.Error("Merged an expired item")
+	}
+	if mine.items["theirs"].Expiration != theirs.items["theirs"].Expiration {
+		t.Error("Copied item did not keep its expiration")
+	}
+	if mine.items["both-newer"].Expiration != theirs.items["both-newer"].Expiration {
+		t.Error("Resolved item did not get the resolved expiration")
+	}
+}
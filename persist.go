@@ -0,0 +1,110 @@
+package cache
+
+import (
+	"io"
+	"os"
+)
+
+// Returns a copy of all unexpired items. Must be called with c locked.
+func (c *cache) liveItems() map[interface{}]Item {
+	m := make(map[interface{}]Item, len(c.items))
+	for k, v := range c.items {
+		if c.expired(v) {
+			continue
+		}
+		m[k] = v
+	}
+	return m
+}
+
+// Write the cache's unexpired items to an io.Writer, using Gob unless another
+// codec was set with WithCodec. Expirations are saved as they are, so an item
+// loaded later expires at the same time it would have in this cache.
+//
+// With the default codec, the types of all keys and values are registered with
+// gob.Register. Types that can't be registered, or encoded, cause an error to
+// be returned.
+func (c *cache) Save(w io.Writer) error {
+	return c.itemCodec().Encode(w, c.Items())
+}
+
+// Save the cache's items to the given filename, creating the file if it
+// doesn't exist, and overwriting it if it does.
+func (c *cache) SaveFile(fname string) error {
+	return saveFile(fname, c.Save)
+}
+
+// Create the file fname and write to it with save.
+func saveFile(fname string, save func(io.Writer) error) error {
+	fp, err := os.Create(fname)
+	if err != nil {
+		return err
+	}
+	err = save(fp)
+	if err != nil {
+		fp.Close()
+		return err
+	}
+	return fp.Close()
+}
+
+// Add cache items saved with Save from an io.Reader, excluding any items with
+// keys that already exist (and haven't expired) in the current cache, and any
+// items that have expired since they were saved.
+//
+// With the default codec, the types of the keys and values must have been
+// registered with gob.Register before calling Load, unless they are builtin
+// types.
+func (c *cache) Load(r io.Reader) error {
+	return c.load(r, c.itemCodec())
+}
+
+func (c *cache) load(r io.Reader, codec Codec) error {
+	items, err := codec.Decode(r)
+	if err != nil {
+		return err
+	}
+	c.loadItems(items)
+	return nil
+}
+
+// Add the unexpired items whose keys aren't in the cache.
+func (c *cache) loadItems(items map[interface{}]Item) {
+	if c.shards != nil {
+		for s, items := range c.itemsByShard(items) {
+			s.loadItems(items)
+		}
+		return
+	}
+	c.Lock()
+	defer c.unlock()
+	for k, v := range items {
+		if c.expired(v) {
+			continue
+		}
+		if _, found := c.get(k); found {
+			continue
+		}
+		c.put(k, v)
+	}
+}
+
+// Load and add cache items from the given filename, excluding any items with
+// keys that already exist in the current cache.
+func (c *cache) LoadFile(fname string) error {
+	return loadFile(fname, c.Load)
+}
+
+// Open the file fname and read it with load.
+func loadFile(fname string, load func(io.Reader) error) error {
+	fp, err := os.Open(fname)
+	if err != nil {
+		return err
+	}
+	err = load(fp)
+	if err != nil {
+		fp.Close()
+		return err
+	}
+	return fp.Close()
+}
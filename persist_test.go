@@ -0,0 +1,67 @@
+package cache
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type persistedValue struct {
+	Name string
+	N    int
+}
+
+func TestSaveLoad(t *testing.T) {
+	tc := New(DefaultExpiration, 0)
+	tc.Set("a", "a", DefaultExpiration)
+	tc.Set(2, persistedValue{"b", 2}, time.Hour)
+	tc.Set("c", "c", time.Millisecond)
+	<-time.After(5 * time.Millisecond)
+
+	buf := &bytes.Buffer{}
+	if err := tc.Save(buf); err != nil {
+		t.Fatal("Couldn't save cache:", err)
+	}
+
+	oc := New(DefaultExpiration, 0)
+	oc.Set("a", "aa", DefaultExpiration)
+	if err := oc.Load(buf); err != nil {
+		t.Fatal("Couldn't load cache:", err)
+	}
+
+	if x, _ := oc.Get("a"); x != "aa" {
+		t.Error("a was overwritten by Load:", x)
+	}
+	got, found := oc.Inspect(2)
+	if !found || got.Value != (persistedValue{"b", 2}) {
+		t.Error("2 wasn't loaded:", got.Value)
+	}
+	want, _ := tc.Inspect(2)
+	if !got.Expiration.Equal(want.Expiration) {
+		t.Errorf("2 expires at %v, want %v", got.Expiration, want.Expiration)
+	}
+	if _, found := oc.Get("c"); found {
+		t.Error("expired item c was saved")
+	}
+	if n := oc.ItemCount(); n != 2 {
+		t.Errorf("ItemCount is %d, want 2", n)
+	}
+}
+
+func TestSaveLoadFile(t *testing.T) {
+	fname := filepath.Join(t.TempDir(), "cache.gob")
+	tc := New(DefaultExpiration, 0)
+	tc.Set("a", 1, DefaultExpiration)
+	if err := tc.SaveFile(fname); err != nil {
+		t.Fatal("Couldn't save cache to file:", err)
+	}
+
+	oc := New(DefaultExpiration, 0)
+	if err := oc.LoadFile(fname); err != nil {
+		t.Fatal("Couldn't load cache from file:", err)
+	}
+	if x, found := oc.Get("a"); !found || x != 1 {
+		t.Error("a wasn't loaded from file:", x)
+	}
+}
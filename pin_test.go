@@ -0,0 +1,131 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPin(t *testing.T) {
+	tc := New(20*time.Millisecond, 0)
+	tc.Set("config", "v1", DefaultExpiration)
+	tc.Set("other", "v1", DefaultExpiration)
+	if !tc.Pin("config") {
+		t.Error("Could not pin config")
+	}
+	if tc.Pin("missing") {
+		t.Error("Pinned a missing item")
+	}
+
+	<-time.After(30 * time.Millisecond)
+	if _, found := tc.Get("config"); !found {
+		t.Error("Pinned item expired")
+	}
+	if _, found := tc.Get("other"); found {
+		t.Error("Found other after it expired")
+	}
+
+	if !tc.Unpin("config", DefaultExpiration) {
+		t.Error("Could not unpin config")
+	}
+	<-time.After(30 * time.Millisecond)
+	if _, found := tc.Get("config"); found {
+		t.Error("Found config after it was unpinned and expired")
+	}
+	if tc.Unpin("config", DefaultExpiration) {
+		t.Error("Unpinned an expired item")
+	}
+}
+
+func TestPinExemptsFromEviction(t *testing.T) {
+	for name, opts := range map[string][]Option{
+		"LRU": nil,
+		"LFU": {WithLFUEviction()},
+		"ARC": {WithARCEviction()},
+	} {
+		tc := New(DefaultExpiration, 0, append(opts, WithMaxEntries(2))...)
+		tc.Set("config", 1, time.Hour)
+		tc.Pin("config")
+		for i := 0; i < 5; i++ {
+			tc.Set(i, i, DefaultExpiration)
+		}
+		if _, found := tc.Get("config"); !found {
+			t.Errorf("%s: pinned item was evicted", name)
+		}
+		if x, found := tc.Get(4); !found || x != 4 {
+			t.Errorf("%s: got %v, %t for 4", name, x, found)
+		}
+		if n := tc.EvictLRU(5); n != 1 {
+			t.Errorf("%s: EvictLRU evicted %d items, want 1", name, n)
+		}
+		if err := tc.ConsistencyCheck(); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+
+		// Once unpinned, the item can be evicted again.
+		tc.Unpin("config", DefaultExpiration)
+		tc.Set("a", 1, DefaultExpiration)
+		tc.Set("b", 2, DefaultExpiration)
+		if _, found := tc.Get("config"); found {
+			t.Errorf("%s: unpinned item was not evicted", name)
+		}
+		if err := tc.ConsistencyCheck(); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
+
+func TestPinnedItemsFillCache(t *testing.T) {
+	tc := New(DefaultExpiration, 0, WithMaxEntries(2), WithMaxCost(10))
+	tc.SetWithCost("a", 1, 6, DefaultExpiration)
+	tc.Pin("a")
+	tc.SetWithCost("b", 2, 3, DefaultExpiration)
+	tc.Pin("b")
+	// The pinned items take up the room, so only one other item fits.
+	tc.Set("c", 3, DefaultExpiration)
+	tc.Set("d", 4, DefaultExpiration)
+	if n := tc.ItemCount(); n != 3 {
+		t.Errorf("ItemCount is %d, want 3", n)
+	}
+	if _, found := tc.Get("d"); !found {
+		t.Error("d was not stored")
+	}
+	if err := tc.ConsistencyCheck(); err != nil {
+		t.Error(err)
+	}
+	// A pinned item may exceed the cost limit.
+	tc.SetWithCost("a", 1, 20, DefaultExpiration)
+	if _, found := tc.Get("a"); !found {
+		t.Error("Pinned item over the cost limit was evicted")
+	}
+	if n := tc.ItemCount(); n != 2 {
+		t.Errorf("ItemCount is %d, want 2", n)
+	}
+	if err := tc.ConsistencyCheck(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPinnedItemNeverExpires(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	tc := New(time.Minute, 0, WithClock(clock))
+	tc.Set("config", 1, DefaultExpiration)
+	tc.Pin("config")
+	tc.Set("config", 2, time.Second)
+	tc.Touch("config", time.Second)
+	clock.Advance(time.Hour)
+	tc.DeleteExpired()
+	if x, found := tc.Get("config"); !found || x != 2 {
+		t.Errorf("Got %v, %t for pinned item", x, found)
+	}
+
+	// Deleting the item unpins it.
+	tc.Delete("config")
+	tc.Set("config", 3, time.Second)
+	clock.Advance(time.Minute)
+	if _, found := tc.Get("config"); found {
+		t.Error("Item stored after its pinned one was deleted didn't expire")
+	}
+	if err := tc.ConsistencyCheck(); err != nil {
+		t.Error(err)
+	}
+}
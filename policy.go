@@ -0,0 +1,82 @@
+package cache
+
+import "container/list"
+
+// An EvictionPolicy picks the items to evict when the cache is full. The cache
+// tells it about every key it stores, looks up and removes, and calls it under
+// a mutex, so it doesn't need to lock. It must not call the cache. A Victim
+// that isn't in the cache is ignored, and another item is evicted instead.
+type EvictionPolicy interface {
+	// A value was stored under k, which may already hold one.
+	RecordInsert(k interface{})
+	// A lookup returned the item under k.
+	RecordAccess(k interface{})
+	// k left the cache.
+	Remove(k interface{})
+	// Returns the key to evict next, and false if there is none. The key
+	// stays tracked until Remove is called for it.
+	Victim() (interface{}, bool)
+}
+
+// WithEvictionPolicy makes the cache evict the items picked by a policy made
+// by newPolicy when it is full, instead of the least recently used ones.
+// newPolicy is called with the limit set with WithMaxEntries, or zero if there
+// is none, once for each cache that needs a policy: each cache returned by
+// Partition gets its own. A cache with a policy isn't split into shards (see
+// WithShards). The policy only sees keys after the key function set with
+// WithKeyFunc is applied. See NewLRUPolicy and NewLFUPolicy for the policies
+// shipped with the package, and WithMaxEntries and WithMaxCost.
+func WithEvictionPolicy(newPolicy func(maxEntries int) EvictionPolicy) Option {
+	return func(c *cache) {
+		c.newPolicy = newPolicy
+	}
+}
+
+// NewLRUPolicy returns a policy evicting the least recently used item: the one
+// whose value was stored or returned by a lookup longest ago. It picks the same
+// items as a cache without a policy, and can serve as the base of other
+// policies.
+func NewLRUPolicy() EvictionPolicy {
+	return newLRUPolicy()
+}
+
+// Keys in the order they were last used, most recent first.
+type lruPolicy struct {
+	keys    *list.List
+	entries map[interface{}]*list.Element
+}
+
+func newLRUPolicy() *lruPolicy {
+	return &lruPolicy{
+		keys:    list.New(),
+		entries: map[interface{}]*list.Element{},
+	}
+}
+
+func (p *lruPolicy) RecordInsert(k interface{}) {
+	if e, found := p.entries[k]; found {
+		p.keys.MoveToFront(e)
+		return
+	}
+	p.entries[k] = p.keys.PushFront(k)
+}
+
+func (p *lruPolicy) RecordAccess(k interface{}) {
+	if e, found := p.entries[k]; found {
+		p.keys.MoveToFront(e)
+	}
+}
+
+func (p *lruPolicy) Remove(k interface{}) {
+	if e, found := p.entries[k]; found {
+		p.keys.Remove(e)
+		delete(p.entries, k)
+	}
+}
+
+func (p *lruPolicy) Victim() (interface{}, bool) {
+	if back := p.keys.Back(); back != nil {
+		return back.Value, true
+	}
+	return nil, false
+}
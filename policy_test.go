@@ -0,0 +1,112 @@
+package cache
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestLRUPolicy(t *testing.T) {
+	p := newLRUPolicy()
+	if _, found := p.Victim(); found {
+		t.Error("Empty policy returned a victim")
+	}
+	for _, k := range []string{"a", "b", "c"} {
+		p.RecordInsert(k)
+	}
+	p.RecordAccess("a")
+	p.RecordInsert("b")
+	p.RecordAccess("missing")
+	for _, want := range []string{"c", "a", "b"} {
+		k, found := p.Victim()
+		if !found || k != want {
+			t.Fatalf("Victim is %v, want %s", k, want)
+		}
+		p.Remove(k)
+	}
+	if len(p.entries) != 0 || p.keys.Len() != 0 {
+		t.Errorf("Policy still tracks %d keys", len(p.entries))
+	}
+}
+
+// Evicts keys starting with "bulk:" before the others, least recently used
+// first.
+type bulkFirstPolicy struct {
+	bulk, other EvictionPolicy
+}
+
+func (p *bulkFirstPolicy) part(k interface{}) EvictionPolicy {
+	if s, ok := k.(string); ok && strings.HasPrefix(s, "bulk:") {
+		return p.bulk
+	}
+	return p.other
+}
+
+func (p *bulkFirstPolicy) RecordInsert(k interface{}) { p.part(k).RecordInsert(k) }
+func (p *bulkFirstPolicy) RecordAccess(k interface{}) { p.part(k).RecordAccess(k) }
+func (p *bulkFirstPolicy) Remove(k interface{})       { p.part(k).Remove(k) }
+
+func (p *bulkFirstPolicy) Victim() (interface{}, bool) {
+	if k, found := p.bulk.Victim(); found {
+		return k, true
+	}
+	return p.other.Victim()
+}
+
+func TestWithEvictionPolicy(t *testing.T) {
+	var mu sync.Mutex
+	var sizes []int
+	newPolicy := func(maxEntries int) EvictionPolicy {
+		mu.Lock()
+		sizes = append(sizes, maxEntries)
+		mu.Unlock()
+		return &bulkFirstPolicy{NewLRUPolicy(), NewLRUPolicy()}
+	}
+	tc := New(DefaultExpiration, 0, WithMaxEntries(3), WithEvictionPolicy(newPolicy))
+	tc.Set("config", 1, DefaultExpiration)
+	tc.Set("bulk:1", 2, DefaultExpiration)
+	tc.Set("bulk:2", 3, DefaultExpiration)
+	tc.Get("bulk:1")
+	tc.Set("bulk:3", 4, DefaultExpiration)
+	tc.Set("bulk:4", 5, DefaultExpiration)
+	for k, want := range map[string]bool{"config": true, "bulk:1": false, "bulk:2": false, "bulk:3": true, "bulk:4": true} {
+		if _, found := tc.Get(k); found != want {
+			t.Errorf("Get(%s) found %t, want %t", k, found, want)
+		}
+	}
+	if err := tc.ConsistencyCheck(); err != nil {
+		t.Error(err)
+	}
+	if len(sizes) != 1 || sizes[0] != 3 {
+		t.Errorf("Policies made for sizes %v, want [3]", sizes)
+	}
+
+	// A cache with a policy isn't split, so it gets a single one.
+	sizes = nil
+	New(DefaultExpiration, 0, WithShards(4), WithMaxEntries(10), WithEvictionPolicy(newPolicy))
+	if len(sizes) != 1 {
+		t.Errorf("Made %d policies for a cache asking for 4 shards, want 1", len(sizes))
+	}
+}
+
+// Always picks a key that isn't in the cache.
+type strayPolicy struct{ EvictionPolicy }
+
+func (strayPolicy) Victim() (interface{}, bool) {
+	return "stray", true
+}
+
+func TestEvictionPolicyStrayVictim(t *testing.T) {
+	tc := New(DefaultExpiration, 0, WithMaxEntries(2), WithEvictionPolicy(func(int) EvictionPolicy {
+		return strayPolicy{NewLRUPolicy()}
+	}))
+	for _, k := range []string{"a", "b", "c", "d"} {
+		tc.Set(k, k, DefaultExpiration)
+	}
+	if n := tc.ItemCount(); n != 2 {
+		t.Errorf("ItemCount is %d, want 2", n)
+	}
+	if err := tc.ConsistencyCheck(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,150 @@
+package cache
+
+import (
+	"sort"
+	"strings"
+)
+
+// RenamePrefix moves every unexpired item whose key is a string starting with
+// oldPrefix to the same key with oldPrefix replaced by newPrefix, keeping its
+// value, expiration, cost, tags, priority and pin. All matching items are
+// moved at once, so prefixes may overlap. An existing item under a new key is
+// overwritten, as with Set. Returns the number of items moved.
+func (c *cache) RenamePrefix(oldPrefix, newPrefix string) int {
+	unlock := c.lockAll()
+	defer unlock()
+
+	if c.shards == nil {
+		return c.putMoved(c.takePrefix(oldPrefix), oldPrefix, newPrefix)
+	}
+	byShard := map[*cache][]prefixMove{}
+	for _, s := range c.shards {
+		for _, m := range s.takePrefix(oldPrefix) {
+			to := c.keyShard(newPrefix + m.key[len(oldPrefix):])
+			byShard[to] = append(byShard[to], m)
+		}
+	}
+	moved := 0
+	for s, moves := range byShard {
+		moved += s.putMoved(moves, oldPrefix, newPrefix)
+	}
+	return moved
+}
+
+// An item taken out of the cache by RenamePrefix, with what it keeps.
+type prefixMove struct {
+	key      string
+	item     Item
+	tags     []string
+	cost     int64
+	priority Priority
+	pinned   bool
+}
+
+// Delete the unexpired items whose key is a string starting with prefix and
+// return them. Must be called with c locked.
+func (c *cache) takePrefix(prefix string) []prefixMove {
+	now := c.now().UnixNano()
+	var moves []prefixMove
+	for k, v := range c.items {
+		sk, ok := k.(string)
+		if !ok || !strings.HasPrefix(sk, prefix) {
+			continue
+		}
+		if v.Expiration > 0 && now > v.Expiration {
+			continue
+		}
+		e, _ := c.access.entry(k)
+		moves = append(moves, prefixMove{
+			key:      sk,
+			item:     v,
+			cost:     c.costs[k],
+			priority: e.priority,
+			pinned:   c.access.isPinned(k),
+		})
+	}
+	for i := range moves {
+		moves[i].tags = c.untag(moves[i].key)
+		c.delete(moves[i].key, EventDelete)
+	}
+	return moves
+}
+
+// Store the items taken by takePrefix with oldPrefix replaced by newPrefix,
+// and return the number stored. Must be called with c locked.
+func (c *cache) putMoved(moves []prefixMove, oldPrefix, newPrefix string) int {
+	moved := 0
+	for _, m := range moves {
+		nk := newPrefix + m.key[len(oldPrefix):]
+		if !c.put(nk, m.item) {
+			continue
+		}
+		if m.pinned {
+			c.access.pin(nk)
+		}
+		c.setCost(nk, m.cost)
+		for _, tag := range m.tags {
+			c.tag(nk, tag)
+		}
+		if m.priority != PriorityNormal {
+			c.access.prioritize(nk, m.priority)
+		}
+		moved++
+	}
+	return moved
+}
+
+// DeleteByPrefix deletes every item whose key is a string starting with prefix,
+// calling OnEvicted for them, and returns the number of unexpired items that
+// were deleted. Expired items with a matching key are removed as if by
+// DeleteExpired.
+func (c *cache) DeleteByPrefix(prefix string) int {
+	if c.shards != nil {
+		n := 0
+		for _, s := range c.shards {
+			n += s.DeleteByPrefix(prefix)
+		}
+		return n
+	}
+	c.Lock()
+	defer c.unlock()
+
+	now := c.now().UnixNano()
+	n := 0
+	for k, v := range c.items {
+		sk, ok := k.(string)
+		if !ok || !strings.HasPrefix(sk, prefix) {
+			continue
+		}
+		if v.Expiration > 0 && now > v.Expiration {
+			c.evict(k, EventExpire)
+			continue
+		}
+		c.evict(k, EventDelete)
+		n++
+	}
+	return n
+}
+
+// KeysWithPrefix returns the sorted keys of the unexpired items whose key is a
+// string starting with prefix.
+func (c *cache) KeysWithPrefix(prefix string) []string {
+	now := c.now().UnixNano()
+	var keys []string
+	for _, s := range c.parts() {
+		s.RLock()
+		for k, v := range s.items {
+			sk, ok := k.(string)
+			if !ok || !strings.HasPrefix(sk, prefix) {
+				continue
+			}
+			if v.Expiration > 0 && now > v.Expiration {
+				continue
+			}
+			keys = append(keys, sk)
+		}
+		s.RUnlock()
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,106 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRenamePrefix(t *testing.T) {
+	tc := New(DefaultExpiration, 0, WithShards(1))
+	tc.Set("v1:a", 1, time.Hour)
+	tc.Set("v1:b", 2, NoExpiration)
+	tc.Set("v1:c", 3, time.Millisecond)
+	tc.Set("other", 4, DefaultExpiration)
+	<-time.After(5 * time.Millisecond)
+
+	before := tc.items["v1:a"].Expiration
+	if n := tc.RenamePrefix("v1:", "v2:"); n != 2 {
+		t.Errorf("Moved %d items, want 2", n)
+	}
+	if _, found := tc.Get("v1:a"); found {
+		t.Error("Found v1:a after it was moved")
+	}
+	if x, found := tc.Get("v2:a"); !found || x.(int) != 1 {
+		t.Errorf("v2:a is %v, %v", x, found)
+	}
+	if x, found := tc.Get("v2:b"); !found || x.(int) != 2 {
+		t.Errorf("v2:b is %v, %v", x, found)
+	}
+	if _, found := tc.Get("v2:c"); found {
+		t.Error("Moved an expired item")
+	}
+	if _, found := tc.Get("other"); !found {
+		t.Error("Did not find other")
+	}
+	if e := tc.items["v2:a"].Expiration; e != before {
+		t.Errorf("Expiration changed from %d to %d", before, e)
+	}
+	if e := tc.items["v2:b"].Expiration; e != 0 {
+		t.Errorf("v2:b expires at %d, want never", e)
+	}
+}
+
+func TestDeleteByPrefix(t *testing.T) {
+	tc := New(DefaultExpiration, 0)
+	var evicted []interface{}
+	tc.OnEvicted(func(k, v interface{}) {
+		evicted = append(evicted, k)
+	})
+	tc.Set("user:1:name", "a", DefaultExpiration)
+	tc.Set("user:1:mail", "b", DefaultExpiration)
+	tc.Set("user:2:name", "c", DefaultExpiration)
+	tc.Set("user:1:old", "d", time.Millisecond)
+	tc.Set(1, "e", DefaultExpiration)
+	<-time.After(5 * time.Millisecond)
+
+	keys := tc.KeysWithPrefix("user:")
+	if len(keys) != 3 || keys[0] != "user:1:mail" || keys[1] != "user:1:name" || keys[2] != "user:2:name" {
+		t.Error("KeysWithPrefix returned", keys)
+	}
+
+	if n := tc.DeleteByPrefix("user:1:"); n != 2 {
+		t.Errorf("Deleted %d items, want 2", n)
+	}
+	if len(evicted) != 3 {
+		t.Errorf("OnEvicted was called for %v, want 3 keys", evicted)
+	}
+	if n := tc.ItemCount(); n != 2 {
+		t.Errorf("ItemCount is %d, want 2", n)
+	}
+	if keys := tc.KeysWithPrefix("user:1:"); len(keys) != 0 {
+		t.Error("KeysWithPrefix returned deleted keys", keys)
+	}
+}
+
+func TestRenamePrefixOverlapping(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		tc := New(DefaultExpiration, 0, WithMaxCost(100))
+		tc.Set("a1", "A1", DefaultExpiration)
+		tc.SetWithCost("aa1", "AA1", 5, DefaultExpiration)
+		tc.Pin("a1")
+		tc.SetWithTags("aa2", "AA2", DefaultExpiration, "t")
+		if n := tc.RenamePrefix("a", "aa"); n != 3 {
+			t.Fatalf("Moved %d items, want 3", n)
+		}
+		for k, want := range map[string]string{"aa1": "A1", "aaa1": "AA1", "aaa2": "AA2"} {
+			if x, found := tc.Get(k); !found || x != want {
+				t.Fatalf("%s is %v, %t, want %s", k, x, found, want)
+			}
+		}
+		if _, found := tc.Get("a1"); found {
+			t.Fatal("Found a1 after it was moved")
+		}
+		if r, _ := tc.Inspect("aaa1"); r.Cost != 5 {
+			t.Fatalf("Cost of aaa1 is %d, want 5", r.Cost)
+		}
+		if !tc.access.isPinned("aa1") {
+			t.Fatal("aa1 lost the pin of a1")
+		}
+		if n := tc.DeleteByTag("t"); n != 1 {
+			t.Fatalf("DeleteByTag deleted %d items, want 1", n)
+		}
+		if err := tc.ConsistencyCheck(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package cache
+
+import "time"
+
+// A Priority decides which items are evicted first when the cache is full.
+type Priority int
+
+const (
+	// Items evicted before all others.
+	PriorityLow Priority = iota - 1
+	// The priority of items stored without one.
+	PriorityNormal
+	// Items evicted only when no items of lower priority are left.
+	PriorityHigh
+)
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityNormal:
+		return "normal"
+	case PriorityHigh:
+		return "high"
+	}
+	return "unknown"
+}
+
+// SetWithPriority adds an item to the cache like Set, with priority p. When
+// the cache is full, items are evicted from the lowest priority first, and
+// among items of the same priority in the order of the eviction policy, such
+// as least recently used first. This applies to the limits set with
+// WithMaxEntries and WithMaxCost, and to TriggerMemoryPressure; EvictLRU and
+// expiration ignore priorities. Storing the key again with Set or a similar
+// method resets its priority to PriorityNormal, while changing its value in
+// place with Update, Increment and the like, or reloading it with a loader,
+// keeps it. Priorities below PriorityLow count as PriorityLow, and those above
+// PriorityHigh as PriorityHigh.
+func (c *cache) SetWithPriority(k interface{}, x interface{}, p Priority, d time.Duration) {
+	c = c.shard(k)
+	k = c.key(k)
+	c.Lock()
+	defer c.unlock()
+
+	if !c.set(k, x, d) {
+		return
+	}
+	c.access.prioritize(k, p)
+}
+
+// Store x under k like set, keeping the priority of the unexpired item it
+// replaces. Must be called with the write lock held.
+func (c *cache) rewrite(k interface{}, x interface{}, d time.Duration) bool {
+	p := PriorityNormal
+	if _, found := c.get(k); found {
+		p = c.access.priority(k)
+	}
+	if !c.set(k, x, d) {
+		return false
+	}
+	c.access.prioritize(k, p)
+	return true
+}
+
+// Returns the priority of k, or PriorityNormal if it isn't tracked.
+func (a *accessOrder) priority(k interface{}) Priority {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if e, found := a.elems[k]; found {
+		return e.Value.(*accessEntry).priority
+	}
+	return PriorityNormal
+}
+
+// Set the priority of k, which must be tracked.
+func (a *accessOrder) prioritize(k interface{}, p Priority) {
+	if p < PriorityLow {
+		p = PriorityLow
+	} else if p > PriorityHigh {
+		p = PriorityHigh
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	e := a.elems[k].Value.(*accessEntry)
+	if e.priority == p {
+		return
+	}
+	if a.levels == nil {
+		if a.policy == nil {
+			// Keep the keys of normal priority apart from the others,
+			// in the same order.
+			a.policy = newLRUPolicy()
+			for e := a.list.Back(); e != nil; e = e.Prev() {
+				if k := e.Value.(*accessEntry).key; !a.pinned[k] {
+					a.policy.RecordInsert(k)
+				}
+			}
+		}
+		a.levels = map[Priority]EvictionPolicy{}
+	}
+	if p != PriorityNormal && a.levels[p] == nil {
+		if a.newPolicy != nil {
+			a.levels[p] = a.newPolicy()
+		} else {
+			a.levels[p] = newLRUPolicy()
+		}
+	}
+	if old := a.policyFor(k); old != nil {
+		old.Remove(k)
+	}
+	e.priority = p
+	if policy := a.policyFor(k); policy != nil {
+		policy.RecordInsert(k)
+	}
+}
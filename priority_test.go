@@ -0,0 +1,118 @@
+package cache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestSetWithPriority(t *testing.T) {
+	for name, opts := range map[string][]Option{
+		"LRU": nil,
+		"LFU": {WithLFUEviction()},
+		"ARC": {WithARCEviction()},
+	} {
+		tc := New(DefaultExpiration, 0, append(opts, WithMaxEntries(4))...)
+		tc.SetWithPriority("expensive", 1, PriorityHigh, DefaultExpiration)
+		tc.Set("normal", 2, DefaultExpiration)
+		tc.SetWithPriority("cheap1", 3, PriorityLow, DefaultExpiration)
+		tc.SetWithPriority("cheap2", 4, PriorityLow, DefaultExpiration)
+		tc.Get("cheap1")
+		tc.Get("cheap2")
+
+		// Items of low priority go first, then those of normal priority.
+		tc.Set("a", 5, DefaultExpiration)
+		tc.Set("b", 6, DefaultExpiration)
+		for k, want := range map[string]bool{"expensive": true, "normal": true, "cheap1": false, "cheap2": false} {
+			if _, found := tc.Get(k); found != want {
+				t.Errorf("%s: Get(%s) found %t, want %t", name, k, found, want)
+			}
+		}
+		for i := 0; i < 5; i++ {
+			tc.Set(fmt.Sprint(i), i, DefaultExpiration)
+		}
+		if _, found := tc.Get("expensive"); !found {
+			t.Errorf("%s: item of high priority was evicted", name)
+		}
+		if err := tc.ConsistencyCheck(); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
+
+func TestPriorityReset(t *testing.T) {
+	tc := New(DefaultExpiration, 0, WithMaxEntries(2))
+	tc.SetWithPriority("a", 1, PriorityHigh, DefaultExpiration)
+	tc.Set("b", 2, DefaultExpiration)
+	if r, _ := tc.Inspect("a"); r.Priority != PriorityHigh {
+		t.Errorf("Priority of a is %v, want high", r.Priority)
+	}
+	// Storing a again without a priority makes it normal.
+	tc.Set("a", 3, DefaultExpiration)
+	if r, _ := tc.Inspect("a"); r.Priority != PriorityNormal {
+		t.Errorf("Priority of a is %v, want normal", r.Priority)
+	}
+	tc.Set("c", 4, DefaultExpiration)
+	if _, found := tc.Get("b"); found {
+		t.Error("b, least recently used, was not evicted")
+	}
+
+	// Priorities out of range are clamped.
+	tc.SetWithPriority("d", 5, Priority(10), DefaultExpiration)
+	if r, _ := tc.Inspect("d"); r.Priority != PriorityHigh {
+		t.Errorf("Priority of d is %v, want high", r.Priority)
+	}
+	// Pinned items keep out of eviction whatever their priority.
+	tc.SetWithPriority("e", 6, PriorityLow, DefaultExpiration)
+	tc.Pin("e")
+	tc.Set("f", 7, DefaultExpiration)
+	if _, found := tc.Get("e"); !found {
+		t.Error("Pinned item of low priority was evicted")
+	}
+	tc.Unpin("e", DefaultExpiration)
+	tc.Set("g", 8, DefaultExpiration)
+	if _, found := tc.Get("e"); found {
+		t.Error("Unpinned item of low priority was not evicted")
+	}
+	tc.Flush()
+	if err := tc.ConsistencyCheck(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPriorityKept(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	tc := New(10*time.Minute, 0, WithClock(clock), WithMaxEntries(10), WithRefreshAhead(0.5, func(k interface{}) (interface{}, time.Duration, error) {
+		return "new", DefaultExpiration, nil
+	}))
+	tc.SetWithPriority("n", 1, PriorityHigh, DefaultExpiration)
+	tc.SetWithPriority("s", "old", PriorityHigh, DefaultExpiration)
+
+	// Values changed in place keep their priority.
+	tc.Increment("n", 1)
+	if r, _ := tc.Inspect("n"); r.Priority != PriorityHigh {
+		t.Errorf("Priority after Increment is %v, want high", r.Priority)
+	}
+	tc.Update("n", func(old interface{}, exists bool) (interface{}, time.Duration, bool) {
+		return old.(int) * 2, DefaultExpiration, true
+	})
+	if r, _ := tc.Inspect("n"); r.Priority != PriorityHigh {
+		t.Errorf("Priority after Update is %v, want high", r.Priority)
+	}
+
+	// So do reloaded ones.
+	clock.Advance(6 * time.Minute)
+	tc.Get("s")
+	for {
+		if x, _ := tc.Get("s"); x == "new" {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if r, _ := tc.Inspect("s"); r.Priority != PriorityHigh {
+		t.Errorf("Priority after a refresh is %v, want high", r.Priority)
+	}
+	if err := tc.ConsistencyCheck(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,91 @@
+// Package promcache exports the statistics of a cache as Prometheus metrics.
+//
+//	c := cache.New(5*time.Minute, 10*time.Minute)
+//	prometheus.MustRegister(promcache.NewCollector("sessions", c))
+//
+// It lives in its own module so that the cache package doesn't depend on the
+// Prometheus client.
+package promcache
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rumsrami/cache"
+)
+
+// A StatsSource is a cache whose statistics can be collected, such as a
+// *cache.Cache or the result of Untyped on a *cache.Typed.
+type StatsSource interface {
+	Stats() cache.Stats
+}
+
+// Collector is a prometheus.Collector reporting the Stats of a cache.
+type Collector struct {
+	source StatsSource
+
+	hits        *prometheus.Desc
+	misses      *prometheus.Desc
+	sets        *prometheus.Desc
+	deletes     *prometheus.Desc
+	expirations *prometheus.Desc
+	evictions   *prometheus.Desc
+	rejections  *prometheus.Desc
+	loadErrors  *prometheus.Desc
+	loads       *prometheus.Desc
+	items       *prometheus.Desc
+}
+
+// NewCollector returns a Collector for c whose metrics carry a "cache" label
+// with the given name, so several caches can be registered in one process.
+// Counters start again from zero when the cache's ResetStats is called, which
+// Prometheus treats as a counter reset.
+func NewCollector(name string, c StatsSource) *Collector {
+	labels := prometheus.Labels{"cache": name}
+	desc := func(metric, help string) *prometheus.Desc {
+		return prometheus.NewDesc("cache_"+metric, help, nil, labels)
+	}
+	return &Collector{
+		source:      c,
+		hits:        desc("hits_total", "Lookups that found an unexpired item."),
+		misses:      desc("misses_total", "Lookups that didn't find an unexpired item."),
+		sets:        desc("sets_total", "Items stored in the cache."),
+		deletes:     desc("deletes_total", "Items deleted from the cache."),
+		expirations: desc("expirations_total", "Expired items removed from the cache."),
+		evictions:   desc("evictions_total", "Items evicted to make room for other items."),
+		rejections:  desc("rejections_total", "New items not stored because the admission filter rejected them."),
+		loadErrors:  desc("load_errors_total", "Loader calls that returned an error."),
+		loads:       desc("load_duration_seconds", "Time spent in loader calls."),
+		items:       desc("items", "Items in the cache, including expired items not yet removed."),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.hits
+	ch <- c.misses
+	ch <- c.sets
+	ch <- c.deletes
+	ch <- c.expirations
+	ch <- c.evictions
+	ch <- c.rejections
+	ch <- c.loadErrors
+	ch <- c.loads
+	ch <- c.items
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	s := c.source.Stats()
+	counter := func(d *prometheus.Desc, v uint64) {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.CounterValue, float64(v))
+	}
+	counter(c.hits, s.Hits)
+	counter(c.misses, s.Misses)
+	counter(c.sets, s.Sets)
+	counter(c.deletes, s.Deletes)
+	counter(c.expirations, s.Expirations)
+	counter(c.evictions, s.Evictions)
+	counter(c.rejections, s.Rejections)
+	counter(c.loadErrors, s.LoadErrors)
+	ch <- prometheus.MustNewConstSummary(c.loads, s.Loads, s.LoadTime.Seconds(), nil)
+	ch <- prometheus.MustNewConstMetric(c.items, prometheus.GaugeValue, float64(s.Items))
+}
@@ -0,0 +1,47 @@
+package promcache
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rumsrami/cache"
+)
+
+func TestCollector(t *testing.T) {
+	a := cache.New(cache.DefaultExpiration, 0)
+	b := cache.New(cache.DefaultExpiration, 0, cache.WithShards(2))
+	a.Set("x", 1, cache.DefaultExpiration)
+	a.Get("x")
+	a.Get("y")
+	b.GetOrLoad("x", func(k interface{}) (interface{}, time.Duration, error) {
+		return 1, cache.DefaultExpiration, nil
+	})
+
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(NewCollector("a", a), NewCollector("b", b))
+
+	want := `
+# HELP cache_hits_total Lookups that found an unexpired item.
+# TYPE cache_hits_total counter
+cache_hits_total{cache="a"} 1
+cache_hits_total{cache="b"} 0
+# HELP cache_items Items in the cache, including expired items not yet removed.
+# TYPE cache_items gauge
+cache_items{cache="a"} 1
+cache_items{cache="b"} 1
+# HELP cache_misses_total Lookups that didn't find an unexpired item.
+# TYPE cache_misses_total counter
+cache_misses_total{cache="a"} 1
+cache_misses_total{cache="b"} 1
+`
+	err := testutil.GatherAndCompare(reg, strings.NewReader(want), "cache_hits_total", "cache_misses_total", "cache_items")
+	if err != nil {
+		t.Error(err)
+	}
+	if n := testutil.CollectAndCount(NewCollector("b", b), "cache_load_duration_seconds"); n != 1 {
+		t.Errorf("got %d load duration metrics, want 1", n)
+	}
+}
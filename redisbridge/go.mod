@@ -0,0 +1,17 @@
+module github.com/rumsrami/cache/redisbridge
+
+go 1.25.0
+
+require (
+	github.com/alicebob/miniredis/v2 v2.37.0
+	github.com/redis/go-redis/v9 v9.9.0
+	github.com/rumsrami/cache v0.0.0-20261016120748-252007477c0e
+)
+
+require (
+	github.com/cespare/xxhash/v2 v2.3.0 // indirect
+	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
+	github.com/yuin/gopher-lua v1.1.1 // indirect
+)
+
+replace github.com/rumsrami/cache => ../
@@ -0,0 +1,309 @@
+// Package redisbridge keeps the local caches of several processes consistent
+// by sending invalidations over a Redis pub/sub channel.
+//
+//	c := cache.New(5*time.Minute, 10*time.Minute)
+//	rdb := redis.NewClient(&redis.Options{Addr: "localhost:6379"})
+//	b, err := redisbridge.New(ctx, c, rdb, "cache:users")
+//	...
+//	c.Set("alice", user, cache.DefaultExpiration)
+//
+// A Bridge watches the events of the local cache and publishes the keys stored
+// and deleted on the channel; the bridges of other processes subscribed to it
+// then delete the keys from their caches, or with WithUpdates store the new
+// values. Items that expire or are evicted are not published, as each process
+// expires and evicts its own, and neither is Flush: use Bridge.Flush to flush
+// the other caches too. Events that the cache drops because the bridge falls
+// behind (see cache.Cache.DroppedEvents) are lost. Keys and values are sent
+// encoded with a cache.Codec, GobCodec by default, so their types must be
+// registered with it as for saving a cache.
+//
+// It lives in its own module so that the cache package doesn't depend on a
+// Redis client.
+package redisbridge
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/rumsrami/cache"
+)
+
+// A Cache is the local cache kept consistent, such as a *cache.Cache.
+type Cache interface {
+	Set(k interface{}, x interface{}, d time.Duration)
+	GetWithExpiration(k interface{}) (interface{}, time.Time, bool)
+	Pop(k interface{}) (interface{}, bool)
+	Flush()
+	Subscribe(f func(cache.Event)) (cancel func())
+}
+
+// An Option configures a Bridge.
+type Option func(*Bridge)
+
+// WithUpdates publishes the values stored, which other processes store in
+// their caches for what is left of their lifetime, instead of invalidations
+// that make them delete the keys.
+func WithUpdates() Option {
+	return func(b *Bridge) {
+		b.updates = true
+	}
+}
+
+// WithCodec encodes the keys and values sent with codec instead of
+// cache.GobCodec. All the processes on a channel must use the same codec.
+func WithCodec(codec cache.Codec) Option {
+	return func(b *Bridge) {
+		b.codec = codec
+	}
+}
+
+// WithErrorHandler calls f with the errors met receiving and applying
+// messages, such as messages that can't be decoded. By default they are
+// ignored.
+func WithErrorHandler(f func(error)) Option {
+	return func(b *Bridge) {
+		b.onError = f
+	}
+}
+
+// The operations sent on the channel.
+const (
+	opDelete = "delete"
+	opSet    = "set"
+	opFlush  = "flush"
+)
+
+// The value sent with deleted keys, as codecs such as GobCodec can't encode
+// nil values.
+const deleted = true
+
+// A message sent on the channel.
+type message struct {
+	// The bridge that sent the message, which ignores it.
+	Origin string `json:"origin"`
+	Op     string `json:"op"`
+	// For set, how long to store the values for, or cache.NoExpiration.
+	TTL time.Duration `json:"ttl,omitempty"`
+	// The keys and, for set, values, encoded with the codec.
+	Items []byte `json:"items,omitempty"`
+}
+
+// A Bridge publishes the writes made to a local cache to the other processes
+// on a Redis channel, and applies the writes they publish.
+type Bridge struct {
+	c       Cache
+	rdb     redis.UniversalClient
+	channel string
+	id      string
+	codec   cache.Codec
+	updates bool
+	onError func(error)
+
+	mu sync.Mutex
+	// The writes applied from the channel whose events are yet to be seen,
+	// by key, so that they aren't published again.
+	applied map[interface{}][]write
+
+	sub         *redis.PubSub
+	done        chan struct{}
+	unsubscribe func()
+	closeOnce   sync.Once
+}
+
+// A write applied to the local cache.
+type write struct {
+	op    string
+	value interface{}
+}
+
+// New subscribes to channel with rdb and returns a Bridge that keeps c
+// consistent with the caches of the other processes subscribed to it. The
+// Bridge publishes the writes made to c, and applies theirs to c, until it is
+// closed.
+func New(ctx context.Context, c Cache, rdb redis.UniversalClient, channel string, opts ...Option) (*Bridge, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	b := &Bridge{
+		c:       c,
+		rdb:     rdb,
+		channel: channel,
+		id:      hex.EncodeToString(id),
+		codec:   cache.GobCodec{},
+		applied: map[interface{}][]write{},
+		done:    make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	b.sub = rdb.Subscribe(ctx, channel)
+	// Wait for the subscription, so that no writes published after New
+	// returns are missed.
+	if _, err := b.sub.Receive(ctx); err != nil {
+		b.sub.Close()
+		return nil, err
+	}
+	go b.receive()
+	b.unsubscribe = c.Subscribe(b.changed)
+	return b, nil
+}
+
+// Flush deletes all items from the local cache and has the other processes
+// flush theirs. The local cache is flushed even if publishing fails.
+func (b *Bridge) Flush(ctx context.Context) error {
+	b.c.Flush()
+	return b.publish(ctx, opFlush, nil, nil, 0)
+}
+
+// Close stops publishing the writes made to the local cache and unsubscribes
+// from the channel. The local cache is not changed.
+func (b *Bridge) Close() error {
+	var err error
+	b.closeOnce.Do(func() {
+		b.unsubscribe()
+		err = b.sub.Close()
+		<-b.done
+	})
+	return err
+}
+
+// Publish the change e made to the local cache, unless it was applied from the
+// channel. Errors go to the error handler.
+func (b *Bridge) changed(e cache.Event) {
+	var op string
+	switch e.Op {
+	case cache.EventSet, cache.EventReplace:
+		op = opSet
+	case cache.EventDelete:
+		op = opDelete
+	default:
+		return
+	}
+	if b.seen(e.Key, write{op, e.Value}) {
+		return
+	}
+	var err error
+	switch {
+	case op == opDelete || !b.updates:
+		err = b.publish(context.Background(), opDelete, e.Key, deleted, 0)
+	default:
+		x, exp, found := b.c.GetWithExpiration(e.Key)
+		if !found || !reflect.DeepEqual(x, e.Value) {
+			// Overwritten or deleted since; that is published in turn.
+			return
+		}
+		d := cache.NoExpiration
+		if !exp.IsZero() {
+			if d = time.Until(exp); d <= 0 {
+				return
+			}
+		}
+		err = b.publish(context.Background(), opSet, e.Key, x, d)
+	}
+	if err != nil {
+		b.error(err)
+	}
+}
+
+// Reports whether w, made under k, was applied from the channel, and forgets
+// it if so.
+func (b *Bridge) seen(k interface{}, w write) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	writes := b.applied[k]
+	for i, a := range writes {
+		if a.op == w.op && reflect.DeepEqual(a.value, w.value) {
+			if len(writes) == 1 {
+				delete(b.applied, k)
+			} else {
+				b.applied[k] = append(writes[:i:i], writes[i+1:]...)
+			}
+			return true
+		}
+	}
+	return false
+}
+
+func (b *Bridge) publish(ctx context.Context, op string, k, x interface{}, d time.Duration) error {
+	m := message{Origin: b.id, Op: op, TTL: d}
+	if op != opFlush {
+		var buf bytes.Buffer
+		if err := b.codec.Encode(&buf, map[interface{}]cache.Item{k: {Object: x}}); err != nil {
+			return err
+		}
+		m.Items = buf.Bytes()
+	}
+	payload, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return b.rdb.Publish(ctx, b.channel, payload).Err()
+}
+
+// Apply the messages received until the subscription is closed.
+func (b *Bridge) receive() {
+	defer close(b.done)
+	for {
+		msg, err := b.sub.ReceiveMessage(context.Background())
+		if err != nil {
+			if errors.Is(err, redis.ErrClosed) {
+				return
+			}
+			b.error(err)
+			continue
+		}
+		if err := b.apply(msg.Payload); err != nil {
+			b.error(err)
+		}
+	}
+}
+
+func (b *Bridge) apply(payload string) error {
+	var m message
+	if err := json.Unmarshal([]byte(payload), &m); err != nil {
+		return err
+	}
+	if m.Origin == b.id {
+		return nil
+	}
+	if m.Op == opFlush {
+		b.c.Flush()
+		return nil
+	}
+	items, err := b.codec.Decode(bytes.NewReader(m.Items))
+	if err != nil {
+		return err
+	}
+	if m.Op != opSet && m.Op != opDelete {
+		return errors.New("redisbridge: unknown operation " + m.Op)
+	}
+	// Writes are applied and recorded under the mutex, so that their events
+	// are only looked at once they are recorded.
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for k, item := range items {
+		if m.Op == opSet {
+			b.applied[k] = append(b.applied[k], write{opSet, item.Object})
+			b.c.Set(k, item.Object, m.TTL)
+		} else if x, found := b.c.Pop(k); found {
+			b.applied[k] = append(b.applied[k], write{opDelete, x})
+		}
+	}
+	return nil
+}
+
+func (b *Bridge) error(err error) {
+	if b.onError != nil {
+		b.onError(err)
+	}
+}
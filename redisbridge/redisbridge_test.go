@@ -0,0 +1,159 @@
+package redisbridge
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/rumsrami/cache"
+)
+
+// Returns bridges for n caches on one channel.
+func bridges(t *testing.T, n int, opts ...Option) ([]*cache.Cache, []*Bridge) {
+	s := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	caches := make([]*cache.Cache, n)
+	bs := make([]*Bridge, n)
+	for i := range caches {
+		caches[i] = cache.New(cache.NoExpiration, 0)
+		b, err := New(context.Background(), caches[i], rdb, "test", opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { b.Close() })
+		bs[i] = b
+	}
+	return caches, bs
+}
+
+// Wait until cond is true.
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); !cond(); {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// Wait until dst has applied the writes that src published so far, with
+// invalidations.
+func drain(t *testing.T, dst, src *cache.Cache) {
+	t.Helper()
+	dst.Set("sync", 1, cache.DefaultExpiration)
+	src.Set("sync", 1, cache.DefaultExpiration)
+	eventually(t, "sync to be deleted", func() bool {
+		_, found := dst.Get("sync")
+		return !found
+	})
+}
+
+func TestInvalidation(t *testing.T) {
+	ctx := context.Background()
+	caches, bs := bridges(t, 2)
+	a, b := caches[0], caches[1]
+
+	b.Set("k", "stale", cache.DefaultExpiration)
+	drain(t, a, b)
+	a.Set("k", "new", cache.DefaultExpiration)
+	eventually(t, "k to be deleted", func() bool {
+		_, found := b.Get("k")
+		return !found
+	})
+	// The deletion applied to b is not published in turn.
+	drain(t, a, b)
+	if x, _ := a.Get("k"); x != "new" {
+		t.Errorf("got %v, want new in the writer's cache", x)
+	}
+
+	b.Set("i", 1, cache.DefaultExpiration)
+	if err := bs[0].Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "the cache to be flushed", func() bool {
+		return b.ItemCount() == 0
+	})
+}
+
+func TestUpdates(t *testing.T) {
+	caches, _ := bridges(t, 3, WithUpdates())
+	// Wait until caches[i] has applied the writes that caches[0] published so
+	// far.
+	syncs := 0
+	drain := func(i int) {
+		t.Helper()
+		syncs++
+		caches[0].Set("sync", syncs, cache.DefaultExpiration)
+		eventually(t, "sync to be stored", func() bool {
+			x, _ := caches[i].Get("sync")
+			return x == syncs
+		})
+	}
+
+	events, cancel := caches[0].Watch("k")
+	defer cancel()
+	caches[0].Set("k", "v", time.Hour)
+	for _, c := range caches[1:] {
+		eventually(t, "k to be stored", func() bool {
+			x, _ := c.Get("k")
+			return x == "v"
+		})
+		if _, e, _ := c.GetWithExpiration("k"); time.Until(e) <= 59*time.Minute {
+			t.Errorf("got expiration %v, want an hour from now", e)
+		}
+	}
+	caches[1].Delete("k")
+	for _, c := range []*cache.Cache{caches[0], caches[2]} {
+		eventually(t, "k to be deleted", func() bool {
+			_, found := c.Get("k")
+			return !found
+		})
+	}
+	// The writes applied from the channel are not published in turn.
+	if n := len(events); n != 2 {
+		t.Errorf("got %d events for k, want a set and a delete", n)
+	}
+
+	// Neither are evictions and flushes.
+	caches[0].Set("e", 1, cache.NoExpiration)
+	drain(1)
+	caches[0].EvictLRU(2)
+	caches[0].Flush()
+	drain(1)
+	if _, found := caches[1].Get("e"); !found {
+		t.Error("e was deleted from the other caches")
+	}
+}
+
+func TestErrorHandler(t *testing.T) {
+	s := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	defer rdb.Close()
+
+	var mu sync.Mutex
+	var errs []error
+	b, err := New(context.Background(), cache.New(cache.NoExpiration, 0), rdb, "test", WithErrorHandler(func(err error) {
+		mu.Lock()
+		errs = append(errs, err)
+		mu.Unlock()
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rdb.Publish(context.Background(), "test", "not json")
+	eventually(t, "an error", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(errs) == 1
+	})
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// Closing twice is harmless.
+	b.Close()
+}
@@ -0,0 +1,62 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// WithRefreshAhead makes lookups of an item that is more than threshold of the
+// way through its lifetime reload it with load in the background, so that hot
+// items are replaced before they expire instead of being missed. With a
+// threshold of 0.8, an item stored for ten minutes is refreshed when it is
+// read after eight. The lookup returns the current value without waiting, and
+// an item is only refreshed once at a time. load is called with the key as
+// stored, i.e. converted by WithKeyFunc, and its result is stored as with
+// GetOrLoad; if it fails, the item is kept until it expires. This applies to
+// Get, GetOrLoad and GetOrLoadContext. Items that don't expire are never
+// refreshed. threshold is limited to between 0 and 1.
+func WithRefreshAhead(threshold float64, load func(k interface{}) (interface{}, time.Duration, error)) Option {
+	return func(c *cache) {
+		switch {
+		case threshold < 0:
+			threshold = 0
+		case threshold > 1:
+			threshold = 1
+		}
+		c.refreshAhead = &refreshAhead{threshold: threshold, load: load}
+	}
+}
+
+type refreshAhead struct {
+	threshold float64
+	load      loader
+}
+
+// Reports whether the unexpired item under k is far enough through its
+// lifetime to be refreshed.
+func (c *cache) dueForRefresh(k interface{}, item Item) bool {
+	if c.refreshAhead == nil || item.Expiration <= 0 {
+		return false
+	}
+	e, found := c.access.entry(k)
+	if !found || e.stored >= item.Expiration {
+		return false
+	}
+	elapsed := c.now().UnixNano() - e.stored
+	return float64(elapsed) > c.refreshAhead.threshold*float64(item.Expiration-e.stored)
+}
+
+// Start reloading k in the background with the refresh-ahead loader, unless
+// it is already being loaded. Must be called with c locked.
+func (c *cache) refresh(k interface{}) {
+	if _, loading := c.loads[k]; loading {
+		return
+	}
+	if c.breaker != nil && !c.breaker.allow(c.now().UnixNano()) {
+		return
+	}
+	call := c.startLoad(k)
+	go c.finishLoad(context.Background(), k, call, func() (interface{}, time.Duration, error) {
+		return c.refreshAhead.load(k)
+	})
+}
@@ -0,0 +1,54 @@
+package cache
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRefreshAhead(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	var loads int32
+	release := make(chan struct{})
+	tc := New(10*time.Minute, 0, WithClock(clock), WithRefreshAhead(0.8, func(k interface{}) (interface{}, time.Duration, error) {
+		atomic.AddInt32(&loads, 1)
+		<-release
+		return "new", DefaultExpiration, nil
+	}))
+	tc.Set("a", "old", DefaultExpiration)
+	tc.Set("b", "forever", NoExpiration)
+
+	clock.Advance(7 * time.Minute)
+	tc.Get("a")
+	tc.Get("b")
+	if n := atomic.LoadInt32(&loads); n != 0 {
+		t.Fatalf("Refreshed %d times before the threshold", n)
+	}
+
+	clock.Advance(2 * time.Minute)
+	for i := 0; i < 3; i++ {
+		if x, found := tc.Get("a"); !found || x != "old" {
+			t.Fatalf("Get returned %v, %v; want the current value", x, found)
+		}
+	}
+	x, err := tc.GetOrLoad("a", func(k interface{}) (interface{}, time.Duration, error) {
+		t.Error("GetOrLoad loaded an unexpired item")
+		return nil, 0, nil
+	})
+	if err != nil || x != "old" {
+		t.Fatalf("GetOrLoad returned %v, %v; want the current value", x, err)
+	}
+	close(release)
+	for {
+		if x, _ := tc.Get("a"); x == "new" {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if n := atomic.LoadInt32(&loads); n != 1 {
+		t.Errorf("Refreshed %d times, want once", n)
+	}
+	if ttl, _ := tc.TTL("a"); ttl != 10*time.Minute {
+		t.Errorf("TTL of the refreshed item is %v, want 10m", ttl)
+	}
+}
@@ -0,0 +1,36 @@
+package cache
+
+import "time"
+
+// The value stored by SetResponse.
+type cachedResponse struct {
+	body         []byte
+	etag         string
+	lastModified time.Time
+}
+
+// SetResponse stores an HTTP response body together with its ETag and
+// Last-Modified validators, replacing any existing item, with the expiration d
+// as for Set. The body is not copied, so it must not be modified afterwards.
+func (c *cache) SetResponse(k interface{}, body []byte, etag string, lastModified time.Time, d time.Duration) {
+	c.Set(k, cachedResponse{
+		body:         body,
+		etag:         etag,
+		lastModified: lastModified,
+	}, d)
+}
+
+// GetResponse gets a response stored with SetResponse. Returns its body, ETag
+// and Last-Modified time, and a bool indicating whether the key was found and
+// holds a response. The returned body must not be modified.
+func (c *cache) GetResponse(k interface{}) (body []byte, etag string, lastModified time.Time, found bool) {
+	x, found := c.Get(k)
+	if !found {
+		return nil, "", time.Time{}, false
+	}
+	r, ok := x.(cachedResponse)
+	if !ok {
+		return nil, "", time.Time{}, false
+	}
+	return r.body, r.etag, r.lastModified, true
+}
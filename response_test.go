@@ -0,0 +1,35 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestResponse(t *testing.T) {
+	tc := New(DefaultExpiration, 0)
+	modified := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	tc.SetResponse("/index.html", []byte("<html></html>"), `"abc123"`, modified, 10*time.Millisecond)
+	tc.Set("/other", "not a response", DefaultExpiration)
+
+	body, etag, lastModified, found := tc.GetResponse("/index.html")
+	if !found {
+		t.Fatal("Did not find the response")
+	}
+	if string(body) != "<html></html>" {
+		t.Errorf("Body is %q", body)
+	}
+	if etag != `"abc123"` {
+		t.Errorf("ETag is %s", etag)
+	}
+	if !lastModified.Equal(modified) {
+		t.Errorf("Last-Modified is %v, want %v", lastModified, modified)
+	}
+	if _, _, _, found := tc.GetResponse("/other"); found {
+		t.Error("Got a response for an item that isn't one")
+	}
+
+	<-time.After(20 * time.Millisecond)
+	if _, _, _, found := tc.GetResponse("/index.html"); found {
+		t.Error("Found the response after it expired")
+	}
+}
@@ -0,0 +1,61 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// A RetryPolicy tells the cache how to retry failed loads. See WithLoadRetry.
+type RetryPolicy struct {
+	// The maximum number of times the loader is called for one load,
+	// including the first. If it is less than two, loads are not retried.
+	Attempts int
+	// The delay before the first retry. It doubles for every later retry.
+	Backoff time.Duration
+	// If greater than zero, the longest delay between retries.
+	MaxBackoff time.Duration
+	// Reports whether a load that failed with err should be retried. If
+	// nil, all errors are retried.
+	Retryable func(err error) bool
+}
+
+// WithLoadRetry makes GetOrLoad and the other loading methods retry a loader
+// that returns an error as set by policy, so that brief failures of the
+// backing store don't reach the callers. Callers sharing the load wait for
+// all of its attempts. Only the final result counts in Stats, and towards
+// WithNegativeCacheTTL and WithLoadCircuitBreaker. GetOrLoadContext stops
+// retrying when the context of the caller that started the load is done, and
+// returns the last error. The delays between retries are measured by the
+// system clock, not by WithClock.
+func WithLoadRetry(policy RetryPolicy) Option {
+	return func(c *cache) {
+		c.retry = &policy
+	}
+}
+
+// Call load until it succeeds, it returns an error that isn't retryable, the
+// attempts run out or ctx is done. A nil policy calls load once.
+func (p *RetryPolicy) run(ctx context.Context, load func() (interface{}, time.Duration, error)) (interface{}, time.Duration, error) {
+	object, d, err := load()
+	if p == nil {
+		return object, d, err
+	}
+	backoff := p.Backoff
+	for attempt := 1; err != nil && attempt < p.Attempts; attempt++ {
+		if p.Retryable != nil && !p.Retryable(err) {
+			break
+		}
+		t := time.NewTimer(backoff)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return object, d, err
+		}
+		object, d, err = load()
+		if backoff *= 2; p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+			backoff = p.MaxBackoff
+		}
+	}
+	return object, d, err
+}
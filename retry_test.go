@@ -0,0 +1,69 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLoadRetry(t *testing.T) {
+	errBusy := errors.New("busy")
+	errGone := errors.New("gone")
+	tc := New(DefaultExpiration, 0, WithLoadRetry(RetryPolicy{
+		Attempts:   3,
+		Backoff:    time.Millisecond,
+		MaxBackoff: time.Millisecond,
+		Retryable: func(err error) bool {
+			return err == errBusy
+		},
+	}))
+
+	attempts := 0
+	x, err := tc.GetOrLoad("a", func(k interface{}) (interface{}, time.Duration, error) {
+		attempts++
+		if attempts < 3 {
+			return nil, 0, errBusy
+		}
+		return 1, DefaultExpiration, nil
+	})
+	if err != nil || x != 1 || attempts != 3 {
+		t.Errorf("GetOrLoad returned %v, %v after %d attempts; want 1 after 3", x, err, attempts)
+	}
+
+	attempts = 0
+	_, err = tc.GetOrLoad("b", func(k interface{}) (interface{}, time.Duration, error) {
+		attempts++
+		return nil, 0, errBusy
+	})
+	if err != errBusy || attempts != 3 {
+		t.Errorf("GetOrLoad returned %v after %d attempts; want %v after 3", err, attempts, errBusy)
+	}
+
+	attempts = 0
+	_, err = tc.GetOrLoad("c", func(k interface{}) (interface{}, time.Duration, error) {
+		attempts++
+		return nil, 0, errGone
+	})
+	if err != errGone || attempts != 1 {
+		t.Errorf("GetOrLoad returned %v after %d attempts; want %v after 1", err, attempts, errGone)
+	}
+	if s := tc.Stats(); s.Loads != 3 || s.LoadErrors != 2 {
+		t.Errorf("Stats counted %d loads and %d errors, want 3 and 2", s.Loads, s.LoadErrors)
+	}
+}
+
+func TestLoadRetryContext(t *testing.T) {
+	tc := New(DefaultExpiration, 0, WithLoadRetry(RetryPolicy{Attempts: 100, Backoff: time.Hour}))
+	ctx, cancel := context.WithCancel(context.Background())
+	errBusy := errors.New("busy")
+	attempts := 0
+	_, err := tc.GetOrLoadContext(ctx, "a", func(ctx context.Context, k interface{}) (interface{}, time.Duration, error) {
+		attempts++
+		cancel()
+		return nil, 0, errBusy
+	})
+	if err != errBusy || attempts != 1 {
+		t.Errorf("GetOrLoadContext returned %v after %d attempts; want %v after 1", err, attempts, errBusy)
+	}
+}
@@ -0,0 +1,278 @@
+package cache
+
+import (
+	"encoding/binary"
+	"hash/maphash"
+	"math"
+	"reflect"
+	"runtime"
+	"sync/atomic"
+)
+
+// WithShards splits the cache into n shards, each with its own lock, to reduce
+// lock contention under heavy concurrent use. Keys are assigned to shards by
+// hash. Operations on a single key only lock that key's shard, and most
+// operations on the whole cache visit the shards one after another, so that
+// for example Items and Keys are not a snapshot of the whole cache at one
+// point in time. Caches created with New and NewWithOptions have
+// runtime.GOMAXPROCS(0) shards by default. A cache with a limit set with
+// WithMaxEntries, WithMaxCost or WithMaxPerTag, an eviction policy or
+// WithWriteBehind keeps a single shard whatever n is, as these apply to the
+// whole cache at once. An n less than one means the default.
+func WithShards(n int) Option {
+	return func(c *cache) {
+		c.numShards = n
+	}
+}
+
+// The number of items in all shards of a cache, and the highest it has been.
+// Accessed atomically.
+type itemCount struct {
+	n    int64
+	peak int64
+}
+
+// Returns the number of shards the options ask for.
+func (c *cache) shardCount() int {
+	if c.maxEntries > 0 || c.maxCost > 0 || c.maxPerTag > 0 || c.newPolicy != nil || c.writeBehind != nil {
+		return 1
+	}
+	if c.numShards < 1 {
+		return runtime.GOMAXPROCS(0)
+	}
+	return c.numShards
+}
+
+// Split c into n shards configured with opts, moving its items into them. The
+// shards share c's events, hit ratio window, circuit breaker and item count,
+// and c keeps the janitor and the other goroutines that span them.
+func (c *cache) split(n int, opts []Option) {
+	c.seed = maphash.MakeSeed()
+	c.shards = make([]*cache, n)
+	c.count = &itemCount{}
+	items := make([]map[interface{}]Item, n)
+	for k, v := range c.items {
+		i := c.shardIndex(k)
+		if items[i] == nil {
+			items[i] = map[interface{}]Item{}
+		}
+		items[i][k] = v
+	}
+	c.items = map[interface{}]Item{}
+	c.coalescer = nil
+	uses := new(uint64)
+	opts = append(opts[:len(opts):len(opts)], WithShards(1))
+	for i := range c.shards {
+		if items[i] == nil {
+			items[i] = map[interface{}]Item{}
+		}
+		s := newCache(c.defaultExpiration, c.cleanupInterval, items[i], opts)
+		s.events = c.events
+		s.hitWindow = c.hitWindow
+		s.breaker = c.breaker
+		s.snapshotter = nil
+		s.access.uses = uses
+		s.count = c.count
+		s.recount()
+		c.shards[i] = s
+	}
+}
+
+// Returns the shards of the cache, or the cache itself if it isn't split.
+func (c *cache) parts() []*cache {
+	if c.shards == nil {
+		return []*cache{c}
+	}
+	return c.shards
+}
+
+// Returns the shard responsible for k, or c if it isn't split.
+func (c *cache) shard(k interface{}) *cache {
+	if c.shards == nil {
+		return c
+	}
+	return c.keyShard(c.key(k))
+}
+
+// Returns the shard responsible for k, a key already converted with the key
+// function set with WithKeyFunc, or c if it isn't split.
+func (c *cache) keyShard(k interface{}) *cache {
+	if c.shards == nil {
+		return c
+	}
+	return c.shards[c.shardIndex(k)]
+}
+
+func (c *cache) shardIndex(k interface{}) int {
+	return int(hashKey(c.seed, k) % uint64(len(c.shards)))
+}
+
+// Write-lock all shards for an operation that spans them, in order, so that
+// such operations can't deadlock. The returned function unlocks them, then
+// calls the eviction callbacks. The items evicted from any shard meanwhile are
+// queued on the first, so that the callbacks are called in the order the items
+// were evicted in.
+func (c *cache) lockAll() (unlock func()) {
+	if c.shards == nil {
+		c.Lock()
+		return c.unlock
+	}
+	first := c.shards[0]
+	for _, s := range c.shards {
+		s.Lock()
+		if s != first {
+			s.pendingIn = &first.pending
+		}
+	}
+	return func() {
+		notify := make([]func(), len(c.shards))
+		for i, s := range c.shards {
+			s.pendingIn = nil
+			notify[i] = s.release()
+		}
+		for _, f := range notify {
+			f()
+		}
+	}
+}
+
+// Groups keys by the shard responsible for them.
+func (c *cache) byShard(keys []interface{}) map[*cache][]interface{} {
+	byShard := map[*cache][]interface{}{}
+	for _, k := range keys {
+		s := c.shard(k)
+		byShard[s] = append(byShard[s], k)
+	}
+	return byShard
+}
+
+// Groups items, whose keys are already converted, by the shard responsible
+// for them.
+func (c *cache) itemsByShard(items map[interface{}]Item) map[*cache]map[interface{}]Item {
+	byShard := map[*cache]map[interface{}]Item{}
+	for k, v := range items {
+		s := c.keyShard(k)
+		if byShard[s] == nil {
+			byShard[s] = map[interface{}]Item{}
+		}
+		byShard[s][k] = v
+	}
+	return byShard
+}
+
+// Bring the item count shared by the shards up to date with the items of
+// this shard, and record the new count if it is the highest seen. Must be
+// called with the write lock held.
+func (c *cache) recount() {
+	n := atomic.AddInt64(&c.count.n, int64(len(c.items)-c.counted))
+	c.counted = len(c.items)
+	for {
+		peak := atomic.LoadInt64(&c.count.peak)
+		if n <= peak || atomic.CompareAndSwapInt64(&c.count.peak, peak, n) {
+			return
+		}
+	}
+}
+
+// Returns the shard holding the item to evict next and its key, picking from
+// the items pick returns for each shard the least recently used one, of the
+// lowest priority first if byPriority is set. Must be called with all shards
+// locked.
+func (c *cache) nextVictim(pick func(s *cache) (interface{}, bool), byPriority bool) (*cache, interface{}, bool) {
+	if c.shards == nil {
+		k, found := pick(c)
+		return c, k, found
+	}
+	var victim *cache
+	var key interface{}
+	var best accessEntry
+	for _, s := range c.shards {
+		k, found := pick(s)
+		if !found {
+			continue
+		}
+		e, _ := s.access.entry(k)
+		switch {
+		case victim == nil,
+			byPriority && e.priority < best.priority,
+			(!byPriority || e.priority == best.priority) && e.use < best.use:
+			victim, key, best = s, k, e
+		}
+	}
+	return victim, key, victim != nil
+}
+
+// Returns a hash of k that is equal for keys that are equal with ==.
+func hashKey(seed maphash.Seed, k interface{}) uint64 {
+	var h maphash.Hash
+	h.SetSeed(seed)
+	switch v := k.(type) {
+	case string:
+		h.WriteString(v)
+	case int:
+		writeUint64(&h, uint64(v))
+	case int64:
+		writeUint64(&h, uint64(v))
+	case uint64:
+		writeUint64(&h, v)
+	default:
+		writeValue(&h, reflect.ValueOf(k))
+	}
+	return h.Sum64()
+}
+
+// Write v to h so that values that are equal with == are written alike:
+// floats are normalised, so that 0 and -0 are written the same, and structs
+// and arrays are written field by field and element by element.
+func writeValue(h *maphash.Hash, v reflect.Value) {
+	switch v.Kind() {
+	case reflect.String:
+		writeUint64(h, uint64(v.Len()))
+		h.WriteString(v.String())
+	case reflect.Bool:
+		if v.Bool() {
+			h.WriteByte(1)
+		} else {
+			h.WriteByte(0)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		writeUint64(h, uint64(v.Int()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		writeUint64(h, v.Uint())
+	case reflect.Float32, reflect.Float64:
+		writeFloat(h, v.Float())
+	case reflect.Complex64, reflect.Complex128:
+		writeFloat(h, real(v.Complex()))
+		writeFloat(h, imag(v.Complex()))
+	case reflect.Ptr, reflect.Chan, reflect.UnsafePointer:
+		// Pointers are equal only if they point to the same thing,
+		// whatever it contains.
+		writeUint64(h, uint64(v.Pointer()))
+	case reflect.Interface:
+		if !v.IsNil() {
+			writeValue(h, v.Elem())
+		}
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			writeValue(h, v.Index(i))
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			writeValue(h, v.Field(i))
+		}
+	}
+	// Other kinds can't be compared, so they can't be keys.
+}
+
+func writeFloat(h *maphash.Hash, f float64) {
+	if f == 0 {
+		f = 0 // -0 == 0
+	}
+	writeUint64(h, math.Float64bits(f))
+}
+
+func writeUint64(h *maphash.Hash, v uint64) {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], v)
+	h.Write(buf[:])
+}
@@ -0,0 +1,483 @@
+package cache
+
+import (
+	"bytes"
+	"errors"
+	"hash/maphash"
+	"math"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+type shardKey struct {
+	A string
+	B int
+	P *int
+}
+
+type floatKey struct {
+	X float64
+	Y float32
+	I interface{}
+}
+
+func TestHashKey(t *testing.T) {
+	seed := maphash.MakeSeed()
+	n := 1
+	negZero := math.Copysign(0, -1)
+	equal := [][2]interface{}{
+		{"foo", "foo"},
+		{42, 42},
+		{0.0, -0.0},
+		{shardKey{"a", 1, &n}, shardKey{"a", 1, &n}},
+		{[2]string{"x", "y"}, [2]string{"x", "y"}},
+		{&n, &n},
+		{negZero, 0.0},
+		{float32(negZero), float32(0)},
+		{floatKey{negZero, float32(negZero), negZero}, floatKey{0, 0, 0.0}},
+		{[1]floatKey{{X: negZero}}, [1]floatKey{{X: 0}}},
+	}
+	for _, pair := range equal {
+		if pair[0] != pair[1] {
+			t.Fatalf("%#v != %#v", pair[0], pair[1])
+		}
+		if hashKey(seed, pair[0]) != hashKey(seed, pair[1]) {
+			t.Errorf("Equal keys %#v and %#v hash differently", pair[0], pair[1])
+		}
+	}
+
+	p := &n
+	before := hashKey(seed, p)
+	n++
+	if hashKey(seed, p) != before {
+		t.Error("Hash of a pointer changed with the value it points to")
+	}
+}
+
+func TestShardedCache(t *testing.T) {
+	tc := New(DefaultExpiration, 0, WithShards(8))
+	for i := 0; i < 1000; i++ {
+		tc.Set(i, i, DefaultExpiration)
+		tc.Set(strconv.Itoa(i), i, DefaultExpiration)
+	}
+	if n := tc.ItemCount(); n != 2000 {
+		t.Errorf("Item count is %d, want 2000", n)
+	}
+	for i, c := range tc.shards {
+		if c.ItemCount() == 0 {
+			t.Errorf("Shard %d is empty", i)
+		}
+	}
+	for i := 0; i < 1000; i++ {
+		if x, found := tc.Get(strconv.Itoa(i)); !found || x.(int) != i {
+			t.Fatalf("Got %v, %v for %d", x, found, i)
+		}
+	}
+
+	n := 1
+	tc.Set(shardKey{"a", 1, &n}, "struct", DefaultExpiration)
+	if x, found := tc.Get(shardKey{"a", 1, &n}); !found || x != "struct" {
+		t.Errorf("Got %v, %v for a struct key", x, found)
+	}
+	if err := tc.Add(5, 5, DefaultExpiration); err == nil {
+		t.Error("Added an existing key")
+	}
+	x, err := tc.GetOrLoad("new", func(k interface{}) (interface{}, time.Duration, error) {
+		return "loaded", DefaultExpiration, nil
+	})
+	if err != nil || x != "loaded" {
+		t.Errorf("GetOrLoad returned %v, %v", x, err)
+	}
+
+	evicted := 0
+	tc.OnEvicted(func(k interface{}, v interface{}) {
+		evicted++
+	})
+	tc.Delete(5)
+	if _, found := tc.Get(5); found || evicted != 1 {
+		t.Errorf("Found 5 after deleting it, or evicted %d items", evicted)
+	}
+	tc.Flush()
+	if n := tc.ItemCount(); n != 0 {
+		t.Errorf("Item count after Flush is %d", n)
+	}
+}
+
+func TestShardedCacheJanitor(t *testing.T) {
+	tc := New(DefaultExpiration, time.Millisecond, WithShards(4))
+	defer tc.Close()
+	for i := 0; i < 100; i++ {
+		tc.Set(i, i, 5*time.Millisecond)
+	}
+	tc.Set("forever", 1, NoExpiration)
+	<-time.After(20 * time.Millisecond)
+	if n := tc.ItemCount(); n != 1 {
+		t.Errorf("Item count is %d after the items expired, want 1", n)
+	}
+}
+
+func BenchmarkShardedCacheGetExpiring(b *testing.B) {
+	benchmarkShardedCacheGet(b, 5*time.Minute)
+}
+
+func BenchmarkShardedCacheGetNotExpiring(b *testing.B) {
+	benchmarkShardedCacheGet(b, NoExpiration)
+}
+
+func benchmarkShardedCacheGet(b *testing.B, exp time.Duration) {
+	b.StopTimer()
+	tc := New(exp, 0, WithShards(10))
+	tc.Set("foobarba", "zquux", DefaultExpiration)
+	b.StartTimer()
+	for i := 0; i < b.N; i++ {
+		tc.Get("foobarba")
+	}
+}
+
+func BenchmarkShardedCacheGetManyConcurrentExpiring(b *testing.B) {
+	benchmarkShardedCacheGetManyConcurrent(b, 5*time.Minute)
+}
+
+func BenchmarkShardedCacheGetManyConcurrentNotExpiring(b *testing.B) {
+	benchmarkShardedCacheGetManyConcurrent(b, NoExpiration)
+}
+
+func benchmarkShardedCacheGetManyConcurrent(b *testing.B, exp time.Duration) {
+	b.StopTimer()
+	n := 10000
+	tsc := New(exp, 0, WithShards(20))
+	keys := make([]string, n)
+	for i := 0; i < n; i++ {
+		k := "foo" + strconv.Itoa(i)
+		keys[i] = k
+		tsc.Set(k, "bar", DefaultExpiration)
+	}
+	each := b.N / n
+	wg := new(sync.WaitGroup)
+	wg.Add(n)
+	for _, v := range keys {
+		go func(v string) {
+			for j := 0; j < each; j++ {
+				tsc.Get(v)
+			}
+			wg.Done()
+		}(v)
+	}
+	b.StartTimer()
+	wg.Wait()
+}
+
+func TestShardedAutoSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.snapshot")
+	sc := New(DefaultExpiration, 0, WithShards(4), WithAutoSnapshot(path, time.Hour))
+	for i := 0; i < 10; i++ {
+		sc.Set(i, i, DefaultExpiration)
+	}
+	sc.Close()
+	oc, err := NewFromSnapshot(path, DefaultExpiration, 0, WithShards(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := oc.ItemCount(); n != 10 {
+		t.Errorf("Loaded %d items from the snapshot, want 10", n)
+	}
+}
+
+func TestShardedLimits(t *testing.T) {
+	for name, opt := range map[string]Option{
+		"WithMaxEntries":  WithMaxEntries(10),
+		"WithMaxCost":     WithMaxCost(10),
+		"WithMaxPerTag":   WithMaxPerTag(10),
+		"WithLFUEviction": WithLFUEviction(),
+	} {
+		tc := New(DefaultExpiration, 0, WithShards(4), opt)
+		if tc.shards != nil {
+			t.Errorf("A cache with %s was split into shards", name)
+		}
+	}
+	tc := New(DefaultExpiration, 0, WithShards(4), WithMaxEntries(10))
+	for i := 0; i < 100; i++ {
+		tc.Set(i, i, DefaultExpiration)
+	}
+	if n := tc.ItemCount(); n != 10 {
+		t.Errorf("Item count is %d, want 10", n)
+	}
+}
+
+func TestShardedPeakItemCount(t *testing.T) {
+	tc := New(DefaultExpiration, 0, WithShards(8))
+	for i := 0; i < 100; i++ {
+		tc.Set(i, i, DefaultExpiration)
+	}
+	for i := 0; i < 60; i++ {
+		tc.Delete(i)
+	}
+	for i := 0; i < 20; i++ {
+		tc.Set(i, i, DefaultExpiration)
+	}
+	if n := tc.PeakItemCount(); n != 100 {
+		t.Errorf("Peak item count is %d, want 100", n)
+	}
+	tc.ResetPeakItemCount()
+	if n := tc.PeakItemCount(); n != 60 {
+		t.Errorf("Peak item count after reset is %d, want 60", n)
+	}
+	tc.Flush()
+	tc.Set("a", 1, DefaultExpiration)
+	if n := tc.PeakItemCount(); n != 60 {
+		t.Errorf("Peak item count after Flush is %d, want 60", n)
+	}
+}
+
+func TestShardedStopJanitor(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	sc := New(time.Minute, time.Minute, WithShards(4), WithClock(clock))
+	for i := 0; i < 10; i++ {
+		sc.Set(i, i, DefaultExpiration)
+	}
+	sc.StopJanitor()
+	clock.Advance(time.Hour)
+	if n := sc.ItemCount(); n != 10 {
+		t.Errorf("Item count after stopping the janitor is %d, want 10", n)
+	}
+	sc.Close()
+	sc.StopJanitor()
+}
+
+func TestShardedAdaptiveCleanup(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	sc := New(DefaultExpiration, time.Minute, WithShards(4), WithClock(clock), WithAdaptiveCleanup(time.Second, time.Hour))
+	defer sc.Close()
+	for i := 0; i < 10; i++ {
+		sc.Set(i, i, time.Duration(i+5)*time.Minute)
+	}
+	clock.Advance(time.Minute)
+	if !waitPeriod(clock, sc.janitor.ticker, 4*time.Minute) {
+		t.Fatal("Interval is not the 4m until the first item expires")
+	}
+}
+
+func TestShardedCacheAPI(t *testing.T) {
+	tc := New(DefaultExpiration, 0, WithShards(8))
+	for i := 0; i < 100; i++ {
+		tc.Set(i, i, DefaultExpiration)
+	}
+	if n := len(tc.Items()); n != 100 {
+		t.Errorf("Items returned %d items, want 100", n)
+	}
+	seen := 0
+	tc.Range(func(k, v interface{}) bool {
+		seen++
+		return seen < 10
+	})
+	if seen != 10 {
+		t.Errorf("Range called f %d times after it returned false, want 10", seen)
+	}
+	if n, sample := tc.CountAndSample(5); n != 100 || len(sample) != 5 {
+		t.Errorf("CountAndSample returned %d, %d items, want 100, 5", n, len(sample))
+	}
+	if items := tc.SortedItems(func(a, b interface{}) bool { return a.(int) < b.(int) }); len(items) != 100 || items[42].Key != 42 {
+		t.Errorf("SortedItems returned %v", items)
+	}
+
+	if err := tc.Increment(1, 10); err != nil {
+		t.Fatal(err)
+	}
+	if x, _ := tc.Get(1); x != 11 {
+		t.Errorf("Incremented value is %v, want 11", x)
+	}
+	if !tc.Touch(2, time.Hour) {
+		t.Error("Couldn't touch 2")
+	}
+	if d, found := tc.TTL(2); !found || d <= 0 || d > time.Hour {
+		t.Errorf("TTL of 2 is %v, %v", d, found)
+	}
+	if r, found := tc.Inspect(3); !found || r.Value != 3 {
+		t.Errorf("Inspect(3) returned %v, %v", r, found)
+	}
+	if x, found := tc.Pop(4); !found || x != 4 {
+		t.Errorf("Pop(4) returned %v, %v", x, found)
+	}
+	if _, found := tc.Get(4); found {
+		t.Error("Popped item is still there")
+	}
+	if !tc.CompareAndSwap(5, 5, 50, DefaultExpiration) {
+		t.Error("CompareAndSwap failed")
+	}
+	if taken := tc.TakeN(3, func(k, v interface{}) bool { return k.(int) < 50 }); len(taken) != 3 {
+		t.Errorf("TakeN took %d items, want 3", len(taken))
+	}
+	if n := tc.DeleteWhere(func(k, v interface{}) bool { return k.(int) >= 90 }); n != 10 {
+		t.Errorf("DeleteWhere deleted %d items, want 10", n)
+	}
+	if err := tc.ConsistencyCheck(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestShardedRenamePrefix(t *testing.T) {
+	tc := New(DefaultExpiration, 0, WithShards(8))
+	for i := 0; i < 100; i++ {
+		tc.SetWithTags("old:"+strconv.Itoa(i), i, time.Hour, "tag")
+	}
+	tc.Pin("old:7")
+	if n := tc.RenamePrefix("old:", "new:"); n != 100 {
+		t.Errorf("Moved %d items, want 100", n)
+	}
+	if keys := tc.KeysWithPrefix("old:"); len(keys) != 0 {
+		t.Errorf("Items left under the old prefix: %v", keys)
+	}
+	for i := 0; i < 100; i++ {
+		if x, found := tc.Get("new:" + strconv.Itoa(i)); !found || x != i {
+			t.Fatalf("Got %v, %v for new:%d", x, found, i)
+		}
+	}
+	if d, _ := tc.TTL("new:7"); d != NoExpiration {
+		t.Error("Moved item lost its pin")
+	}
+	if err := tc.ConsistencyCheck(); err != nil {
+		t.Error(err)
+	}
+	if n := tc.DeleteByTag("tag"); n != 100 {
+		t.Errorf("Deleted %d tagged items, want 100", n)
+	}
+}
+
+func TestShardedManyKeys(t *testing.T) {
+	tc := New(DefaultExpiration, 0, WithShards(8))
+	tc.Set("a", 1, DefaultExpiration)
+	tc.Set("b", "x", DefaultExpiration)
+	err := tc.IncrementMany(map[interface{}]int64{"a": 1, "b": 1, "c": 1}, DefaultExpiration)
+	if !errors.Is(err, ErrNotNumeric) {
+		t.Errorf("IncrementMany returned %v, want ErrNotNumeric", err)
+	}
+	if x, _ := tc.Get("a"); x != 1 {
+		t.Errorf("a is %v after a failed IncrementMany, want 1", x)
+	}
+	if _, found := tc.Get("c"); found {
+		t.Error("c was set by a failed IncrementMany")
+	}
+
+	tc.UpdateMany([]interface{}{"a", "b"}, func(current map[interface{}]interface{}) map[interface{}]interface{} {
+		return map[interface{}]interface{}{"a": current["b"], "d": current["a"]}
+	}, DefaultExpiration)
+	if x, _ := tc.Get("a"); x != "x" {
+		t.Errorf("a is %v, want x", x)
+	}
+	if x, _ := tc.Get("d"); x != 1 {
+		t.Errorf("d is %v, want 1", x)
+	}
+	if _, found := tc.Get("b"); found {
+		t.Error("b wasn't deleted")
+	}
+	tc.DeleteMany([]interface{}{"a", "d"})
+	if n := tc.ItemCount(); n != 0 {
+		t.Errorf("%d items left, want 0", n)
+	}
+}
+
+func TestShardedEvictLRU(t *testing.T) {
+	tc := New(DefaultExpiration, 0, WithShards(4), WithAccessTracking())
+	for i := 0; i < 10; i++ {
+		tc.Set(i, i, DefaultExpiration)
+	}
+	tc.Get(0)
+	if n := tc.EvictLRU(6); n != 6 {
+		t.Errorf("Evicted %d items, want 6", n)
+	}
+	for _, k := range []int{0, 7, 8, 9} {
+		if _, found := tc.Get(k); !found {
+			t.Errorf("%d, one of the most recently used items, was evicted", k)
+		}
+	}
+	if n := tc.EvictLRU(10); n != 4 {
+		t.Errorf("Evicted %d items, want 4", n)
+	}
+
+	for i := 0; i < 10; i++ {
+		tc.Set(i, i, DefaultExpiration)
+	}
+	tc.SetWithPriority("low", 1, PriorityLow, DefaultExpiration)
+	tc.OnMemoryPressure(func() int { return 3 })
+	if n := tc.TriggerMemoryPressure(); n != 3 {
+		t.Errorf("TriggerMemoryPressure evicted %d items, want 3", n)
+	}
+	if _, found := tc.Get("low"); found {
+		t.Error("The item of low priority wasn't evicted first")
+	}
+	for _, k := range []int{0, 1} {
+		if _, found := tc.Get(k); found {
+			t.Errorf("%d, one of the least recently used items, wasn't evicted", k)
+		}
+	}
+	tc.OnMemoryPressure(func() int { return 20 })
+	if n := tc.TriggerMemoryPressure(); n != 8 {
+		t.Errorf("TriggerMemoryPressure evicted %d items, want 8", n)
+	}
+}
+
+func TestShardedEvents(t *testing.T) {
+	tc := New(DefaultExpiration, 0, WithShards(8))
+	events, stop := tc.Events(100)
+	for i := 0; i < 10; i++ {
+		tc.Set(i, i, DefaultExpiration)
+	}
+	stop()
+	n := 0
+	for e := range events {
+		if e.Op != EventSet {
+			t.Errorf("Got %v event, want set", e.Op)
+		}
+		n++
+	}
+	if n != 10 {
+		t.Errorf("Got %d events, want 10", n)
+	}
+	stop()
+}
+
+func TestShardedSaveLoad(t *testing.T) {
+	tc := New(DefaultExpiration, 0, WithShards(8))
+	for i := 0; i < 100; i++ {
+		tc.Set(strconv.Itoa(i), i, DefaultExpiration)
+	}
+	var buf bytes.Buffer
+	if err := tc.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	oc := New(DefaultExpiration, 0, WithShards(3))
+	if err := oc.Load(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if n := oc.ItemCount(); n != 100 {
+		t.Errorf("Loaded %d items, want 100", n)
+	}
+	if err := oc.ConsistencyCheck(); err != nil {
+		t.Error(err)
+	}
+
+	even := tc.Partition(func(k, v interface{}) bool { return v.(int)%2 == 0 })
+	defer even.Close()
+	if n := even.ItemCount(); n != 50 {
+		t.Errorf("Partition holds %d items, want 50", n)
+	}
+	if err := even.ConsistencyCheck(); err != nil {
+		t.Error(err)
+	}
+	even.Set("0", -1, DefaultExpiration)
+	changed := tc.Merge(even, func(key, mine, theirs interface{}, mineExp, theirsExp time.Time) (interface{}, time.Time) {
+		return theirs, theirsExp
+	})
+	if changed != 1 {
+		t.Errorf("Merge changed %d items, want 1", changed)
+	}
+
+	ns := tc.Namespace("ns")
+	ns.Set("a", 1, DefaultExpiration)
+	if x, found := tc.Get("ns:a"); !found || x != 1 {
+		t.Errorf("Got %v, %v for ns:a", x, found)
+	}
+}
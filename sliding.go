@@ -0,0 +1,24 @@
+package cache
+
+// WithSlidingExpiration makes every Get that finds an item reset its
+// expiration to the default expiration from now, as GetAndExtend does, so that
+// items expire once they haven't been read for that long. Items stored with
+// NoExpiration, and all items if the default expiration is NoExpiration, are
+// not affected. Other lookups don't extend items. A Get that extends an item
+// takes the cache's write lock.
+func WithSlidingExpiration() Option {
+	return func(c *cache) {
+		c.sliding = true
+	}
+}
+
+// Reset the expiration of the item under k to the default expiration from now,
+// if it is still unexpired and has an expiration.
+func (c *cache) slide(k interface{}) {
+	c.Lock()
+	defer c.unlock()
+
+	if item, found := c.get(k); found && item.Expiration > 0 {
+		c.extend(k, item, DefaultExpiration)
+	}
+}
@@ -0,0 +1,43 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSlidingExpiration(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	tc := New(time.Minute, 0, WithClock(clock), WithSlidingExpiration())
+	tc.Set("a", 1, DefaultExpiration)
+	tc.Set("b", 2, DefaultExpiration)
+	tc.Set("c", 3, NoExpiration)
+
+	for i := 0; i < 5; i++ {
+		clock.Advance(40 * time.Second)
+		if _, found := tc.Get("a"); !found {
+			t.Fatalf("a expired after %d reads", i)
+		}
+	}
+	if _, found := tc.Get("b"); found {
+		t.Error("Found b, which was never read")
+	}
+	if ttl, _ := tc.TTL("a"); ttl != time.Minute {
+		t.Errorf("TTL of a after a read is %v, want 1m", ttl)
+	}
+	tc.Get("c")
+	if ttl, _ := tc.TTL("c"); ttl != NoExpiration {
+		t.Errorf("TTL of c is %v, want NoExpiration", ttl)
+	}
+}
+
+func TestSlidingExpirationWithoutDefault(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	tc := New(NoExpiration, 0, WithClock(clock), WithSlidingExpiration())
+	tc.Set("a", 1, time.Minute)
+	clock.Advance(40 * time.Second)
+	tc.Get("a")
+	clock.Advance(40 * time.Second)
+	if _, found := tc.Get("a"); found {
+		t.Error("a was extended without a default expiration")
+	}
+}
@@ -0,0 +1,99 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// WithAutoSnapshot saves the cache's unexpired items to path every interval,
+// and once more when the cache is closed. Each snapshot is written to a
+// temporary file in the same directory which is then renamed to path, so path
+// always holds a complete snapshot. A snapshot that fails to be written is
+// retried at the next interval; call Snapshot to write one and see the error.
+// Use NewFromSnapshot to create a cache from the file. The interval must be
+// greater than zero.
+func WithAutoSnapshot(path string, interval time.Duration) Option {
+	return func(c *cache) {
+		c.snapshotter = &snapshotter{
+			path:     path,
+			interval: interval,
+			stop:     make(chan struct{}),
+			done:     make(chan struct{}),
+		}
+	}
+}
+
+type snapshotter struct {
+	path     string
+	interval time.Duration
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+func (s *snapshotter) run(c *cache) {
+	defer close(s.done)
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.Snapshot()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// Snapshot saves the cache's unexpired items to the file given to
+// WithAutoSnapshot right away. It does nothing if the cache wasn't created
+// with WithAutoSnapshot.
+func (c *cache) Snapshot() error {
+	if c.snapshotter == nil {
+		return nil
+	}
+	return c.saveFileAtomic(c.snapshotter.path)
+}
+
+// Save the cache to a temporary file next to fname, then rename it to fname.
+func (c *cache) saveFileAtomic(fname string) error {
+	fp, err := os.CreateTemp(filepath.Dir(fname), filepath.Base(fname)+".tmp*")
+	if err != nil {
+		return err
+	}
+	tmp := fp.Name()
+	err = c.Save(fp)
+	if err == nil {
+		err = fp.Sync()
+	}
+	if cerr := fp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, fname)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
+
+// Stop taking periodic snapshots and take a final one.
+func (c *cache) stopSnapshots() {
+	close(c.snapshotter.stop)
+	<-c.snapshotter.done
+	c.Snapshot()
+}
+
+// NewFromSnapshot returns a new cache like New, filled with the unexpired
+// items saved in the file at path, e.g. by WithAutoSnapshot. A missing file is
+// not an error: the cache starts out empty. The items are loaded before any
+// snapshot is taken, so it is safe to pass WithAutoSnapshot(path, ...)
+// among opts to keep the same file up to date.
+func NewFromSnapshot(path string, defaultExpiration, cleanupInterval time.Duration, opts ...Option) (*Cache, error) {
+	c := newCache(defaultExpiration, cleanupInterval, map[interface{}]Item{}, opts)
+	if err := c.LoadFile(path); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return startCache(c), nil
+}
@@ -0,0 +1,51 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAutoSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.snapshot")
+
+	tc, err := NewFromSnapshot(path, DefaultExpiration, 0, WithAutoSnapshot(path, 10*time.Millisecond))
+	if err != nil {
+		t.Fatal("Couldn't create cache without a snapshot:", err)
+	}
+	tc.Set("a", 1, DefaultExpiration)
+	<-time.After(50 * time.Millisecond)
+	if _, err := os.Stat(path); err != nil {
+		t.Fatal("No snapshot was taken:", err)
+	}
+
+	tc.Set("b", 2, DefaultExpiration)
+	tc.Close()
+
+	oc, err := NewFromSnapshot(path, DefaultExpiration, 0)
+	if err != nil {
+		t.Fatal("Couldn't create cache from snapshot:", err)
+	}
+	defer oc.Close()
+	for k, want := range map[string]int{"a": 1, "b": 2} {
+		if x, found := oc.Get(k); !found || x != want {
+			t.Errorf("%s is %v, want %d", k, x, want)
+		}
+	}
+
+	matches, _ := filepath.Glob(path + ".tmp*")
+	if len(matches) != 0 {
+		t.Error("temporary snapshot files were left behind:", matches)
+	}
+}
+
+func TestNewFromSnapshotInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.snapshot")
+	if err := os.WriteFile(path, []byte("not a snapshot"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewFromSnapshot(path, DefaultExpiration, 0); err == nil {
+		t.Error("NewFromSnapshot succeeded with an invalid snapshot")
+	}
+}
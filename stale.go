@@ -0,0 +1,42 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// WithStaleWhileRevalidate makes GetOrLoad and GetOrLoadContext return the
+// value of an item that expired at most maxStale ago, instead of waiting for
+// it to be loaded, and reload it in the background. Until the new value is
+// stored, later calls return the stale value too, without starting another
+// load. If the load fails, the stale value keeps being served until it is
+// more than maxStale out of date. Background loads get a context that is never
+// done. Stale values are only served while the item is still in the cache:
+// the janitor, DeleteExpired and OnExpired delete expired items as usual, so
+// use a cleanup interval longer than maxStale to keep them around. Other
+// lookups never return stale values.
+func WithStaleWhileRevalidate(maxStale time.Duration) Option {
+	return func(c *cache) {
+		c.maxStale = maxStale
+	}
+}
+
+// If the item under key has expired, but by no more than the cache's maxStale,
+// start reloading it with load in the background, unless it is already being
+// loaded, and return its value and true. Must be called with c locked.
+func (c *cache) serveStale(key interface{}, load func() (interface{}, time.Duration, error)) (interface{}, bool) {
+	if c.maxStale <= 0 {
+		return nil, false
+	}
+	item, found := c.items[key]
+	if !found || item.Expiration <= 0 || c.now().UnixNano()-item.Expiration > int64(c.maxStale) {
+		return nil, false
+	}
+	c.recordLookup(true)
+	c.access.used(key)
+	if _, loading := c.loads[key]; !loading && (c.breaker == nil || c.breaker.allow(c.now().UnixNano())) {
+		call := c.startLoad(key)
+		go c.finishLoad(context.Background(), key, call, load)
+	}
+	return item.Object, true
+}
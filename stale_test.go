@@ -0,0 +1,82 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStaleWhileRevalidate(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	tc := New(time.Minute, 0, WithClock(clock), WithStaleWhileRevalidate(time.Minute))
+	tc.Set("a", "old", DefaultExpiration)
+	clock.Advance(90 * time.Second)
+
+	release := make(chan struct{})
+	loads := make(chan struct{}, 10)
+	load := func(k interface{}) (interface{}, time.Duration, error) {
+		loads <- struct{}{}
+		<-release
+		return "new", DefaultExpiration, nil
+	}
+	for i := 0; i < 3; i++ {
+		x, err := tc.GetOrLoad("a", load)
+		if err != nil || x != "old" {
+			t.Fatalf("GetOrLoad returned %v, %v; want the stale value", x, err)
+		}
+	}
+	<-loads
+	close(release)
+	for {
+		if x, found := tc.Get("a"); found {
+			if x != "new" {
+				t.Fatalf("Refreshed value is %v, want new", x)
+			}
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if len(loads) != 0 {
+		t.Errorf("Loaded %d more times, want once", len(loads))
+	}
+}
+
+func TestStaleWhileRevalidateTooStale(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	tc := New(time.Minute, 0, WithClock(clock), WithStaleWhileRevalidate(time.Minute))
+	tc.Set("a", "old", DefaultExpiration)
+	clock.Advance(3 * time.Minute)
+
+	x, err := tc.GetOrLoad("a", func(k interface{}) (interface{}, time.Duration, error) {
+		return "new", DefaultExpiration, nil
+	})
+	if err != nil || x != "new" {
+		t.Errorf("GetOrLoad returned %v, %v; want a fresh load", x, err)
+	}
+}
+
+func TestStaleWhileRevalidateError(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	tc := New(time.Minute, 0, WithClock(clock), WithStaleWhileRevalidate(time.Minute))
+	tc.Set("a", "old", DefaultExpiration)
+	clock.Advance(90 * time.Second)
+
+	done := make(chan struct{})
+	x, err := tc.GetOrLoad("a", func(k interface{}) (interface{}, time.Duration, error) {
+		defer close(done)
+		return nil, 0, errors.New("unavailable")
+	})
+	if err != nil || x != "old" {
+		t.Fatalf("GetOrLoad returned %v, %v; want the stale value", x, err)
+	}
+	<-done
+	for tc.Stats().LoadErrors == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	x, _ = tc.GetOrLoad("a", func(k interface{}) (interface{}, time.Duration, error) {
+		return "new", DefaultExpiration, nil
+	})
+	if x != "old" {
+		t.Errorf("GetOrLoad after a failed refresh returned %v, want old", x)
+	}
+}
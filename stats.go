@@ -0,0 +1,140 @@
+package cache
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Stats holds counts of the operations performed on a cache since it was
+// created or ResetStats was last called.
+type Stats struct {
+	// Lookups that found an unexpired item: Get, GetAndExtend and the
+	// GetOrLoad variants.
+	Hits uint64
+	// Lookups that didn't find an unexpired item.
+	Misses uint64
+	// Items stored, including by loaders and increments.
+	Sets uint64
+	// Items removed with Delete, Flush and similar methods.
+	Deletes uint64
+	// Expired items removed from the cache.
+	Expirations uint64
+	// Items evicted to make room for other items.
+	Evictions uint64
+	// New items not stored because the filter set with
+	// WithTinyLFUAdmission rejected them.
+	Rejections uint64
+	// Calls to loaders by the GetOrLoad variants, how many of them returned
+	// an error, and the total time spent in them.
+	Loads      uint64
+	LoadErrors uint64
+	LoadTime   time.Duration
+	// The number of items in the cache, as returned by ItemCount.
+	Items int
+}
+
+// HitRatio returns the fraction of lookups that were hits, or 0 if there were
+// no lookups.
+func (s Stats) HitRatio() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(total)
+}
+
+// The counters behind Stats. They are updated atomically because lookups only
+// hold the read lock.
+type statCounters struct {
+	hits        uint64
+	misses      uint64
+	sets        uint64
+	deletes     uint64
+	expirations uint64
+	evictions   uint64
+	rejections  uint64
+	loads       uint64
+	loadErrors  uint64
+	loadNanos   uint64
+}
+
+// Count the removal of an item by an operation.
+func (s *statCounters) removed(op EventOp) {
+	switch op {
+	case EventDelete, EventFlush:
+		atomic.AddUint64(&s.deletes, 1)
+	case EventExpire:
+		atomic.AddUint64(&s.expirations, 1)
+	case EventEvict:
+		atomic.AddUint64(&s.evictions, 1)
+	}
+}
+
+// Count a call to a loader that took d and returned err.
+func (s *statCounters) loaded(d time.Duration, err error) {
+	atomic.AddUint64(&s.loads, 1)
+	if err != nil {
+		atomic.AddUint64(&s.loadErrors, 1)
+	}
+	atomic.AddUint64(&s.loadNanos, uint64(d))
+}
+
+// Returns the cache's statistics. Counters are read one at a time, so a
+// snapshot taken while the cache is in use may not be exactly consistent.
+func (c *cache) Stats() Stats {
+	total := c.stats.read()
+	for _, s := range c.shards {
+		total.add(s.stats.read())
+	}
+	total.Items = c.ItemCount()
+	return total
+}
+
+// Returns the counters as Stats, without the item count.
+func (s *statCounters) read() Stats {
+	return Stats{
+		Hits:        atomic.LoadUint64(&s.hits),
+		Misses:      atomic.LoadUint64(&s.misses),
+		Sets:        atomic.LoadUint64(&s.sets),
+		Deletes:     atomic.LoadUint64(&s.deletes),
+		Expirations: atomic.LoadUint64(&s.expirations),
+		Evictions:   atomic.LoadUint64(&s.evictions),
+		Rejections:  atomic.LoadUint64(&s.rejections),
+		Loads:       atomic.LoadUint64(&s.loads),
+		LoadErrors:  atomic.LoadUint64(&s.loadErrors),
+		LoadTime:    time.Duration(atomic.LoadUint64(&s.loadNanos)),
+	}
+}
+
+// Add the counts of o, such as those of another shard, to s.
+func (s *Stats) add(o Stats) {
+	s.Hits += o.Hits
+	s.Misses += o.Misses
+	s.Sets += o.Sets
+	s.Deletes += o.Deletes
+	s.Expirations += o.Expirations
+	s.Evictions += o.Evictions
+	s.Rejections += o.Rejections
+	s.Loads += o.Loads
+	s.LoadErrors += o.LoadErrors
+	s.LoadTime += o.LoadTime
+	s.Items += o.Items
+}
+
+// Reset all counters returned by Stats to zero.
+func (c *cache) ResetStats() {
+	for _, s := range c.shards {
+		s.ResetStats()
+	}
+	s := &c.stats
+	atomic.StoreUint64(&s.hits, 0)
+	atomic.StoreUint64(&s.misses, 0)
+	atomic.StoreUint64(&s.sets, 0)
+	atomic.StoreUint64(&s.deletes, 0)
+	atomic.StoreUint64(&s.expirations, 0)
+	atomic.StoreUint64(&s.evictions, 0)
+	atomic.StoreUint64(&s.rejections, 0)
+	atomic.StoreUint64(&s.loads, 0)
+	atomic.StoreUint64(&s.loadErrors, 0)
+	atomic.StoreUint64(&s.loadNanos, 0)
+}
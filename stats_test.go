@@ -0,0 +1,100 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	tc := New(DefaultExpiration, 0, WithMaxEntries(2))
+	tc.Set("a", 1, DefaultExpiration)
+	tc.Set("b", 2, DefaultExpiration)
+	tc.Get("a")
+	tc.Get("a")
+	tc.Get("x")
+	tc.Set("c", 3, DefaultExpiration) // evicts b
+	tc.Delete("a")
+	tc.Delete("a")
+	tc.Set("d", 4, time.Millisecond)
+	<-time.After(5 * time.Millisecond)
+	tc.DeleteExpired()
+
+	want := Stats{
+		Hits:        2,
+		Misses:      1,
+		Sets:        4,
+		Deletes:     1,
+		Expirations: 1,
+		Evictions:   1,
+		Items:       1,
+	}
+	if got := tc.Stats(); got != want {
+		t.Errorf("Stats is %+v, want %+v", got, want)
+	}
+	if r := tc.Stats().HitRatio(); r != 2.0/3 {
+		t.Errorf("HitRatio is %v, want %v", r, 2.0/3)
+	}
+
+	tc.ResetStats()
+	if got := tc.Stats(); got != (Stats{Items: 1}) {
+		t.Errorf("Stats after ResetStats is %+v", got)
+	}
+}
+
+func TestShardedStats(t *testing.T) {
+	sc := New(DefaultExpiration, 0, WithShards(4))
+	for i := 0; i < 10; i++ {
+		sc.Set(i, i, DefaultExpiration)
+		sc.Get(i)
+	}
+	sc.Get("missing")
+	got := sc.Stats()
+	if got.Sets != 10 || got.Hits != 10 || got.Misses != 1 || got.Items != 10 {
+		t.Errorf("Stats is %+v", got)
+	}
+}
+
+func TestLoadStats(t *testing.T) {
+	tc := New(DefaultExpiration, 0)
+	tc.GetOrLoad("a", func(k interface{}) (interface{}, time.Duration, error) {
+		<-time.After(5 * time.Millisecond)
+		return 1, DefaultExpiration, nil
+	})
+	tc.GetOrLoad("b", func(k interface{}) (interface{}, time.Duration, error) {
+		return nil, 0, errors.New("failed")
+	})
+	tc.GetOrLoad("a", nil)
+
+	s := tc.Stats()
+	if s.Loads != 2 || s.LoadErrors != 1 {
+		t.Errorf("Loads is %d and LoadErrors %d, want 2 and 1", s.Loads, s.LoadErrors)
+	}
+	if s.LoadTime < 5*time.Millisecond {
+		t.Errorf("LoadTime is %v, want at least 5ms", s.LoadTime)
+	}
+}
+
+func TestPeakItemCount(t *testing.T) {
+	tc := New(DefaultExpiration, 0)
+	for i := 0; i < 10; i++ {
+		tc.Set(i, i, DefaultExpiration)
+	}
+	for i := 0; i < 8; i++ {
+		tc.Delete(i)
+	}
+	tc.Set(0, 0, DefaultExpiration)
+	if n := tc.PeakItemCount(); n != 10 {
+		t.Errorf("Peak item count is %d, want 10", n)
+	}
+
+	tc.ResetPeakItemCount()
+	if n := tc.PeakItemCount(); n != 3 {
+		t.Errorf("Peak item count after reset is %d, want 3", n)
+	}
+	tc.Set(20, 20, DefaultExpiration)
+	tc.Delete(20)
+	if n := tc.PeakItemCount(); n != 4 {
+		t.Errorf("Peak item count is %d, want 4", n)
+	}
+}
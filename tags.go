@@ -0,0 +1,113 @@
+package cache
+
+import (
+	"container/list"
+	"time"
+)
+
+// WithMaxPerTag limits the number of items carrying any one tag to max. When
+// SetWithTags would exceed the limit, the item that was tagged longest ago is
+// evicted. A max less than one means no limit.
+func WithMaxPerTag(max int) Option {
+	return func(c *cache) {
+		c.maxPerTag = max
+	}
+}
+
+// Tag index: the keys carrying each tag in the order they were set, and the
+// position of each tagged key in its tags' lists.
+type tagIndex struct {
+	tags   map[string]*list.List
+	tagged map[interface{}]map[string]*list.Element
+}
+
+// SetWithTags adds an item to the cache like Set, and associates it with the
+// given tags so it can be removed with DeleteByTag. Storing the key again with
+// Set or SetWithTags replaces its tags.
+func (c *cache) SetWithTags(k interface{}, x interface{}, d time.Duration, tags ...string) {
+	c = c.shard(k)
+	k = c.key(k)
+	c.Lock()
+	defer c.unlock()
+
+	if !c.set(k, x, d) {
+		return
+	}
+	for _, tag := range tags {
+		c.tag(k, tag)
+	}
+}
+
+// DeleteByTag deletes every item carrying tag and returns the number of
+// items deleted.
+func (c *cache) DeleteByTag(tag string) int {
+	if c.shards != nil {
+		n := 0
+		for _, s := range c.shards {
+			n += s.DeleteByTag(tag)
+		}
+		return n
+	}
+	c.Lock()
+	defer c.unlock()
+
+	l := c.tagIndex.tags[tag]
+	if l == nil {
+		return 0
+	}
+	n := 0
+	for l.Len() > 0 {
+		k := l.Front().Value
+		if _, found := c.get(k); found {
+			n++
+		}
+		c.evict(k, EventDelete)
+	}
+	return n
+}
+
+// Associate k with tag, evicting the oldest item with that tag if the tag is
+// full. Must be called with the write lock held, after k is stored.
+func (c *cache) tag(k interface{}, tag string) {
+	ti := &c.tagIndex
+	if ti.tags == nil {
+		ti.tags = map[string]*list.List{}
+		ti.tagged = map[interface{}]map[string]*list.Element{}
+	}
+	if _, found := ti.tagged[k][tag]; found {
+		return
+	}
+	for l := ti.tags[tag]; c.maxPerTag > 0 && l != nil && l.Len() >= c.maxPerTag; l = ti.tags[tag] {
+		c.evict(l.Front().Value, EventEvict)
+	}
+	// Evicting the last item with the tag removes its list.
+	l := ti.tags[tag]
+	if l == nil {
+		l = list.New()
+		ti.tags[tag] = l
+	}
+	if ti.tagged[k] == nil {
+		ti.tagged[k] = map[string]*list.Element{}
+	}
+	ti.tagged[k][tag] = l.PushBack(k)
+}
+
+// Remove k from the tag index and return the tags it had.
+func (c *cache) untag(k interface{}) []string {
+	ti := &c.tagIndex
+	elems, found := ti.tagged[k]
+	if !found {
+		return nil
+	}
+	tags := make([]string, 0, len(elems))
+	for tag, e := range elems {
+		l := ti.tags[tag]
+		l.Remove(e)
+		if l.Len() == 0 {
+			delete(ti.tags, tag)
+		}
+		tags = append(tags, tag)
+	}
+	delete(ti.tagged, k)
+	return tags
+}
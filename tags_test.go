@@ -0,0 +1,81 @@
+package cache
+
+import "testing"
+
+func TestDeleteByTag(t *testing.T) {
+	tc := New(DefaultExpiration, 0)
+	tc.SetWithTags("a", 1, DefaultExpiration, "x", "y")
+	tc.SetWithTags("b", 2, DefaultExpiration, "x")
+	tc.SetWithTags("c", 3, DefaultExpiration, "y")
+	tc.Set("c", 4, DefaultExpiration)
+
+	if n := tc.DeleteByTag("y"); n != 1 {
+		t.Errorf("Deleted %d items tagged y, want 1", n)
+	}
+	if _, found := tc.Get("a"); found {
+		t.Error("Found a after deleting its tag")
+	}
+	if _, found := tc.Get("c"); !found {
+		t.Error("Did not find c, whose tags were replaced by Set")
+	}
+	if n := tc.DeleteByTag("x"); n != 1 {
+		t.Errorf("Deleted %d items tagged x, want 1", n)
+	}
+	if err := tc.ConsistencyCheck(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMaxPerTag(t *testing.T) {
+	tc := New(DefaultExpiration, 0, WithMaxPerTag(3))
+	var evicted []interface{}
+	tc.OnEvicted(func(k interface{}, v interface{}) {
+		evicted = append(evicted, k)
+	})
+	for i := 0; i < 4; i++ {
+		tc.SetWithTags(i, i, DefaultExpiration, "user:1")
+	}
+	tc.SetWithTags("other", 0, DefaultExpiration, "user:2")
+
+	if len(evicted) != 1 || evicted[0] != 0 {
+		t.Errorf("Evicted %v, want [0]", evicted)
+	}
+	if _, found := tc.Get(0); found {
+		t.Error("Found the earliest tagged item")
+	}
+	for i := 1; i < 4; i++ {
+		if _, found := tc.Get(i); !found {
+			t.Errorf("Did not find %d", i)
+		}
+	}
+
+	tc.SetWithTags(1, 1, DefaultExpiration, "user:1")
+	tc.SetWithTags(4, 4, DefaultExpiration, "user:1")
+	if _, found := tc.Get(1); !found {
+		t.Error("Evicted 1 although it was set again most recently")
+	}
+	if _, found := tc.Get(2); found {
+		t.Error("Found 2, the least recently set item with the tag")
+	}
+	if err := tc.ConsistencyCheck(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMaxPerTagOne(t *testing.T) {
+	tc := New(DefaultExpiration, 0, WithMaxPerTag(1))
+	tc.SetWithTags("a", 1, DefaultExpiration, "t")
+	tc.SetWithTags("b", 2, DefaultExpiration, "t")
+	if _, found := tc.Get("a"); found {
+		t.Error("Found a after b took its tag")
+	}
+	if err := tc.ConsistencyCheck(); err != nil {
+		t.Error(err)
+	}
+	if n := tc.DeleteByTag("t"); n != 1 {
+		t.Errorf("DeleteByTag deleted %d items, want 1", n)
+	}
+	if _, found := tc.Get("b"); found {
+		t.Error("Found b after its tag was deleted")
+	}
+}
@@ -0,0 +1,149 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"time"
+)
+
+// An L2 is a shared, usually remote, second tier for a Tiered cache, such as
+// an adapter for Redis or memcached. It stores encoded values under string
+// keys, and must be safe for concurrent use.
+type L2 interface {
+	// Get returns the value stored under key, and false if there is none.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set stores value under key for ttl, or without expiration if ttl is 0.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Delete deletes key. Deleting a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// Tiered is a two-tier cache: an in-memory Cache in front of an L2. Lookups
+// that miss the Cache fall through to the L2, and values found there are
+// stored in the Cache on the way back. Values are encoded for the L2, together
+// with their expiration, with the Cache's codec (see WithCodec), so their types
+// must be registered with it as for Save.
+type Tiered struct {
+	l1 *Cache
+	l2 L2
+}
+
+// NewTiered returns a Tiered cache with the in-memory first tier l1 and the
+// second tier l2.
+func NewTiered(l1 *Cache, l2 L2) *Tiered {
+	return &Tiered{l1, l2}
+}
+
+// Returns the in-memory first tier.
+func (t *Tiered) L1() *Cache {
+	return t.l1
+}
+
+// Get returns the value of an item from the first tier that has it, storing
+// values found in the L2 in the Cache, and a bool indicating whether the key
+// was found. An error is returned only if the L2 fails.
+func (t *Tiered) Get(ctx context.Context, k string) (interface{}, bool, error) {
+	if x, found := t.l1.Get(k); found {
+		return x, true, nil
+	}
+	return t.getL2(ctx, k)
+}
+
+// Returns the value of k from the L2, storing it in the Cache.
+func (t *Tiered) getL2(ctx context.Context, k string) (interface{}, bool, error) {
+	b, found, err := t.l2.Get(ctx, k)
+	if err != nil || !found {
+		return nil, false, err
+	}
+	item, err := t.decode(k, b)
+	if err != nil {
+		return nil, false, err
+	}
+	d, ok := t.remaining(item)
+	if !ok {
+		return nil, false, nil
+	}
+	t.l1.Set(k, item.Object, d)
+	return item.Object, true, nil
+}
+
+// Set stores x under k in both tiers with the expiration d, as with
+// Cache.Set. The Cache is updated even if the L2 fails.
+func (t *Tiered) Set(ctx context.Context, k string, x interface{}, d time.Duration) error {
+	t.l1.Set(k, x, d)
+	return t.setL2(ctx, k, x, d)
+}
+
+// Stores x under k in the L2 with the expiration d.
+func (t *Tiered) setL2(ctx context.Context, k string, x interface{}, d time.Duration) error {
+	if d == DefaultExpiration {
+		d = t.l1.defaultExpiration
+	}
+	item := Item{Object: x}
+	if d > 0 {
+		item.Expiration = t.l1.now().Add(d).UnixNano()
+	} else {
+		d = 0
+	}
+	var buf bytes.Buffer
+	if err := t.l1.itemCodec().Encode(&buf, map[interface{}]Item{k: item}); err != nil {
+		return err
+	}
+	return t.l2.Set(ctx, k, buf.Bytes(), d)
+}
+
+// Delete deletes k from both tiers. The Cache is updated even if the L2
+// fails.
+func (t *Tiered) Delete(ctx context.Context, k string) error {
+	t.l1.Delete(k)
+	return t.l2.Delete(ctx, k)
+}
+
+// GetOrLoad returns the value of k from the first tier that has it, storing
+// values found in the L2 in the Cache. If neither has it, it calls load and
+// stores the value it returns in both tiers with the expiration it returns.
+// Concurrent calls for the same missing key share one lookup in the L2 and one
+// call to load, as with Cache.GetOrLoadContext, whose options apply. If the L2
+// fails, the value is loaded all the same, and is then stored only in the
+// Cache; errors from load are returned as they are.
+func (t *Tiered) GetOrLoad(ctx context.Context, k string, load func(ctx context.Context, k string) (interface{}, time.Duration, error)) (interface{}, error) {
+	return t.l1.GetOrLoadContext(ctx, k, func(ctx context.Context, _ interface{}) (interface{}, time.Duration, error) {
+		if b, found, err := t.l2.Get(ctx, k); err == nil && found {
+			if item, err := t.decode(k, b); err == nil {
+				if d, ok := t.remaining(item); ok {
+					return item.Object, d, nil
+				}
+			}
+		}
+		x, d, err := load(ctx, k)
+		if err != nil {
+			return nil, 0, err
+		}
+		t.setL2(ctx, k, x, d)
+		return x, d, nil
+	})
+}
+
+// Returns the item of k encoded in b.
+func (t *Tiered) decode(k string, b []byte) (Item, error) {
+	items, err := t.l1.itemCodec().Decode(bytes.NewReader(b))
+	if err != nil {
+		return Item{}, err
+	}
+	item, found := items[k]
+	if !found {
+		return Item{}, errors.New("cache: L2 value holds another key")
+	}
+	return item, nil
+}
+
+// Returns how long to keep item in the Cache for, and false if it has
+// expired.
+func (t *Tiered) remaining(item Item) (time.Duration, bool) {
+	if item.Expiration == 0 {
+		return NoExpiration, true
+	}
+	d := time.Unix(0, item.Expiration).Sub(t.l1.now())
+	return d, d > 0
+}
@@ -0,0 +1,171 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// An L2 holding values in a map.
+type mapL2 struct {
+	mu     sync.Mutex
+	values map[string][]byte
+	ttls   map[string]time.Duration
+	gets   int
+	err    error
+}
+
+func newMapL2() *mapL2 {
+	return &mapL2{values: map[string][]byte{}, ttls: map[string]time.Duration{}}
+}
+
+func (m *mapL2) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.gets++
+	if m.err != nil {
+		return nil, false, m.err
+	}
+	b, found := m.values[key]
+	return b, found, nil
+}
+
+func (m *mapL2) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return m.err
+	}
+	m.values[key] = value
+	m.ttls[key] = ttl
+	return nil
+}
+
+func (m *mapL2) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return m.err
+	}
+	delete(m.values, key)
+	return nil
+}
+
+func TestTieredGet(t *testing.T) {
+	ctx := context.Background()
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	l2 := newMapL2()
+	// Another process's cache sharing the L2.
+	other := NewTiered(New(time.Minute, 0, WithClock(clock)), l2)
+	if err := other.Set(ctx, "a", "x", DefaultExpiration); err != nil {
+		t.Fatal(err)
+	}
+	if l2.ttls["a"] != time.Minute {
+		t.Errorf("got L2 TTL %v, want the default expiration", l2.ttls["a"])
+	}
+	other.Set(ctx, "forever", 1, NoExpiration)
+	if l2.ttls["forever"] != 0 {
+		t.Errorf("got L2 TTL %v, want 0 for no expiration", l2.ttls["forever"])
+	}
+
+	tc := NewTiered(New(NoExpiration, 0, WithClock(clock)), l2)
+	clock.Advance(10 * time.Second)
+	x, found, err := tc.Get(ctx, "a")
+	if err != nil || !found || x != "x" {
+		t.Fatalf("got %v, %v, %v, want x from the L2", x, found, err)
+	}
+	if ttl, _ := tc.L1().TTL("a"); ttl != 50*time.Second {
+		t.Errorf("got L1 TTL %v, want the 50s left in the L2", ttl)
+	}
+	if x, found, _ := tc.Get(ctx, "forever"); !found || x != 1 {
+		t.Errorf("got %v, %v, want 1", x, found)
+	}
+	if ttl, _ := tc.L1().TTL("forever"); ttl != NoExpiration {
+		t.Errorf("got L1 TTL %v, want no expiration", ttl)
+	}
+
+	gets := l2.gets
+	tc.Get(ctx, "a")
+	if l2.gets != gets {
+		t.Error("L1 hit looked up the L2")
+	}
+	if _, found, _ := tc.Get(ctx, "missing"); found {
+		t.Error("found a missing key")
+	}
+
+	if err := tc.Delete(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, found, _ := other.Get(ctx, "a"); !found {
+		t.Error("Delete deleted from another process's L1")
+	}
+	if _, found, _ := tc.Get(ctx, "a"); found {
+		t.Error("found a deleted key")
+	}
+
+	// Values that expire in the L2 while it still returns them are misses.
+	other.Set(ctx, "b", "y", time.Second)
+	clock.Advance(time.Second)
+	if _, found, _ := tc.Get(ctx, "b"); found {
+		t.Error("found an expired L2 value")
+	}
+
+	l2.err = errors.New("down")
+	if _, _, err := tc.Get(ctx, "c"); err != l2.err {
+		t.Errorf("got %v, want the L2 error", err)
+	}
+	if err := tc.Set(ctx, "c", 1, DefaultExpiration); err != l2.err {
+		t.Errorf("got %v, want the L2 error", err)
+	}
+	if x, _, _ := tc.Get(ctx, "c"); x != 1 {
+		t.Errorf("got %v, want 1 stored in the L1 despite the L2 error", x)
+	}
+}
+
+func TestTieredGetOrLoad(t *testing.T) {
+	ctx := context.Background()
+	l2 := newMapL2()
+	NewTiered(New(NoExpiration, 0), l2).Set(ctx, "a", "from L2", DefaultExpiration)
+	tc := NewTiered(New(NoExpiration, 0), l2)
+
+	calls := 0
+	load := func(ctx context.Context, k string) (interface{}, time.Duration, error) {
+		calls++
+		return "loaded " + k, time.Hour, nil
+	}
+	if x, err := tc.GetOrLoad(ctx, "a", load); err != nil || x != "from L2" {
+		t.Errorf("got %v, %v, want the L2 value", x, err)
+	}
+	if calls != 0 {
+		t.Error("loaded a value found in the L2")
+	}
+
+	if x, err := tc.GetOrLoad(ctx, "b", load); err != nil || x != "loaded b" {
+		t.Errorf("got %v, %v, want loaded b", x, err)
+	}
+	if calls != 1 {
+		t.Errorf("got %d loads, want 1", calls)
+	}
+	if l2.ttls["b"] != time.Hour {
+		t.Errorf("got L2 TTL %v, want the TTL returned by load", l2.ttls["b"])
+	}
+	if x, _, _ := NewTiered(New(NoExpiration, 0), l2).Get(ctx, "b"); x != "loaded b" {
+		t.Errorf("got %v from the L2, want the loaded value", x)
+	}
+
+	// The L2 failing doesn't fail the load.
+	l2.err = errors.New("down")
+	if x, err := tc.GetOrLoad(ctx, "c", load); err != nil || x != "loaded c" {
+		t.Errorf("got %v, %v, want loaded c", x, err)
+	}
+
+	errLoad := errors.New("load failed")
+	_, err := tc.GetOrLoad(ctx, "d", func(ctx context.Context, k string) (interface{}, time.Duration, error) {
+		return nil, 0, errLoad
+	})
+	if !errors.Is(err, errLoad) {
+		t.Errorf("got %v, want the load error", err)
+	}
+}
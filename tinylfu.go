@@ -0,0 +1,142 @@
+package cache
+
+import "hash/maphash"
+
+// WithTinyLFUAdmission makes a cache with a limit set with WithMaxEntries
+// admit a new key when it is full only if the key is estimated to be used
+// more often than the item that would be evicted for it. Otherwise the new
+// key is not stored, and counts as a rejection in Stats. Keys are counted
+// each time they are stored or returned by a lookup, in a compact sketch of
+// about two bytes per item of the limit, so that keys seen only once (one-hit
+// wonders) don't push out valuable items. A doorkeeper filter absorbs each
+// key's first use, and all counts are halved periodically so that the
+// estimates follow changes in popularity. Keys already in the cache are
+// always stored. It works with any eviction policy.
+func WithTinyLFUAdmission() Option {
+	return func(c *cache) {
+		c.tinyLFU = true
+	}
+}
+
+// The depth of the count-min sketch: the number of counters per key.
+const sketchDepth = 4
+
+// A TinyLFU frequency estimator: a count-min sketch of 4-bit counters behind
+// a doorkeeper Bloom filter.
+type tinyLFU struct {
+	seed maphash.Seed
+	// The counters, two per byte, in sketchDepth rows of mask+1 each. A key
+	// has one counter in each row.
+	counters []byte
+	// One bit per counter: the keys seen once since the last reset.
+	doorkeeper []uint64
+	mask       uint64
+	// The number of keys counted since the last reset, and the number at
+	// which counts are halved.
+	samples, resetAt int
+}
+
+func newTinyLFU(capacity int) *tinyLFU {
+	width := uint64(256)
+	for width < uint64(capacity) {
+		width *= 2
+	}
+	n := sketchDepth * width
+	return &tinyLFU{
+		seed:       maphash.MakeSeed(),
+		counters:   make([]byte, n/2),
+		doorkeeper: make([]uint64, n/64),
+		mask:       width - 1,
+		resetAt:    10 * capacity,
+	}
+}
+
+// Returns the indexes of the counters of the key hashed to h.
+func (f *tinyLFU) indexes(h uint64) [sketchDepth]uint64 {
+	var idx [sketchDepth]uint64
+	for i := range idx {
+		// Mix h differently for each row, as in SplitMix64.
+		x := h + uint64(i+1)*0x9e3779b97f4a7c15
+		x = (x ^ x>>30) * 0xbf58476d1ce4e5b9
+		x = (x ^ x>>27) * 0x94d049bb133111eb
+		x ^= x >> 31
+		idx[i] = uint64(i)*(f.mask+1) + x&f.mask
+	}
+	return idx
+}
+
+func (f *tinyLFU) counter(i uint64) byte {
+	return f.counters[i/2] >> (4 * (i % 2)) & 0x0f
+}
+
+func (f *tinyLFU) increment(i uint64) {
+	if f.counter(i) < 15 {
+		f.counters[i/2] += 1 << (4 * (i % 2))
+	}
+}
+
+// Count a use of k.
+func (f *tinyLFU) add(k interface{}) {
+	idx := f.indexes(hashKey(f.seed, k))
+	seen := true
+	for _, i := range idx {
+		if f.doorkeeper[i/64]&(1<<(i%64)) == 0 {
+			seen = false
+			f.doorkeeper[i/64] |= 1 << (i % 64)
+		}
+	}
+	if seen {
+		for _, i := range idx {
+			f.increment(i)
+		}
+	}
+	if f.samples++; f.samples >= f.resetAt {
+		f.reset()
+	}
+}
+
+// Returns the estimated number of uses of k.
+func (f *tinyLFU) estimate(k interface{}) int {
+	idx := f.indexes(hashKey(f.seed, k))
+	min := byte(15)
+	seen := true
+	for _, i := range idx {
+		if c := f.counter(i); c < min {
+			min = c
+		}
+		if f.doorkeeper[i/64]&(1<<(i%64)) == 0 {
+			seen = false
+		}
+	}
+	if seen {
+		return int(min) + 1
+	}
+	return int(min)
+}
+
+// Halve all counts and clear the doorkeeper.
+func (f *tinyLFU) reset() {
+	for i, b := range f.counters {
+		f.counters[i] = b >> 1 & 0x77
+	}
+	for i := range f.doorkeeper {
+		f.doorkeeper[i] = 0
+	}
+	f.samples = 0
+}
+
+// Count a use of k by the admission filter, if any.
+func (a *accessOrder) count(k interface{}) {
+	a.mu.Lock()
+	if a.filter != nil {
+		a.filter.add(k)
+	}
+	a.mu.Unlock()
+}
+
+// Reports whether the new key k should be stored in place of victim.
+func (a *accessOrder) admit(k, victim interface{}) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.filter == nil || a.filter.estimate(k) > a.filter.estimate(victim)
+}
@@ -0,0 +1,100 @@
+package cache
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestTinyLFUEstimate(t *testing.T) {
+	f := newTinyLFU(100)
+	if n := f.estimate("a"); n != 0 {
+		t.Errorf("Estimate of unseen key is %d", n)
+	}
+	for i := 0; i < 5; i++ {
+		f.add("a")
+	}
+	f.add("b")
+	if n := f.estimate("a"); n < 5 {
+		t.Errorf("Estimate of a is %d, want at least 5", n)
+	}
+	if n := f.estimate("b"); n != 1 {
+		t.Errorf("Estimate of b is %d, want 1", n)
+	}
+	f.reset()
+	if n := f.estimate("a"); n != 2 {
+		t.Errorf("Estimate of a after reset is %d, want 2", n)
+	}
+	if n := f.estimate("b"); n != 0 {
+		t.Errorf("Estimate of b after reset is %d, want 0", n)
+	}
+
+	// Counts are halved after enough samples.
+	for i := 0; i < 1000; i++ {
+		f.add(i)
+	}
+	if f.samples >= f.resetAt {
+		t.Errorf("Counted %d samples without a reset", f.samples)
+	}
+}
+
+func TestTinyLFUAdmission(t *testing.T) {
+	tc := New(DefaultExpiration, 0, WithMaxEntries(3), WithTinyLFUAdmission())
+	for _, k := range []string{"a", "b", "c"} {
+		tc.Set(k, k, DefaultExpiration)
+		for i := 0; i < 3; i++ {
+			tc.Get(k)
+		}
+	}
+	// Keys seen once are rejected instead of evicting popular items.
+	for i := 0; i < 10; i++ {
+		tc.Set(fmt.Sprint("once", i), i, DefaultExpiration)
+	}
+	for _, k := range []string{"a", "b", "c"} {
+		if _, found := tc.Get(k); !found {
+			t.Errorf("%s was evicted", k)
+		}
+	}
+	if _, found := tc.Get("once9"); found {
+		t.Error("once9 was stored")
+	}
+	if s := tc.Stats(); s.Rejections != 10 || s.Evictions != 0 {
+		t.Errorf("Rejections = %d, Evictions = %d, want 10 and 0", s.Rejections, s.Evictions)
+	}
+
+	// A key stored often enough is admitted.
+	for i := 0; i < 10; i++ {
+		tc.Set("hot", i, DefaultExpiration)
+	}
+	if x, found := tc.Get("hot"); !found || x != 9 {
+		t.Errorf("hot is %v, %t", x, found)
+	}
+	if n := tc.ItemCount(); n != 3 {
+		t.Errorf("ItemCount is %d, want 3", n)
+	}
+	// Keys in the cache are always stored.
+	tc.Set("hot", 10, DefaultExpiration)
+	if x, _ := tc.Get("hot"); x != 10 {
+		t.Errorf("hot is %v, want 10", x)
+	}
+	if err := tc.ConsistencyCheck(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTinyLFUAdmissionRejectedCost(t *testing.T) {
+	tc := New(DefaultExpiration, 0, WithMaxEntries(1), WithTinyLFUAdmission())
+	tc.SetWithCost("a", 1, 5, DefaultExpiration)
+	tc.Get("a")
+	tc.Get("a")
+	tc.SetWithCost("b", 2, 7, DefaultExpiration)
+	tc.SetWithTags("c", 3, DefaultExpiration, "t")
+	if _, found := tc.Get("b"); found {
+		t.Error("b was stored")
+	}
+	if n := tc.DeleteByTag("t"); n != 0 {
+		t.Errorf("DeleteByTag deleted %d items", n)
+	}
+	if err := tc.ConsistencyCheck(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,210 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// Typed is a type-safe view of a Cache whose keys are of type K and values of
+// type V. It is a thin wrapper: the values are stored in the underlying Cache,
+// which can be obtained with Untyped.
+type Typed[K comparable, V any] struct {
+	c *Cache
+}
+
+// NewTyped returns a new typed cache with the given default expiration,
+// cleanup interval and options, as for New. WithKeyFunc can't be used, as the
+// cache would hold the converted keys, which Keys, OnEvicted and OnExpired
+// couldn't turn back into keys of type K: NewTyped panics if it is given.
+func NewTyped[K comparable, V any](defaultExpiration, cleanupInterval time.Duration, opts ...Option) *Typed[K, V] {
+	c := New(defaultExpiration, cleanupInterval, opts...)
+	if c.keyFunc != nil {
+		c.Close()
+		panic("cache: NewTyped can't be used with WithKeyFunc")
+	}
+	return &Typed[K, V]{c}
+}
+
+// Returns the untyped cache that holds the items.
+func (t *Typed[K, V]) Untyped() *Cache {
+	return t.c
+}
+
+// Returns x as a V, or the zero V if x is nil or of another type.
+func value[V any](x interface{}) V {
+	v, _ := x.(V)
+	return v
+}
+
+// Set adds an item to the cache, replacing any existing item. See Cache.Set.
+func (t *Typed[K, V]) Set(k K, v V, d time.Duration) {
+	t.c.Set(k, v, d)
+}
+
+// TrySet works like Set, but returns the error writing the item to the store
+// of a cache with WithWriteThrough. See Cache.TrySet.
+func (t *Typed[K, V]) TrySet(k K, v V, d time.Duration) error {
+	return t.c.TrySet(k, v, d)
+}
+
+// Add an item to the cache only if it doesn't already exist. See Cache.Add.
+func (t *Typed[K, V]) Add(k K, v V, d time.Duration) error {
+	return t.c.Add(k, v, d)
+}
+
+// Replace an item only if it already exists. See Cache.Replace.
+func (t *Typed[K, V]) Replace(k K, v V, d time.Duration) error {
+	return t.c.Replace(k, v, d)
+}
+
+// Get an item from the cache. Returns the item or the zero V, and a bool
+// indicating whether the key was found.
+func (t *Typed[K, V]) Get(k K) (V, bool) {
+	x, found := t.c.Get(k)
+	return value[V](x), found
+}
+
+// GetAndExtend gets an item and extends its expiration by d. See
+// Cache.GetAndExtend.
+func (t *Typed[K, V]) GetAndExtend(k K, d time.Duration) (V, bool) {
+	x, found := t.c.GetAndExtend(k, d)
+	return value[V](x), found
+}
+
+// Returns load as an untyped loader.
+func untypedLoader[K comparable, V any](load func(K) (V, time.Duration, error)) loader {
+	return func(k interface{}) (interface{}, time.Duration, error) {
+		return load(k.(K))
+	}
+}
+
+// GetOrLoad gets an item, loading and storing it with load if it isn't in the
+// cache. See Cache.GetOrLoad.
+func (t *Typed[K, V]) GetOrLoad(k K, load func(K) (V, time.Duration, error)) (V, error) {
+	x, err := t.c.GetOrLoad(k, untypedLoader(load))
+	return value[V](x), err
+}
+
+// GetOrLoadContext works like GetOrLoad, but passes ctx to load and stops
+// waiting for another goroutine's load when ctx is done. See
+// Cache.GetOrLoadContext.
+func (t *Typed[K, V]) GetOrLoadContext(ctx context.Context, k K, load func(context.Context, K) (V, time.Duration, error)) (V, error) {
+	x, err := t.c.GetOrLoadContext(ctx, k, func(ctx context.Context, k interface{}) (interface{}, time.Duration, error) {
+		return load(ctx, k.(K))
+	})
+	return value[V](x), err
+}
+
+// GetAndExtendOrLoad gets an item and extends its expiration by d, or loads
+// and stores it with load if it isn't in the cache. See
+// Cache.GetAndExtendOrLoad.
+func (t *Typed[K, V]) GetAndExtendOrLoad(k K, d time.Duration, load func(K) (V, time.Duration, error)) (V, error) {
+	x, err := t.c.GetAndExtendOrLoad(k, d, untypedLoader(load))
+	return value[V](x), err
+}
+
+// GetOrSet returns the value of the unexpired item under k, or stores v if
+// there is none. See Cache.GetOrSet.
+func (t *Typed[K, V]) GetOrSet(k K, v V, d time.Duration) (actual V, loaded bool) {
+	x, loaded := t.c.GetOrSet(k, v, d)
+	return value[V](x), loaded
+}
+
+// Update reads, modifies and writes the item under k in one step. See
+// Cache.Update.
+func (t *Typed[K, V]) Update(k K, fn func(old V, exists bool) (new V, d time.Duration, keep bool)) {
+	t.c.Update(k, func(old interface{}, exists bool) (interface{}, time.Duration, bool) {
+		return fn(value[V](old), exists)
+	})
+}
+
+// LockKey locks k for the caller and returns the function that unlocks it.
+// See Cache.LockKey.
+func (t *Typed[K, V]) LockKey(k K) (unlock func()) {
+	return t.c.LockKey(k)
+}
+
+// Delete an item from the cache. Does nothing if the key is not in the cache.
+func (t *Typed[K, V]) Delete(k K) {
+	t.c.Delete(k)
+}
+
+// TryDelete works like Delete, but returns the error deleting the key from the
+// store of a cache with WithWriteThrough. See Cache.TryDelete.
+func (t *Typed[K, V]) TryDelete(k K) error {
+	return t.c.TryDelete(k)
+}
+
+// Pin exempts an item from expiration and eviction until Unpin is called for
+// it. See Cache.Pin.
+func (t *Typed[K, V]) Pin(k K) bool {
+	return t.c.Pin(k)
+}
+
+// Unpin makes a pinned item subject to eviction again and sets its expiration
+// to d from now. See Cache.Unpin.
+func (t *Typed[K, V]) Unpin(k K, d time.Duration) bool {
+	return t.c.Unpin(k, d)
+}
+
+// Sets an (optional) function that is called with the key and value when an
+// item is evicted from the cache. See Cache.OnEvicted. Set to nil to disable.
+func (t *Typed[K, V]) OnEvicted(f func(K, V)) {
+	if f == nil {
+		t.c.OnEvicted(nil)
+		return
+	}
+	t.c.OnEvicted(func(k interface{}, v interface{}) {
+		f(value[K](k), value[V](v))
+	})
+}
+
+// Sets an (optional) function that is called with the key and value when an
+// item expires. See Cache.OnExpired. Set to nil to disable.
+func (t *Typed[K, V]) OnExpired(f func(K, V)) {
+	if f == nil {
+		t.c.OnExpired(nil)
+		return
+	}
+	t.c.OnExpired(func(k interface{}, v interface{}) {
+		f(value[K](k), value[V](v))
+	})
+}
+
+// Keys returns the keys of all unexpired items in the cache, in no particular
+// order. See Cache.Keys.
+func (t *Typed[K, V]) Keys() []K {
+	keys := t.c.Keys()
+	typed := make([]K, len(keys))
+	for i, k := range keys {
+		typed[i] = value[K](k)
+	}
+	return typed
+}
+
+// Returns the number of items in the cache. See Cache.ItemCount.
+func (t *Typed[K, V]) ItemCount() int {
+	return t.c.ItemCount()
+}
+
+// Delete all items from the cache.
+func (t *Typed[K, V]) Flush() {
+	t.c.Flush()
+}
+
+// Close stops the cache's background goroutines. See Cache.Close.
+func (t *Typed[K, V]) Close() {
+	t.c.Close()
+}
+
+// FlushStore writes the writes queued by WithWriteBehind to the store right
+// away. See Cache.FlushStore.
+func (t *Typed[K, V]) FlushStore() error {
+	return t.c.FlushStore()
+}
+
+// StopJanitor stops the goroutine that deletes expired items. See
+// Cache.StopJanitor.
+func (t *Typed[K, V]) StopJanitor() {
+	t.c.StopJanitor()
+}
@@ -0,0 +1,87 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+)
+
+type session struct {
+	User string
+}
+
+func TestTyped(t *testing.T) {
+	tc := NewTyped[string, *session](DefaultExpiration, 0)
+	if s, found := tc.Get("missing"); found || s != nil {
+		t.Errorf("Got %v, %v for a missing key", s, found)
+	}
+
+	tc.Set("abc", &session{User: "alice"}, DefaultExpiration)
+	s, found := tc.Get("abc")
+	if !found || s.User != "alice" {
+		t.Errorf("Got %v, %v; want alice's session", s, found)
+	}
+	if err := tc.Add("abc", &session{}, DefaultExpiration); err == nil {
+		t.Error("Added an existing key")
+	}
+
+	loads := 0
+	load := func(k string) (*session, time.Duration, error) {
+		loads++
+		if k == "bad" {
+			return nil, 0, errors.New("no such session")
+		}
+		return &session{User: k}, DefaultExpiration, nil
+	}
+	s, err := tc.GetOrLoad("bob", load)
+	if err != nil || s.User != "bob" {
+		t.Errorf("Loaded %v, %v; want bob's session", s, err)
+	}
+	s, err = tc.GetOrLoad("bob", load)
+	if err != nil || s.User != "bob" || loads != 1 {
+		t.Errorf("Got %v, %v after %d loads; want bob's session after 1", s, err, loads)
+	}
+	if _, err := tc.GetOrLoad("bad", load); err == nil {
+		t.Error("Loading bad did not fail")
+	}
+
+	var evicted []string
+	tc.OnEvicted(func(k string, v *session) {
+		evicted = append(evicted, k+"="+v.User)
+	})
+	tc.Delete("abc")
+	if len(evicted) != 1 || evicted[0] != "abc=alice" {
+		t.Errorf("Evicted %v, want [abc=alice]", evicted)
+	}
+	if n := tc.ItemCount(); n != 1 {
+		t.Errorf("Item count is %d, want 1", n)
+	}
+	if tc.Untyped().ItemCount() != 1 {
+		t.Error("Untyped cache does not hold the typed items")
+	}
+}
+
+func TestTypedKeys(t *testing.T) {
+	tc := NewTyped[int, string](DefaultExpiration, 0)
+	tc.Set(1, "a", DefaultExpiration)
+	tc.Set(2, "b", DefaultExpiration)
+
+	keys := tc.Keys()
+	sort.Ints(keys)
+	if len(keys) != 2 || keys[0] != 1 || keys[1] != 2 {
+		t.Error("Keys returned", keys)
+	}
+}
+
+func TestTypedKeyFunc(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("NewTyped accepted WithKeyFunc")
+		}
+	}()
+	NewTyped[int, string](DefaultExpiration, 0, WithKeyFunc(func(k interface{}) string {
+		return fmt.Sprint(k)
+	}))
+}
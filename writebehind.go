@@ -0,0 +1,308 @@
+package cache
+
+import (
+	"errors"
+	"hash/maphash"
+	"sync"
+	"time"
+)
+
+// An OverflowPolicy tells a write-behind cache what to do with a write when
+// its queue is full. See WriteBehindPolicy.
+type OverflowPolicy int
+
+const (
+	// Wait until the queue has room. Writers are slowed down to the pace of
+	// the store, and no write is lost.
+	OverflowBlock OverflowPolicy = iota
+	// Keep the queue as it is and don't write the new value to the store.
+	OverflowDropNewest
+	// Drop the oldest write in the queue to make room for the new one.
+	OverflowDropOldest
+)
+
+// A WriteBehindPolicy configures the queue of a cache set up with
+// WithWriteBehind.
+type WriteBehindPolicy struct {
+	// How often the queued writes are flushed to the store. If it is not
+	// greater than zero, they are flushed every second.
+	Interval time.Duration
+	// The maximum number of keys with a queued write. If it is not greater
+	// than zero, 1024 keys are queued at most. The queue is flushed early
+	// when it fills up.
+	QueueSize int
+	// What to do with writes of new keys while the queue is full.
+	Overflow OverflowPolicy
+	// If not nil, called with the key and error of each write that fails in
+	// the store or is dropped from the queue, in which case the error wraps
+	// ErrWriteQueueFull. It is called without any lock held.
+	OnError func(k interface{}, err error)
+}
+
+// A StoreWrite is a write queued for the store of a write-behind cache.
+type StoreWrite struct {
+	Key interface{}
+	// The value to put, unless Delete is true.
+	Value  interface{}
+	Delete bool
+}
+
+// Apply w to s.
+func (w StoreWrite) apply(s Store) error {
+	if w.Delete {
+		return s.Delete(w.Key)
+	}
+	return s.Put(w.Key, w.Value)
+}
+
+// A BatchStore is a Store that can apply several writes at once. A
+// write-behind cache whose store is a BatchStore flushes its queue with one
+// call to WriteBatch instead of one call to Put or Delete per write.
+type BatchStore interface {
+	Store
+	// Apply writes, which are for distinct keys, to the store. If it
+	// returns an error, they are all considered failed.
+	WriteBatch(writes []StoreWrite) error
+}
+
+// WithWriteBehind makes Set and Delete change the cache right away and queue
+// the write for store, which gets the queued writes in batches every
+// interval, so that writers don't wait for the store. Only the latest write
+// of each key is queued. Writes that fail in the store are queued again,
+// unless the key has been written since, and are retried at the next flush.
+// Only Set, Delete, TrySet and TryDelete are written to the store; other
+// writes such as Add, Update, Increment or SetMany, and items expiring or
+// being evicted, don't change it. Keys are passed to the store as given,
+// before WithKeyFunc applies. Call FlushStore to write the queue right away;
+// Close writes what is left, after which writes are made to the store
+// synchronously, as with WithWriteThrough. WithWriteThrough takes precedence
+// over WithWriteBehind.
+func WithWriteBehind(store Store, policy WriteBehindPolicy) Option {
+	return func(c *cache) {
+		if policy.Interval <= 0 {
+			policy.Interval = time.Second
+		}
+		if policy.QueueSize <= 0 {
+			policy.QueueSize = 1024
+		}
+		wb := &writeBehind{
+			store:   store,
+			policy:  policy,
+			locks:   &keyLocks{seed: maphash.MakeSeed()},
+			pending: map[interface{}]StoreWrite{},
+			full:    make(chan struct{}, 1),
+			stop:    make(chan struct{}),
+			done:    make(chan struct{}),
+		}
+		wb.room = sync.NewCond(&wb.mu)
+		c.writeBehind = wb
+	}
+}
+
+// The queue of a write-behind cache.
+type writeBehind struct {
+	store  Store
+	policy WriteBehindPolicy
+	// Serialize the writes of each key, so that the cache and the queue
+	// get them in the same order.
+	locks *keyLocks
+
+	mu sync.Mutex
+	// Signaled when the queue has room or is closed.
+	room *sync.Cond
+	// The queued writes by key as returned by the key function, and their
+	// keys in the order they were queued.
+	pending map[interface{}]StoreWrite
+	order   []interface{}
+	closed  bool
+
+	// Held while flushing, so that writes of one key reach the store in
+	// order.
+	flushing sync.Mutex
+	// Signaled when the queue is full, to flush it early.
+	full chan struct{}
+	stop chan struct{}
+	done chan struct{}
+}
+
+// Returned by enqueue once the queue is closed.
+var errQueueClosed = errors.New("write-behind queue is closed")
+
+// Queue w under the key k. Returns errQueueClosed if the queue is closed, and
+// ErrWriteQueueFull if w is dropped; dropped holds the writes dropped.
+func (wb *writeBehind) enqueue(k interface{}, w StoreWrite) (dropped []StoreWrite, err error) {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+
+	for {
+		if wb.closed {
+			return dropped, errQueueClosed
+		}
+		if _, queued := wb.pending[k]; queued || len(wb.pending) < wb.policy.QueueSize {
+			break
+		}
+		select {
+		case wb.full <- struct{}{}:
+		default:
+		}
+		switch wb.policy.Overflow {
+		case OverflowDropNewest:
+			return append(dropped, w), ErrWriteQueueFull
+		case OverflowDropOldest:
+			oldest := wb.order[0]
+			wb.order = wb.order[1:]
+			dropped = append(dropped, wb.pending[oldest])
+			delete(wb.pending, oldest)
+		default:
+			wb.room.Wait()
+		}
+	}
+	if _, queued := wb.pending[k]; !queued {
+		wb.order = append(wb.order, k)
+	}
+	wb.pending[k] = w
+	return dropped, nil
+}
+
+// Report err for each of writes.
+func (wb *writeBehind) report(writes []StoreWrite, err error) {
+	if wb.policy.OnError == nil {
+		return
+	}
+	for _, w := range writes {
+		wb.policy.OnError(w.Key, &KeyError{w.Key, err})
+	}
+}
+
+// Apply w to the cache with apply and queue it, under the key k as returned
+// by the key function. Returns the error of w if it is dropped, or once the
+// queue is closed, if the store fails.
+func (wb *writeBehind) write(k interface{}, w StoreWrite, apply func()) error {
+	unlock := wb.locks.lock(k)
+	defer unlock()
+	dropped, err := wb.enqueue(k, w)
+	if err == errQueueClosed {
+		if err := w.apply(wb.store); err != nil {
+			return &KeyError{w.Key, err}
+		}
+		apply()
+		return nil
+	}
+	apply()
+	wb.report(dropped, ErrWriteQueueFull)
+	if err != nil {
+		return &KeyError{w.Key, err}
+	}
+	return nil
+}
+
+// Write the queued writes to the store, queueing those that fail again.
+// Returns the first error.
+func (wb *writeBehind) flush() error {
+	wb.flushing.Lock()
+	defer wb.flushing.Unlock()
+	wb.mu.Lock()
+	keys := wb.order
+	batch := make([]StoreWrite, len(keys))
+	for i, k := range keys {
+		batch[i] = wb.pending[k]
+	}
+	wb.pending = map[interface{}]StoreWrite{}
+	wb.order = nil
+	wb.room.Broadcast()
+	wb.mu.Unlock()
+	if len(batch) == 0 {
+		return nil
+	}
+
+	failed := map[int]error{}
+	first := -1
+	if bs, ok := wb.store.(BatchStore); ok {
+		if err := bs.WriteBatch(batch); err != nil {
+			first = 0
+			for i := range batch {
+				failed[i] = err
+			}
+		}
+	} else {
+		for i, w := range batch {
+			if err := w.apply(wb.store); err != nil {
+				if first < 0 {
+					first = i
+				}
+				failed[i] = err
+			}
+		}
+	}
+	if first < 0 {
+		return nil
+	}
+
+	// Queue the failed writes again, oldest first, unless their key has
+	// been written since or there is no room.
+	wb.mu.Lock()
+	var retry []interface{}
+	for i, k := range keys {
+		if _, ok := failed[i]; !ok {
+			continue
+		}
+		if _, queued := wb.pending[k]; !queued && len(wb.pending) < wb.policy.QueueSize {
+			wb.pending[k] = batch[i]
+			retry = append(retry, k)
+		}
+	}
+	wb.order = append(retry, wb.order...)
+	wb.mu.Unlock()
+	if wb.policy.OnError != nil {
+		for i, w := range batch {
+			if err, ok := failed[i]; ok {
+				wb.policy.OnError(w.Key, &KeyError{w.Key, err})
+			}
+		}
+	}
+	return &KeyError{batch[first].Key, failed[first]}
+}
+
+// Start flushing the queue of c periodically. The ticker is created before
+// returning, so that a FakeClock advanced afterwards fires it.
+func (c *cache) startWriteBehind() {
+	go c.writeBehind.run(c.newTicker(c.writeBehind.policy.Interval))
+}
+
+func (wb *writeBehind) run(ticker Ticker) {
+	defer close(wb.done)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C():
+			wb.flush()
+		case <-wb.full:
+			wb.flush()
+		case <-wb.stop:
+			return
+		}
+	}
+}
+
+// Stop flushing the queue periodically and flush what is left. Writes made
+// afterwards go directly to the store.
+func (c *cache) stopWriteBehind() {
+	wb := c.writeBehind
+	wb.mu.Lock()
+	wb.closed = true
+	wb.room.Broadcast()
+	wb.mu.Unlock()
+	close(wb.stop)
+	<-wb.done
+	wb.flush()
+}
+
+// FlushStore writes the writes queued by WithWriteBehind to the store right
+// away, and returns the first error. Writes that fail are queued again. It
+// does nothing if the cache wasn't created with WithWriteBehind.
+func (c *cache) FlushStore() error {
+	if c.writeBehind == nil {
+		return nil
+	}
+	return c.writeBehind.flush()
+}
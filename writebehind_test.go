@@ -0,0 +1,206 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// A BatchStore recording its batches.
+type batchStore struct {
+	mapStore
+	batches [][]StoreWrite
+}
+
+func (s *batchStore) WriteBatch(writes []StoreWrite) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.batches = append(s.batches, writes)
+	for _, w := range writes {
+		if w.Delete {
+			delete(s.values, w.Key)
+		} else {
+			s.values[w.Key] = w.Value
+		}
+	}
+	return nil
+}
+
+func TestWriteBehind(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	store := &mapStore{values: map[interface{}]interface{}{"gone": 0}}
+	tc := New(DefaultExpiration, 0, WithClock(clock), WithWriteBehind(store, WriteBehindPolicy{Interval: time.Minute}))
+	defer tc.Close()
+
+	tc.Set("a", 1, DefaultExpiration)
+	tc.Set("a", 2, DefaultExpiration)
+	tc.Set("b", 3, DefaultExpiration)
+	tc.Delete("gone")
+	if x, _ := tc.Get("a"); x != 2 {
+		t.Errorf("got %v, want 2 in the cache right away", x)
+	}
+	store.mu.Lock()
+	if len(store.values) != 1 {
+		t.Errorf("store has %v before the flush", store.values)
+	}
+	store.mu.Unlock()
+
+	clock.Advance(time.Minute)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		store.mu.Lock()
+		done := store.values["a"] == 2 && store.values["b"] == 3 && len(store.values) == 2
+		store.mu.Unlock()
+		if done {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("store has %v after the interval", store.values)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWriteBehindRetry(t *testing.T) {
+	store := &mapStore{values: map[interface{}]interface{}{}, err: errors.New("unavailable")}
+	var mu sync.Mutex
+	var failedKeys []interface{}
+	tc := New(DefaultExpiration, 0, WithWriteBehind(store, WriteBehindPolicy{
+		Interval: time.Hour,
+		OnError: func(k interface{}, err error) {
+			mu.Lock()
+			failedKeys = append(failedKeys, k)
+			mu.Unlock()
+		},
+	}))
+	defer tc.Close()
+
+	tc.Set("a", 1, DefaultExpiration)
+	tc.Set("b", 1, DefaultExpiration)
+	err := tc.FlushStore()
+	if !errors.Is(err, store.err) {
+		t.Errorf("got %v, want the store error", err)
+	}
+	if len(failedKeys) != 2 {
+		t.Errorf("OnError got %v, want a and b", failedKeys)
+	}
+
+	// Failed writes are retried, unless the key was written since.
+	tc.Set("b", 2, DefaultExpiration)
+	store.err = nil
+	if err := tc.FlushStore(); err != nil {
+		t.Fatal(err)
+	}
+	if store.values["a"] != 1 || store.values["b"] != 2 {
+		t.Errorf("store has %v, want a: 1, b: 2", store.values)
+	}
+}
+
+func TestWriteBehindOverflow(t *testing.T) {
+	for _, test := range []struct {
+		overflow OverflowPolicy
+		want     map[interface{}]interface{}
+		dropped  interface{}
+	}{
+		{OverflowDropNewest, map[interface{}]interface{}{"a": 1, "b": 2}, "c"},
+		{OverflowDropOldest, map[interface{}]interface{}{"b": 2, "c": 3}, "a"},
+	} {
+		store := &mapStore{values: map[interface{}]interface{}{}}
+		var dropped []interface{}
+		tc := New(DefaultExpiration, 0, WithWriteBehind(store, WriteBehindPolicy{
+			Interval:  time.Hour,
+			QueueSize: 2,
+			Overflow:  test.overflow,
+			OnError: func(k interface{}, err error) {
+				if !errors.Is(err, ErrWriteQueueFull) {
+					t.Errorf("got %v, want ErrWriteQueueFull", err)
+				}
+				dropped = append(dropped, k)
+			},
+		}))
+		// Stop the flusher so the queue stays full.
+		tc.writeBehind.stop <- struct{}{}
+
+		tc.Set("a", 1, DefaultExpiration)
+		tc.Set("b", 2, DefaultExpiration)
+		err := tc.TrySet("c", 3, DefaultExpiration)
+		if test.overflow == OverflowDropNewest && !errors.Is(err, ErrWriteQueueFull) {
+			t.Errorf("%v: got %v, want ErrWriteQueueFull", test.overflow, err)
+		}
+		if x, _ := tc.Get("c"); x != 3 {
+			t.Errorf("%v: got %v, want c stored in the cache", test.overflow, x)
+		}
+		if len(dropped) != 1 || dropped[0] != test.dropped {
+			t.Errorf("%v: dropped %v, want %v", test.overflow, dropped, test.dropped)
+		}
+		tc.FlushStore()
+		if len(store.values) != 2 {
+			t.Errorf("%v: store has %v, want %v", test.overflow, store.values, test.want)
+		}
+		for k, v := range test.want {
+			if store.values[k] != v {
+				t.Errorf("%v: store has %v, want %v", test.overflow, store.values, test.want)
+			}
+		}
+	}
+}
+
+func TestWriteBehindBlock(t *testing.T) {
+	store := &batchStore{mapStore: mapStore{values: map[interface{}]interface{}{}}}
+	tc := New(DefaultExpiration, 0, WithWriteBehind(store, WriteBehindPolicy{
+		Interval:  time.Hour,
+		QueueSize: 2,
+	}))
+
+	// The queue is flushed early when it fills up, so writers don't wait
+	// for the interval.
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 10; i++ {
+			tc.Set(i, i, DefaultExpiration)
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("writers blocked on a full queue")
+	}
+	tc.Close()
+	if len(store.values) != 10 {
+		t.Errorf("store has %d values after Close, want 10", len(store.values))
+	}
+	for _, batch := range store.batches {
+		if len(batch) > 2 {
+			t.Errorf("got a batch of %d writes, want at most the queue size", len(batch))
+		}
+	}
+
+	// After Close, writes go to the store synchronously.
+	tc.Set("after", 1, DefaultExpiration)
+	if store.values["after"] != 1 {
+		t.Error("write after Close didn't reach the store")
+	}
+}
+
+func TestShardedWriteBehind(t *testing.T) {
+	store := &mapStore{values: map[interface{}]interface{}{}}
+	sc := New(DefaultExpiration, 0, WithShards(4), WithWriteBehind(store, WriteBehindPolicy{Interval: time.Hour}))
+	defer sc.Close()
+	if sc.shards != nil {
+		t.Error("a cache with WithWriteBehind was split into shards")
+	}
+	for i := 0; i < 10; i++ {
+		sc.Set(i, i, DefaultExpiration)
+	}
+	if err := sc.FlushStore(); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.values) != 10 {
+		t.Errorf("store has %d values, want 10", len(store.values))
+	}
+}
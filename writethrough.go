@@ -0,0 +1,89 @@
+package cache
+
+import (
+	"hash/maphash"
+	"time"
+)
+
+// A Store is the backing store of a cache set up with WithWriteThrough, such
+// as a database or a slow key-value store. It must be safe for concurrent use.
+type Store interface {
+	// Put writes x under k, replacing any existing value.
+	Put(k interface{}, x interface{}) error
+	// Delete deletes k. Deleting a missing key is not an error.
+	Delete(k interface{}) error
+}
+
+// The store of a write-through cache, and the locks serializing the writes of
+// each key so that the store and the cache apply them in the same order.
+type writeThrough struct {
+	store Store
+	locks *keyLocks
+}
+
+// WithWriteThrough makes Set and Delete write to store before changing the
+// cache, so that the cache is a consistent front for it: a write that fails in
+// the store is not applied to the cache, and TrySet and TryDelete return its
+// error. Writes of the same key are serialized, but writes of different keys
+// run concurrently, and the cache is not locked while the store is written.
+// Only Set, Delete, TrySet and TryDelete write through; other writes such as
+// Add, Update, Increment or SetMany, and items expiring or being evicted, don't
+// change the store. Keys are passed to the store as given, before WithKeyFunc
+// applies.
+func WithWriteThrough(store Store) Option {
+	return func(c *cache) {
+		c.writeThrough = &writeThrough{
+			store: store,
+			locks: &keyLocks{seed: maphash.MakeSeed()},
+		}
+	}
+}
+
+// TrySet works like Set, but returns the error writing the item to the store
+// of a cache with WithWriteThrough, in which case the cache is not changed.
+// With WithWriteBehind, it returns an error wrapping ErrWriteQueueFull if the
+// write is dropped from the queue, in which case the cache is changed all the
+// same. Without a store it always returns nil.
+func (c *cache) TrySet(k interface{}, x interface{}, d time.Duration) error {
+	c = c.shard(k)
+	if c.writeThrough == nil {
+		if c.writeBehind != nil {
+			return c.writeBehind.write(c.key(k), StoreWrite{Key: k, Value: x}, func() {
+				c.setItem(k, x, d)
+			})
+		}
+		c.setItem(k, x, d)
+		return nil
+	}
+	unlock := c.writeThrough.locks.lock(c.key(k))
+	defer unlock()
+	if err := c.writeThrough.store.Put(k, x); err != nil {
+		return &KeyError{k, err}
+	}
+	c.setItem(k, x, d)
+	return nil
+}
+
+// TryDelete works like Delete, but returns the error deleting the key from
+// the store of a cache with WithWriteThrough, in which case the cache is not
+// changed, or the error dropping the deletion from the queue of a cache with
+// WithWriteBehind, as for TrySet. Without a store it always returns nil.
+func (c *cache) TryDelete(k interface{}) error {
+	c = c.shard(k)
+	if c.writeThrough == nil {
+		if c.writeBehind != nil {
+			return c.writeBehind.write(c.key(k), StoreWrite{Key: k, Delete: true}, func() {
+				c.deleteItem(k)
+			})
+		}
+		c.deleteItem(k)
+		return nil
+	}
+	unlock := c.writeThrough.locks.lock(c.key(k))
+	defer unlock()
+	if err := c.writeThrough.store.Delete(k); err != nil {
+		return &KeyError{k, err}
+	}
+	c.deleteItem(k)
+	return nil
+}
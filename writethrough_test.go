@@ -0,0 +1,112 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+// A Store holding values in a map.
+type mapStore struct {
+	mu     sync.Mutex
+	values map[interface{}]interface{}
+	err    error
+}
+
+func (s *mapStore) Put(k interface{}, x interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.values[k] = x
+	return nil
+}
+
+func (s *mapStore) Delete(k interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	delete(s.values, k)
+	return nil
+}
+
+func TestWriteThrough(t *testing.T) {
+	store := &mapStore{values: map[interface{}]interface{}{}}
+	tc := New(DefaultExpiration, 0, WithWriteThrough(store))
+
+	tc.Set("a", 1, DefaultExpiration)
+	if err := tc.TrySet("b", 2, DefaultExpiration); err != nil {
+		t.Fatal(err)
+	}
+	for k, want := range map[string]int{"a": 1, "b": 2} {
+		if x := store.values[k]; x != want {
+			t.Errorf("store has %v under %s, want %d", x, k, want)
+		}
+		if x, _ := tc.Get(k); x != want {
+			t.Errorf("cache has %v under %s, want %d", x, k, want)
+		}
+	}
+	tc.Delete("a")
+	if _, found := store.values["a"]; found {
+		t.Error("Delete didn't delete from the store")
+	}
+
+	// Failed writes leave the cache unchanged.
+	store.err = errors.New("unavailable")
+	err := tc.TrySet("b", 3, DefaultExpiration)
+	if !errors.Is(err, store.err) {
+		t.Errorf("got %v, want the store error", err)
+	}
+	tc.Set("c", 3, DefaultExpiration)
+	if err := tc.TryDelete("b"); !errors.Is(err, store.err) {
+		t.Errorf("got %v, want the store error", err)
+	}
+	tc.Delete("b")
+	if x, _ := tc.Get("b"); x != 2 {
+		t.Errorf("got %v, want 2 after failed writes", x)
+	}
+	if _, found := tc.Get("c"); found {
+		t.Error("Set stored an item the store failed to write")
+	}
+
+	// Other writes don't write through.
+	store.err = nil
+	tc.Add("d", 4, DefaultExpiration)
+	if _, found := store.values["d"]; found {
+		t.Error("Add wrote to the store")
+	}
+}
+
+func TestTrySetWithoutStore(t *testing.T) {
+	tc := New(DefaultExpiration, 0)
+	if err := tc.TrySet("a", 1, DefaultExpiration); err != nil {
+		t.Fatal(err)
+	}
+	if x, _ := tc.Get("a"); x != 1 {
+		t.Errorf("got %v, want 1", x)
+	}
+	if err := tc.TryDelete("a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, found := tc.Get("a"); found {
+		t.Error("TryDelete didn't delete")
+	}
+}
+
+func TestShardedWriteThrough(t *testing.T) {
+	store := &mapStore{values: map[interface{}]interface{}{}}
+	sc := New(DefaultExpiration, 0, WithShards(4), WithWriteThrough(store))
+	for i := 0; i < 10; i++ {
+		sc.Set(i, i, DefaultExpiration)
+	}
+	if len(store.values) != 10 {
+		t.Errorf("store has %d values, want 10", len(store.values))
+	}
+	store.err = errors.New("unavailable")
+	if err := sc.TryDelete(1); !errors.Is(err, store.err) {
+		t.Errorf("got %v, want the store error", err)
+	}
+}